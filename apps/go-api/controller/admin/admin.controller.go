@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"errors"
+	categoryService "go-api/services/category"
+	productService "go-api/services/product"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AdminController struct {
+	ProductService  productService.ProductService
+	CategoryService *categoryService.CategoryService
+}
+
+func NewAdminController(productService productService.ProductService, categoryService *categoryService.CategoryService) *AdminController {
+	return &AdminController{
+		ProductService:  productService,
+		CategoryService: categoryService,
+	}
+}
+
+// GetDeletedProducts godoc
+// @Summary      List trashed products
+// @Description  Returns all soft-deleted products, most recently deleted first
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.Product
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/trash/products [get]
+func (ac *AdminController) GetDeletedProducts(c *fiber.Ctx) error {
+	products, err := ac.ProductService.GetDeletedProducts(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching deleted products", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch deleted products",
+		})
+	}
+	return c.Status(http.StatusOK).JSON(products)
+}
+
+// RestoreProduct godoc
+// @Summary      Restore a trashed product
+// @Description  Restores a soft-deleted product by ID. A product whose category is trashed too needs category_id to move it to another one
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true   "Bearer {token}"
+// @Param        id             path      int     true   "Product ID"
+// @Param        category_id    query     int     false  "Category to restore the product into"
+// @Success      200  {object}  models.Product
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/trash/products/{id}/restore [post]
+func (ac *AdminController) RestoreProduct(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	categoryID, err := strconv.ParseUint(c.Query("category_id", "0"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid category ID",
+		})
+	}
+
+	product, err := ac.ProductService.RestoreProduct(c.UserContext(), uint(id), uint(categoryID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Deleted product not found",
+			})
+		case errors.Is(err, productService.ErrCategoryTrashed):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "The category of this product is in the trash, restore it first or pass category_id",
+			})
+		case errors.Is(err, productService.ErrInvalidProduct):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error restoring product", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not restore product",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(product)
+}
+
+// PurgeProduct godoc
+// @Summary      Permanently delete a trashed product
+// @Description  Removes a soft-deleted product from the database with its prices, reviews, subscriptions, stock levels, wishlist entries and images
+// @Tags         Admin
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Product ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/trash/products/{id} [delete]
+func (ac *AdminController) PurgeProduct(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	if err := ac.ProductService.PurgeProduct(c.UserContext(), uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Deleted product not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error purging product", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not purge product",
+		})
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetDeletedCategories godoc
+// @Summary      List trashed categories
+// @Description  Returns all soft-deleted categories, most recently deleted first
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.Category
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/trash/categories [get]
+func (ac *AdminController) GetDeletedCategories(c *fiber.Ctx) error {
+	categories, err := ac.CategoryService.GetDeletedCategories(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching deleted categories", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch deleted categories",
+		})
+	}
+	return c.Status(http.StatusOK).JSON(categories)
+}
+
+// RestoreCategory godoc
+// @Summary      Restore a trashed category
+// @Description  Restores a soft-deleted category by ID
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Category ID"
+// @Success      200  {object}  models.Category
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/trash/categories/{id}/restore [post]
+func (ac *AdminController) RestoreCategory(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid category ID",
+		})
+	}
+
+	category, err := ac.CategoryService.RestoreCategory(c.UserContext(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Deleted category not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error restoring category", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not restore category",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(category)
+}
+
+// PurgeCategory godoc
+// @Summary      Permanently delete a trashed category
+// @Description  Removes a soft-deleted category that no product references anymore
+// @Tags         Admin
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Category ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/trash/categories/{id} [delete]
+func (ac *AdminController) PurgeCategory(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid category ID",
+		})
+	}
+
+	if err := ac.CategoryService.PurgeCategory(c.UserContext(), uint(id)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Deleted category not found",
+			})
+		case errors.Is(err, categoryService.ErrCategoryHasProducts):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Category is still referenced by products",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error purging category", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not purge category",
+		})
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
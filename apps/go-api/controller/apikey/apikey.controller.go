@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"errors"
+	"go-api/middleware"
+	"go-api/models"
+	apiKeyService "go-api/services/apikey"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type APIKeyController struct {
+	APIKeyService apiKeyService.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService apiKeyService.APIKeyService) *APIKeyController {
+	return &APIKeyController{APIKeyService: apiKeyService}
+}
+
+// GetAPIKeys godoc
+// @Summary      List API keys
+// @Description  Returns every API key, revoked ones included, newest first. The keys themselves are never returned
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.APIKey
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/api-keys [get]
+func (kc *APIKeyController) GetAPIKeys(c *fiber.Ctx) error {
+	keys, err := kc.APIKeyService.GetAPIKeys(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching API keys", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch API keys",
+		})
+	}
+	return c.JSON(keys)
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Creates a key for a machine integration. Scopes is a comma separated list of catalog:read, inventory:read, inventory:write and orders:read. The key is only returned in this response, send it in the X-API-Key header
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string              true  "Bearer {token}"
+// @Param        key            body      models.APIKeyInput  true  "API key"
+// @Success      201  {object}  models.NewAPIKey
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/api-keys [post]
+func (kc *APIKeyController) CreateAPIKey(c *fiber.Ctx) error {
+	var input models.APIKeyInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	key, err := kc.APIKeyService.CreateAPIKey(c.UserContext(), input, middleware.UserID(c))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(key)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Disables an API key for good
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "API key ID"
+// @Success      200  {object}  models.APIKey
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/api-keys/{id} [delete]
+func (kc *APIKeyController) RevokeAPIKey(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	key, err := kc.APIKeyService.RevokeAPIKey(c.UserContext(), uint(id))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+	return c.JSON(key)
+}
+
+func apiKeyError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, apiKeyService.ErrInvalidAPIKey):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "API key not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving API key", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save API key",
+	})
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"errors"
+	"go-api/models"
+	attributeService "go-api/services/attribute"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AttributeController struct {
+	AttributeService attributeService.AttributeService
+}
+
+func NewAttributeController(attributeService attributeService.AttributeService) *AttributeController {
+	return &AttributeController{AttributeService: attributeService}
+}
+
+// GetCategoryAttributes godoc
+// @Summary      List category attributes
+// @Description  Returns the attribute schema products of the category follow
+// @Tags         Attributes
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Category ID"
+// @Success      200  {array}   models.CategoryAttribute
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /categories/{id}/attributes [get]
+func (ac *AttributeController) GetCategoryAttributes(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid category ID",
+		})
+	}
+
+	attributes, err := ac.AttributeService.GetCategoryAttributes(c.UserContext(), uint(id))
+	if err != nil {
+		return attributeError(c, err)
+	}
+	return c.JSON(attributes)
+}
+
+// CreateCategoryAttribute godoc
+// @Summary      Create a category attribute
+// @Description  Adds an attribute to the schema of a category. Types are text, number, boolean and enum, enums list their comma separated allowed_values
+// @Tags         Attributes
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                         true  "Bearer {token}"
+// @Param        id             path      int                            true  "Category ID"
+// @Param        attribute      body      models.CategoryAttributeInput  true  "Attribute"
+// @Success      201  {object}  models.CategoryAttribute
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/categories/{id}/attributes [post]
+func (ac *AttributeController) CreateCategoryAttribute(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid category ID",
+		})
+	}
+
+	var input models.CategoryAttributeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	attribute, err := ac.AttributeService.CreateAttribute(c.UserContext(), uint(id), input)
+	if err != nil {
+		return attributeError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(attribute)
+}
+
+// UpdateCategoryAttribute godoc
+// @Summary      Update a category attribute
+// @Description  Replaces an attribute definition. The type cannot change once products have a value for it
+// @Tags         Attributes
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                         true  "Bearer {token}"
+// @Param        id             path      int                            true  "Attribute ID"
+// @Param        attribute      body      models.CategoryAttributeInput  true  "Attribute"
+// @Success      200  {object}  models.CategoryAttribute
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/attributes/{id} [put]
+func (ac *AttributeController) UpdateCategoryAttribute(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attribute ID",
+		})
+	}
+
+	var input models.CategoryAttributeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	attribute, err := ac.AttributeService.UpdateAttribute(c.UserContext(), uint(id), input)
+	if err != nil {
+		return attributeError(c, err)
+	}
+	return c.JSON(attribute)
+}
+
+// DeleteCategoryAttribute godoc
+// @Summary      Delete a category attribute
+// @Description  Deletes an attribute and the values products had for it
+// @Tags         Attributes
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Attribute ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/attributes/{id} [delete]
+func (ac *AttributeController) DeleteCategoryAttribute(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid attribute ID",
+		})
+	}
+
+	if err := ac.AttributeService.DeleteAttribute(c.UserContext(), uint(id)); err != nil {
+		return attributeError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetProductAttributes godoc
+// @Summary      List product attributes
+// @Description  Returns the attribute values of a product with their definitions
+// @Tags         Attributes
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Product ID"
+// @Success      200  {array}   models.ProductAttributeValue
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /products/{id}/attributes [get]
+func (ac *AttributeController) GetProductAttributes(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	values, err := ac.AttributeService.GetProductAttributes(c.UserContext(), uint(id))
+	if err != nil {
+		return attributeError(c, err)
+	}
+	return c.JSON(values)
+}
+
+// SetProductAttributes godoc
+// @Summary      Set product attributes
+// @Description  Replaces the attribute values of a product, validated against the attributes of its category
+// @Tags         Attributes
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                         true  "Bearer {token}"
+// @Param        id             path      int                            true  "Product ID"
+// @Param        attributes     body      models.ProductAttributesInput  true  "Values by attribute code"
+// @Success      200  {array}   models.ProductAttributeValue
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/products/{id}/attributes [put]
+func (ac *AttributeController) SetProductAttributes(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	var input models.ProductAttributesInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	values, err := ac.AttributeService.SetProductAttributes(c.UserContext(), uint(id), input.Values)
+	if err != nil {
+		return attributeError(c, err)
+	}
+	return c.JSON(values)
+}
+
+func attributeError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, attributeService.ErrInvalidAttribute),
+		errors.Is(err, attributeService.ErrInvalidAttributeValue):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, attributeService.ErrAttributeInUse):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Category, product or attribute not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving attributes", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save attributes",
+	})
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"go-api/models"
+	catalogService "go-api/services/catalog"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CatalogController struct {
+	CatalogService catalogService.CatalogService
+}
+
+func NewCatalogController(catalogService catalogService.CatalogService) *CatalogController {
+	return &CatalogController{CatalogService: catalogService}
+}
+
+// StartImport godoc
+// @Summary      Import products or categories
+// @Description  Uploads a CSV (with a header row) or NDJSON file and imports it in the background. Products are upserted by SKU and categories by name. Columns are matched to fields by name unless mapped otherwise, empty values are ignored. Poll the returned job for progress
+// @Tags         Catalog
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        Authorization  header    string  true   "Bearer {token}"
+// @Param        file           formData  file    true   "CSV or NDJSON file"
+// @Param        entity         formData  string  false  "products (default) or categories"
+// @Param        format         formData  string  false  "csv or ndjson, guessed from the file name when omitted"
+// @Param        dry_run        formData  bool    false  "Only validate the rows"
+// @Param        mapping        formData  string  false  "JSON object mapping source columns to fields, e.g. {\"Article\":\"sku\"}"
+// @Success      202  {object}  models.ImportJob
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/catalog/imports [post]
+func (cc *CatalogController) StartImport(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "A file field is required",
+		})
+	}
+
+	options := models.ImportOptions{
+		Entity:   c.FormValue("entity"),
+		Format:   c.FormValue("format"),
+		FileName: header.Filename,
+	}
+	if value := c.FormValue("dry_run"); value != "" {
+		if options.DryRun, err = strconv.ParseBool(value); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "dry_run must be true or false",
+			})
+		}
+	}
+	if value := c.FormValue("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &options.Mapping); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "mapping must be a JSON object of column names to fields",
+			})
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error opening uploaded file", "error", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not read the uploaded file",
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error reading uploaded file", "error", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not read the uploaded file",
+		})
+	}
+
+	job, err := cc.CatalogService.StartImport(c.UserContext(), data, options)
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return c.Status(http.StatusAccepted).JSON(job)
+}
+
+// GetImportJobs godoc
+// @Summary      List import jobs
+// @Description  Returns the catalog import jobs, newest first
+// @Tags         Catalog
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.ImportJob
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/catalog/imports [get]
+func (cc *CatalogController) GetImportJobs(c *fiber.Ctx) error {
+	jobs, err := cc.CatalogService.GetImportJobs(c.UserContext())
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return c.JSON(jobs)
+}
+
+// GetImportJob godoc
+// @Summary      Get an import job
+// @Description  Returns the status and progress of an import with the errors of the rejected rows
+// @Tags         Catalog
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Import job ID"
+// @Success      200  {object}  models.ImportJob
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/catalog/imports/{id} [get]
+func (cc *CatalogController) GetImportJob(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid import job ID",
+		})
+	}
+
+	job, err := cc.CatalogService.GetImportJob(c.UserContext(), uint(id))
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return c.JSON(job)
+}
+
+// ExportCatalog godoc
+// @Summary      Export the catalog
+// @Description  Streams every product or category as CSV or NDJSON, using the same fields an import reads
+// @Tags         Catalog
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        Authorization  header    string  false  "Bearer {token}"
+// @Param        X-API-Key      header    string  false  "API key with the scope of the route"
+// @Param        entity         query     string  false  "products (default) or categories"
+// @Param        format         query     string  false  "csv (default) or ndjson"
+// @Success      200  {string}  string
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/catalog/export [get]
+func (cc *CatalogController) ExportCatalog(c *fiber.Ctx) error {
+	entity := c.Query("entity", models.CatalogProducts)
+	format := c.Query("format", models.CatalogCSV)
+	if err := catalogService.ValidateExport(entity, format); err != nil {
+		return catalogError(c, err)
+	}
+
+	contentType := "text/csv"
+	if format == models.CatalogNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+entity+"."+format+`"`)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := cc.CatalogService.Export(c.UserContext(), w, entity, format); err != nil {
+			slog.ErrorContext(c.UserContext(), "Error exporting catalog", "error", err)
+		}
+		if err := w.Flush(); err != nil {
+			slog.ErrorContext(c.UserContext(), "Error exporting catalog", "error", err)
+		}
+	})
+	return nil
+}
+
+func catalogError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, catalogService.ErrInvalidImport):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Import job not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error processing catalog request", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not process catalog request",
+	})
+}
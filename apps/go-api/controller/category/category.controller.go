@@ -1,37 +1,48 @@
 package controller
 
 import (
+	"errors"
+	"go-api/controller/query"
 	"go-api/models"
 	services "go-api/services/category"
-	"log"
+	currencyService "go-api/services/currency"
+	pricingService "go-api/services/pricing"
+	productService "go-api/services/product"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type CategoryController struct {
 	CategoryService *services.CategoryService
+	ProductService  productService.ProductService
+	PricingService  pricingService.PricingService
 }
 
-func NewCategoryController(categoryService *services.CategoryService) *CategoryController {
+func NewCategoryController(categoryService *services.CategoryService, productService productService.ProductService, pricingService pricingService.PricingService) *CategoryController {
 	return &CategoryController{
 		CategoryService: categoryService,
+		ProductService:  productService,
+		PricingService:  pricingService,
 	}
 }
 
 // GetAllCategories godoc
 // @Summary      Get all categories
-// @Description  Fetch all categories
+// @Description  Fetch all categories with their product counts
 // @Tags         Categories
 // @Accept       json
 // @Produce      json
-// @Success      200  {array}  models.Category
+// @Success      200  {array}  models.CategoryWithCount
 // @Router       /categories [get]
 func (cc *CategoryController) GetAllCategories(c *fiber.Ctx) error {
-	categories, err := cc.CategoryService.GetAllCategories()
+	categories, err := cc.CategoryService.GetAllCategories(c.UserContext())
 	if err != nil {
-		log.Println("Error fetching categories:", err)
+		slog.ErrorContext(c.UserContext(), "Error fetching categories", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not fetch categories",
 		})
@@ -53,15 +64,15 @@ func (cc *CategoryController) CreateCategory(c *fiber.Ctx) error {
 	var category models.Category
 
 	if err := c.BodyParser(&category); err != nil {
-		log.Println("Invalid request body:", err)
+		slog.ErrorContext(c.UserContext(), "Invalid request body", "error", err)
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
 
-	newCategory, err := cc.CategoryService.CreateCategory(category)
+	newCategory, err := cc.CategoryService.CreateCategory(c.UserContext(), category)
 	if err != nil {
-		log.Println("Error creating category:", err)
+		slog.ErrorContext(c.UserContext(), "Error creating category", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create category",
 		})
@@ -72,12 +83,12 @@ func (cc *CategoryController) CreateCategory(c *fiber.Ctx) error {
 
 // GetCategoryByID godoc
 // @Summary      Get category by ID
-// @Description  Fetch category details by ID
+// @Description  Fetch category details and product count by ID
 // @Tags         Categories
 // @Accept       json
 // @Produce      json
 // @Param        id   path      int  true  "Category ID"
-// @Success      200  {object}  models.Category
+// @Success      200  {object}  models.CategoryWithCount
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Router       /categories/{id} [get]
@@ -89,9 +100,9 @@ func (cc *CategoryController) GetCategoryByID(c *fiber.Ctx) error {
 		})
 	}
 
-	category, err := cc.CategoryService.GetCategoryByID(uint(id))
+	category, err := cc.CategoryService.GetCategoryWithCount(c.UserContext(), uint(id))
 	if err != nil {
-		log.Println("Error fetching category:", err)
+		slog.ErrorContext(c.UserContext(), "Error fetching category", "error", err)
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{
 			"error": "Category not found",
 		})
@@ -102,12 +113,17 @@ func (cc *CategoryController) GetCategoryByID(c *fiber.Ctx) error {
 
 // DeleteCategory godoc
 // @Summary      Delete a category
-// @Description  Remove a category by ID
+// @Description  Remove a category by ID. mode decides what happens to its products: restrict refuses while products exist, reassign moves them to target_id, deactivate marks them inactive, which hides them from everyone but admins. With dry_run=true nothing is changed and the report is returned.
 // @Tags         Categories
-// @Param        id   path      int  true  "Category ID"
-// @Success      204  "No Content"
+// @Produce      json
+// @Param        id         path      int     true   "Category ID"
+// @Param        mode       query     string  false  "restrict (default), reassign or deactivate"
+// @Param        target_id  query     int     false  "Category receiving the products when mode=reassign"
+// @Param        dry_run    query     bool    false  "Only report what would change"
+// @Success      200  {object}  models.CategoryDeleteResult
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
 // @Router       /categories/{id} [delete]
 func (cc *CategoryController) DeleteCategory(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
@@ -117,36 +133,120 @@ func (cc *CategoryController) DeleteCategory(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := cc.CategoryService.DeleteCategory(uint(id)); err != nil {
-		log.Println("Error deleting category:", err)
+	opts := models.CategoryDeleteOptions{
+		Mode:   c.Query("mode", models.CategoryDeleteRestrict),
+		DryRun: c.QueryBool("dry_run", false),
+	}
+
+	if targetID := c.Query("target_id"); targetID != "" {
+		target, err := strconv.ParseUint(targetID, 10, 32)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid target_id",
+			})
+		}
+		opts.TargetCategory = uint(target)
+	}
+
+	result, err := cc.CategoryService.DeleteCategory(c.UserContext(), uint(id), opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Category not found",
+			})
+		case errors.Is(err, services.ErrInvalidDeleteMode):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid mode, expected restrict, reassign or deactivate",
+			})
+		case errors.Is(err, services.ErrInvalidTargetCategory):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "target_id must reference another existing category",
+			})
+		case errors.Is(err, services.ErrCategoryHasProducts):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "Category still has products, use mode=reassign or mode=deactivate",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error deleting category", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not delete category",
 		})
 	}
 
-	return c.SendStatus(http.StatusNoContent)
+	return c.Status(http.StatusOK).JSON(result)
 }
 
 // GetProductsByCategory godoc
 // @Summary      Get products by category
-// @Description  Returns all products for a given category
-// @Tags         Products
+// @Description  Returns a filtered, sorted page of products for a given category. Attributes are filtered with attr.<code>=a,b (any of the values) and attr.<code>.min / attr.<code>.max for numbers
+// @Tags         Categories
 // @Accept       json
 // @Produce      json
-// @Param        categoryId path string true "Category ID"
-// @Success      200  {array}  models.Product
-// @Failure      404  {object} map[string]string
-// @Router       /products/category/{categoryId} [get]
+// @Param        id             path   int     true   "Category ID"
+// @Param        q              query  string  false  "Search in name and description"
+// @Param        in_stock       query  bool    false  "Filter by stock availability"
+// @Param        active         query  bool    false  "Filter by active flag"
+// @Param        on_discount    query  bool    false  "Filter by discount"
+// @Param        min_price      query  number  false  "Minimum price"
+// @Param        max_price      query  number  false  "Maximum price"
+// @Param        created_after  query  string  false  "RFC3339 timestamp or YYYY-MM-DD"
+// @Param        sort           query  string  false  "Comma separated keys: price, name, newest, popularity, rating; prefix with - to reverse"
+// @Param        page           query  int     false  "Page number, starting at 1"
+// @Param        limit          query  int     false  "Page size, at most 100"
+// @Param        currency       query  string  false  "ISO 4217 currency to price in"
+// @Success      200  {object}  models.ProductPage
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /categories/{id}/products [get]
 func (cc *CategoryController) GetProductsByCategory(c *fiber.Ctx) error {
-	categoryId := c.Params("categoryId")
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid category ID",
+		})
+	}
 
-	products, err := cc.CategoryService.GetProductsByCategory(categoryId)
+	listQuery, err := query.ParseProductListQuery(c)
 	if err != nil {
-		log.Println("Error fetching products by category:", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if _, err := cc.CategoryService.GetCategoryByID(c.UserContext(), uint(id)); err != nil {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{
-			"error": "No products found for the given category",
+			"error": "Category not found",
+		})
+	}
+
+	categoryID := uint(id)
+	listQuery.CategoryID = &categoryID
+
+	page, err := cc.ProductService.ListProducts(c.UserContext(), listQuery)
+	if err != nil {
+		if errors.Is(err, productService.ErrInvalidSort) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid sort, expected " + strings.Join(productService.SortKeys(), ", "),
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error fetching products by category", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch products",
+		})
+	}
+
+	if err := cc.PricingService.Localize(c.UserContext(), page.Data, query.Currency(c)); err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error localizing prices", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not convert prices",
 		})
 	}
 
-	return c.JSON(products)
+	return c.JSON(page)
 }
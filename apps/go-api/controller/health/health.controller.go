@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"go-api/models"
+	healthService "go-api/services/health"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthController struct {
+	HealthService healthService.HealthService
+}
+
+func NewHealthController(healthService healthService.HealthService) *HealthController {
+	return &HealthController{HealthService: healthService}
+}
+
+// GetLiveness godoc
+// @Summary      Liveness probe
+// @Description  Reports that the process is up. Dependencies are not checked
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}  models.HealthReport
+// @Router       /livez [get]
+func (hc *HealthController) GetLiveness(c *fiber.Ctx) error {
+	return c.JSON(hc.HealthService.Liveness())
+}
+
+// GetReadiness godoc
+// @Summary      Readiness probe
+// @Description  Checks the database pool, the migrations and the RabbitMQ consumer, with the latency of each check
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}  models.HealthReport
+// @Failure      503  {object}  models.HealthReport
+// @Router       /readyz [get]
+func (hc *HealthController) GetReadiness(c *fiber.Ctx) error {
+	report := hc.HealthService.Readiness(c.UserContext())
+	if report.Status != models.HealthUp {
+		var failed []string
+		for name, component := range report.Components {
+			if component.Status != models.HealthUp {
+				failed = append(failed, name+": "+component.Error)
+			}
+		}
+		slog.WarnContext(c.UserContext(), "Readiness check failed", "components", failed)
+		return c.Status(http.StatusServiceUnavailable).JSON(report)
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"errors"
+	"go-api/models"
+	imageService "go-api/services/image"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ImageController struct {
+	ImageService imageService.ImageService
+}
+
+func NewImageController(imageService imageService.ImageService) *ImageController {
+	return &ImageController{ImageService: imageService}
+}
+
+// GetProductImages godoc
+// @Summary      List product images
+// @Description  Returns the images of a product in display order
+// @Tags         Product Images
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Product ID"
+// @Success      200  {array}   models.ProductImage
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /products/{id}/images [get]
+func (ic *ImageController) GetProductImages(c *fiber.Ctx) error {
+	productID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	images, err := ic.ImageService.GetImages(c.UserContext(), uint(productID))
+	if err != nil {
+		return imageError(c, err)
+	}
+	return c.JSON(images)
+}
+
+// UploadProductImage godoc
+// @Summary      Upload a product image
+// @Description  Uploads a JPEG, PNG or GIF image and appends it to the product images. A thumbnail is generated and oversized images are scaled down. The first image becomes the product image
+// @Tags         Product Images
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        Authorization  header    string  true   "Bearer {token}"
+// @Param        id             path      int     true   "Product ID"
+// @Param        file           formData  file    true   "Image file"
+// @Param        alt_text       formData  string  false  "Alternative text"
+// @Success      201  {object}  models.ProductImage
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      413  {object}  map[string]string
+// @Failure      415  {object}  map[string]string
+// @Router       /admin/products/{id}/images [post]
+func (ic *ImageController) UploadProductImage(c *fiber.Ctx) error {
+	productID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "A file field is required",
+		})
+	}
+	file, err := header.Open()
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error opening uploaded file", "error", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not read the uploaded file",
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error reading uploaded file", "error", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not read the uploaded file",
+		})
+	}
+
+	image, err := ic.ImageService.UploadImage(c.UserContext(), uint(productID), data, c.FormValue("alt_text"))
+	if err != nil {
+		return imageError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(image)
+}
+
+// UpdateProductImage godoc
+// @Summary      Update a product image
+// @Description  Changes the alt text of an image and optionally moves it to another position
+// @Tags         Product Images
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                          true  "Bearer {token}"
+// @Param        id             path      int                             true  "Product ID"
+// @Param        imageId        path      int                             true  "Image ID"
+// @Param        image          body      models.ProductImageUpdateInput  true  "Changes"
+// @Success      200  {object}  models.ProductImage
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/products/{id}/images/{imageId} [patch]
+func (ic *ImageController) UpdateProductImage(c *fiber.Ctx) error {
+	productID, imageID, ok := imageIDs(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product or image ID",
+		})
+	}
+
+	var input models.ProductImageUpdateInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	image, err := ic.ImageService.UpdateImage(c.UserContext(), productID, imageID, input)
+	if err != nil {
+		return imageError(c, err)
+	}
+	return c.JSON(image)
+}
+
+// ReorderProductImages godoc
+// @Summary      Reorder product images
+// @Description  Sets the display order of the images of a product. image_ids must list every image once
+// @Tags         Product Images
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                         true  "Bearer {token}"
+// @Param        id             path      int                            true  "Product ID"
+// @Param        order          body      models.ProductImageOrderInput  true  "Image order"
+// @Success      200  {array}   models.ProductImage
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/products/{id}/images/order [put]
+func (ic *ImageController) ReorderProductImages(c *fiber.Ctx) error {
+	productID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	var input models.ProductImageOrderInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	images, err := ic.ImageService.ReorderImages(c.UserContext(), uint(productID), input.ImageIDs)
+	if err != nil {
+		return imageError(c, err)
+	}
+	return c.JSON(images)
+}
+
+// DeleteProductImage godoc
+// @Summary      Delete a product image
+// @Description  Deletes an image together with its stored files
+// @Tags         Product Images
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Product ID"
+// @Param        imageId        path      int     true  "Image ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/products/{id}/images/{imageId} [delete]
+func (ic *ImageController) DeleteProductImage(c *fiber.Ctx) error {
+	productID, imageID, ok := imageIDs(c)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product or image ID",
+		})
+	}
+
+	if err := ic.ImageService.DeleteImage(c.UserContext(), productID, imageID); err != nil {
+		return imageError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func imageIDs(c *fiber.Ctx) (uint, uint, bool) {
+	productID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	imageID, err := strconv.ParseUint(c.Params("imageId"), 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(productID), uint(imageID), true
+}
+
+func imageError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, imageService.ErrInvalidImage):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, imageService.ErrImageTooLarge):
+		return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, imageService.ErrUnsupportedImageType):
+		return c.Status(http.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Product or image not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving product image", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save product image",
+	})
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	inventoryService "go-api/services/inventory"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InventoryController struct {
+	InventoryService inventoryService.InventoryService
+}
+
+func NewInventoryController(inventoryService inventoryService.InventoryService) *InventoryController {
+	return &InventoryController{InventoryService: inventoryService}
+}
+
+// GetLowStockReport godoc
+// @Summary      Low-stock report
+// @Description  Returns the products at or below their reorder threshold and those out of stock, emptiest first
+// @Tags         Inventory
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false "Bearer {token}"
+// @Param        X-API-Key      header    string  false "API key with the scope of the route"
+// @Success      200  {array}   models.Product
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/inventory/low-stock [get]
+func (ic *InventoryController) GetLowStockReport(c *fiber.Ctx) error {
+	products, err := ic.InventoryService.GetLowStock(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching low-stock report", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch low-stock report",
+		})
+	}
+	return c.JSON(products)
+}
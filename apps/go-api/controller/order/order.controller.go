@@ -0,0 +1,328 @@
+package controller
+
+import (
+	"errors"
+	"go-api/controller/shared"
+	"go-api/middleware"
+	"go-api/models"
+	orderService "go-api/services/order"
+	promotionService "go-api/services/promotion"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type OrderController struct {
+	OrderService orderService.OrderService
+}
+
+func NewOrderController(orderService orderService.OrderService) *OrderController {
+	return &OrderController{OrderService: orderService}
+}
+
+// PlaceOrder godoc
+// @Summary      Place an order
+// @Description  Prices the cart with promotions, shipping and tax, reserves the stock and creates a pending order
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string             true  "Bearer {token}"
+// @Param        order          body      models.OrderInput  true  "Cart, delivery address and shipping method"
+// @Success      201  {object}  models.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Router       /orders [post]
+func (oc *OrderController) PlaceOrder(c *fiber.Ctx) error {
+	var input models.OrderInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	order, err := oc.OrderService.PlaceOrder(c.UserContext(), middleware.UserID(c), input)
+	if err != nil {
+		if errors.Is(err, promotionService.ErrUsageLimitReached) {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": "A promotion reached its usage limit, please validate the cart again",
+			})
+		}
+		return shared.CheckoutError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(order)
+}
+
+// GetOrders godoc
+// @Summary      List my orders
+// @Description  Returns the orders of the authenticated user, newest first
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.Order
+// @Failure      500  {object}  map[string]string
+// @Router       /orders [get]
+func (oc *OrderController) GetOrders(c *fiber.Ctx) error {
+	orders, err := oc.OrderService.GetOrders(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching orders", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch orders",
+		})
+	}
+	return c.JSON(orders)
+}
+
+// GetOrderByID godoc
+// @Summary      Get one of my orders
+// @Description  Returns an order of the authenticated user with its items, shipments and tracking events
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Order ID"
+// @Success      200  {object}  models.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /orders/{id} [get]
+func (oc *OrderController) GetOrderByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	order, err := oc.ownOrder(c, uint(id))
+	if err != nil {
+		return orderError(c, err)
+	}
+	return c.JSON(order)
+}
+
+// CancelOrder godoc
+// @Summary      Cancel one of my orders
+// @Description  Cancels a pending order of the authenticated user and releases the reserved stock. Pending orders are also cancelled once ORDER_PENDING_TTL_HOURS have passed
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Order ID"
+// @Success      200  {object}  models.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /orders/{id}/cancel [post]
+func (oc *OrderController) CancelOrder(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	order, err := oc.ownOrder(c, uint(id))
+	if err != nil {
+		return orderError(c, err)
+	}
+
+	order, err = oc.OrderService.TransitionFrom(c.UserContext(), order.ID, models.OrderPending, models.OrderCancelled)
+	if err != nil {
+		return orderError(c, err)
+	}
+	return c.JSON(order)
+}
+
+// TrackShipment godoc
+// @Summary      Track a shipment
+// @Description  Returns a shipment and its tracking events by tracking number
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        tracking_number  path      string  true  "Tracking number"
+// @Success      200  {object}  models.Shipment
+// @Failure      404  {object}  map[string]string
+// @Router       /shipments/{tracking_number} [get]
+func (oc *OrderController) TrackShipment(c *fiber.Ctx) error {
+	shipment, err := oc.OrderService.TrackShipment(c.UserContext(), c.Params("tracking_number"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Shipment not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error fetching shipment", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch shipment",
+		})
+	}
+	return c.JSON(shipment)
+}
+
+// GetAllOrders godoc
+// @Summary      List all orders
+// @Description  Returns every order, newest first, optionally filtered by status
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false  "Bearer {token}"
+// @Param        X-API-Key      header    string  false  "API key with the scope of the route"
+// @Param        status         query     string  false  "Order status"
+// @Success      200  {array}   models.Order
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/orders [get]
+func (oc *OrderController) GetAllOrders(c *fiber.Ctx) error {
+	orders, err := oc.OrderService.GetAllOrders(c.UserContext(), c.Query("status"))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching orders", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch orders",
+		})
+	}
+	return c.JSON(orders)
+}
+
+// UpdateOrderStatus godoc
+// @Summary      Change the status of an order
+// @Description  Moves an order along pending, paid, shipped, delivered or cancels it. Cancelling releases the reserved stock
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                   true  "Bearer {token}"
+// @Param        id             path      int                      true  "Order ID"
+// @Param        status         body      models.OrderStatusInput  true  "New status"
+// @Success      200  {object}  models.Order
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/orders/{id}/status [patch]
+func (oc *OrderController) UpdateOrderStatus(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	var input models.OrderStatusInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	order, err := oc.OrderService.Transition(c.UserContext(), uint(id), input.Status)
+	if err != nil {
+		return orderError(c, err)
+	}
+	return c.JSON(order)
+}
+
+// CreateShipment godoc
+// @Summary      Create a shipment
+// @Description  Registers a carrier tracking number for a paid or shipped order
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                true  "Bearer {token}"
+// @Param        id             path      int                   true  "Order ID"
+// @Param        shipment       body      models.ShipmentInput  true  "Carrier and tracking number"
+// @Success      201  {object}  models.Shipment
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/orders/{id}/shipments [post]
+func (oc *OrderController) CreateShipment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	var input models.ShipmentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	shipment, err := oc.OrderService.CreateShipment(c.UserContext(), uint(id), input)
+	if err != nil {
+		return orderError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(shipment)
+}
+
+// AddShipmentEvent godoc
+// @Summary      Add a tracking event
+// @Description  Records a carrier tracking event. Moving parcels mark the order shipped and the order is delivered once all its shipments are
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                     true  "Bearer {token}"
+// @Param        id             path      int                        true  "Shipment ID"
+// @Param        event          body      models.ShipmentEventInput  true  "Tracking event"
+// @Success      200  {object}  models.Shipment
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/shipments/{id}/events [post]
+func (oc *OrderController) AddShipmentEvent(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid shipment ID",
+		})
+	}
+
+	var input models.ShipmentEventInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	shipment, err := oc.OrderService.AddShipmentEvent(c.UserContext(), uint(id), input)
+	if err != nil {
+		return orderError(c, err)
+	}
+	return c.JSON(shipment)
+}
+
+// ownOrder loads an order and reports it as missing when it belongs to
+// another user.
+func (oc *OrderController) ownOrder(c *fiber.Ctx, id uint) (models.Order, error) {
+	order, err := oc.OrderService.GetOrder(c.UserContext(), id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if order.UserID != middleware.UserID(c) {
+		return models.Order{}, gorm.ErrRecordNotFound
+	}
+	return order, nil
+}
+
+func orderError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, orderService.ErrInvalidTransition), errors.Is(err, orderService.ErrStatusChanged):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, orderService.ErrInvalidShipment):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Order or shipment not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error updating order", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not update order",
+	})
+}
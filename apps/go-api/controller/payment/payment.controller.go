@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"errors"
+	"go-api/middleware"
+	"go-api/models"
+	paymentService "go-api/services/payment"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type PaymentController struct {
+	PaymentService paymentService.PaymentService
+}
+
+func NewPaymentController(paymentService paymentService.PaymentService) *PaymentController {
+	return &PaymentController{PaymentService: paymentService}
+}
+
+// PayOrder godoc
+// @Summary      Pay one of my orders
+// @Description  Authorizes the order total with the payment provider. A successful payment marks the order paid once captured, a declined payment cancels the order
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string               true  "Bearer {token}"
+// @Param        id             path      int                  true  "Order ID"
+// @Param        payment        body      models.PaymentInput  true  "Payment method"
+// @Success      201  {object}  models.Payment
+// @Failure      400  {object}  map[string]string
+// @Failure      402  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /orders/{id}/pay [post]
+func (pc *PaymentController) PayOrder(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	var input models.PaymentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	payment, err := pc.PaymentService.Pay(c.UserContext(), uint(id), middleware.UserID(c), input)
+	if err != nil {
+		if errors.Is(err, paymentService.ErrPaymentDeclined) {
+			return c.Status(http.StatusPaymentRequired).JSON(fiber.Map{
+				"error":   err.Error(),
+				"payment": payment,
+			})
+		}
+		return paymentError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(payment)
+}
+
+// GetOrderPayments godoc
+// @Summary      List the payments of an order
+// @Description  Returns every payment attempt of an order
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false "Bearer {token}"
+// @Param        X-API-Key      header    string  false "API key with the scope of the route"
+// @Param        id             path      int     true  "Order ID"
+// @Success      200  {array}   models.Payment
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/orders/{id}/payments [get]
+func (pc *PaymentController) GetOrderPayments(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	payments, err := pc.PaymentService.GetPayments(c.UserContext(), uint(id))
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(payments)
+}
+
+// CapturePayment godoc
+// @Summary      Capture a payment
+// @Description  Collects an authorized payment and marks the order paid
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Payment ID"
+// @Success      200  {object}  models.Payment
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/payments/{id}/capture [post]
+func (pc *PaymentController) CapturePayment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid payment ID",
+		})
+	}
+
+	payment, err := pc.PaymentService.Capture(c.UserContext(), uint(id))
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(payment)
+}
+
+// RefundPayment godoc
+// @Summary      Refund a payment
+// @Description  Refunds part of a captured payment, or the rest of it when no amount is given. A full refund moves the order to refunded. A payment takes one refund at a time, 409 while another one is in progress
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string              true   "Bearer {token}"
+// @Param        id             path      int                 true   "Payment ID"
+// @Param        refund         body      models.RefundInput  false  "Refund amount"
+// @Success      200  {object}  models.Payment
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/payments/{id}/refund [post]
+func (pc *PaymentController) RefundPayment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid payment ID",
+		})
+	}
+
+	var input models.RefundInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	payment, err := pc.PaymentService.Refund(c.UserContext(), uint(id), input)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(payment)
+}
+
+// VoidPayment godoc
+// @Summary      Void a payment
+// @Description  Releases an authorization that was not captured and cancels the order
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Payment ID"
+// @Success      200  {object}  models.Payment
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/payments/{id}/void [post]
+func (pc *PaymentController) VoidPayment(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid payment ID",
+		})
+	}
+
+	payment, err := pc.PaymentService.Void(c.UserContext(), uint(id))
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(payment)
+}
+
+// HandleWebhook godoc
+// @Summary      Payment provider webhook
+// @Description  Receives payment events signed with the shared secret in X-Payment-Signature (hex HMAC-SHA256 of the body). Events are applied once, redeliveries are acknowledged without effect
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        X-Payment-Signature  header    string               true  "Hex HMAC-SHA256 of the body"
+// @Param        event                body      models.PaymentEvent  true  "Payment event"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /payments/webhook [post]
+func (pc *PaymentController) HandleWebhook(c *fiber.Ctx) error {
+	processed, err := pc.PaymentService.HandleWebhook(c.UserContext(), c.Body(), c.Get("X-Payment-Signature"))
+	if err != nil {
+		if errors.Is(err, paymentService.ErrInvalidSignature) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid signature",
+			})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Payment not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error handling payment webhook", "error", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Could not process event",
+		})
+	}
+
+	if !processed {
+		return c.JSON(fiber.Map{"status": "duplicate"})
+	}
+	return c.JSON(fiber.Map{"status": "processed"})
+}
+
+func paymentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, paymentService.ErrInvalidRefund):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, paymentService.ErrInvalidPaymentState):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Order or payment not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error processing payment", "error", err)
+	return c.Status(http.StatusBadGateway).JSON(fiber.Map{
+		"error": "Payment provider error",
+	})
+}
@@ -1,12 +1,14 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
+	"go-api/controller/query"
 	"go-api/middleware"
 	"go-api/models"
-	categoryService "go-api/services/category"
+	currencyService "go-api/services/currency"
+	pricingService "go-api/services/pricing"
 	productService "go-api/services/product"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,27 +18,38 @@ import (
 )
 
 type ProductController struct {
-	ProductService  productService.ProductService
-	CategoryService categoryService.CategoryService
-	DB              *gorm.DB
+	ProductService productService.ProductService
+	PricingService pricingService.PricingService
 }
 
-func NewProductController(productService productService.ProductService, categoryService categoryService.CategoryService, db *gorm.DB) *ProductController {
+func NewProductController(productService productService.ProductService, pricingService pricingService.PricingService) *ProductController {
 	return &ProductController{
-		ProductService:  productService,
-		CategoryService: categoryService,
-		DB:              db,
+		ProductService: productService,
+		PricingService: pricingService,
 	}
 }
 
 // GetAllProducts godoc
 // @Summary      GetAllProducts
-// @Description  Returns all products
+// @Description  Returns a filtered, sorted page of products. Attributes are filtered with attr.<code>=a,b (any of the values) and attr.<code>.min / attr.<code>.max for numbers
 // @Tags         Products
 // @Accept       json
 // @Produce      json
-// @Param        Authorization  header    string  true  "Bearer {token}"
-// @Success      200  {array}   models.Product
+// @Param        Authorization  header    string  true   "Bearer {token}"
+// @Param        q              query     string  false  "Search in name and description"
+// @Param        category_id    query     int     false  "Category ID"
+// @Param        in_stock       query     bool    false  "Filter by stock availability"
+// @Param        active         query     bool    false  "Filter by active flag"
+// @Param        on_discount    query     bool    false  "Filter by discount"
+// @Param        min_price      query     number  false  "Minimum price, in the requested currency"
+// @Param        max_price      query     number  false  "Maximum price, in the requested currency"
+// @Param        created_after  query     string  false  "RFC3339 timestamp or YYYY-MM-DD"
+// @Param        sort           query     string  false  "Comma separated keys: price, name, newest, popularity, rating; prefix with - to reverse"
+// @Param        page           query     int     false  "Page number, starting at 1"
+// @Param        limit          query     int     false  "Page size, at most 100"
+// @Param        currency       query     string  false  "ISO 4217 currency to price in"
+// @Success      200  {object}  models.ProductPage
+// @Failure      400  {object}  map[string]string
 // @Router       /products [get]
 func (pc *ProductController) GetAllProducts(c *fiber.Ctx) error {
 
@@ -56,34 +69,64 @@ func (pc *ProductController) GetAllProducts(c *fiber.Ctx) error {
 		})
 	}
 
-	fmt.Println("User ID:", claims["id"])
+	slog.DebugContext(c.UserContext(), "Listing products", "user_id", claims["id"])
 
-	products, err := pc.ProductService.GetAllProducts()
+	listQuery, err := query.ParseProductListQuery(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
+	page, err := pc.ProductService.ListProducts(c.UserContext(), listQuery)
 	if err != nil {
-		log.Println("Error fetching products:", err)
+		if errors.Is(err, productService.ErrInvalidSort) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid sort, expected " + strings.Join(productService.SortKeys(), ", "),
+			})
+		}
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error fetching products", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not fetch products",
 		})
 	}
-	return c.Status(http.StatusOK).JSON(products)
+	if err := pc.PricingService.Localize(c.UserContext(), page.Data, query.Currency(c)); err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error localizing prices", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not convert prices",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(page)
 }
 
 // GetProductByID godoc
 // @Summary      GetProductByID
-// @Description  Returns a product by ID
+// @Description  Returns a product by ID. Inactive products are only returned to admins
 // @Tags         Products
 // @Accept       json
 // @Produce      json
-// @Param        Authorization  header    string              true  "Bearer {token}"
-// @Param        product        body      models.ProductCreateInput  true  "Ürün oluşturma verileri"
-// @Success      201  {object}  models.Product
-// @Router       /products [get]
+// @Param        id        path      string  true   "Product ID"
+// @Param        currency  query     string  false  "ISO 4217 currency to price in"
+// @Success      200  {object}  models.Product
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /products/{id} [get]
 func (pc *ProductController) GetProductByID(c *fiber.Ctx) error {
 
 	id := c.Params("id")
 
-	product, err := pc.ProductService.GetProductByID(id)
+	product, err := pc.ProductService.GetProductByID(c.UserContext(), id, query.Visibility(c))
 	if err != nil {
 
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -91,7 +134,20 @@ func (pc *ProductController) GetProductByID(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(product)
+	products := []models.Product{product}
+	if err := pc.PricingService.Localize(c.UserContext(), products, query.Currency(c)); err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error localizing prices", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not convert prices",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(products[0])
 }
 
 // CreateProduct godoc
@@ -103,41 +159,26 @@ func (pc *ProductController) GetProductByID(c *fiber.Ctx) error {
 // @Param        Authorization  header    string              true  "Bearer {token}"
 // @Param        product        body      models.ProductCreateInput  true  "Ürün oluşturma verileri"
 // @Success      201  {object}  models.Product
+// @Failure      400  {object}  map[string]string
 // @Router       /products [post]
 func (pc *ProductController) CreateProduct(c *fiber.Ctx) error {
 	var input models.ProductCreateInput
 
 	if err := c.BodyParser(&input); err != nil {
-		log.Println("Invalid request body:", err)
+		slog.ErrorContext(c.UserContext(), "Invalid request body", "error", err)
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
 
-	category, err := pc.CategoryService.GetCategoryByID(input.CategoryID)
+	product, err := pc.ProductService.CreateProduct(c.UserContext(), input)
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid category ID",
-		})
-	}
-
-	product := models.Product{
-		ID:            input.ID,
-		Name:          input.Name,
-		Description:   input.Description,
-		Price:         input.Price,
-		Quantity:      input.Quantity,
-		Image:         input.Image,
-		CategoryID:    input.CategoryID,
-		Category:      *category,
-		DiscountPrice: input.DiscountPrice,
-		IsActive:      input.IsActive,
-		Stock:         input.Stock,
-		SKU:           input.SKU,
-	}
-
-	if err := pc.DB.Create(&product).Error; err != nil {
-		log.Println("Error creating product:", err)
+		if errors.Is(err, productService.ErrInvalidProduct) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error creating product", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not create product",
 		})
@@ -156,6 +197,8 @@ func (pc *ProductController) CreateProduct(c *fiber.Ctx) error {
 // @Param        id             path      string                 true  "Ürün ID'si"
 // @Param        product        body      models.ProductUpdateInput  true  "Ürün güncelleme verileri"
 // @Success      200  {object}  models.Product
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
 // @Router       /products/{id} [put]
 func (pc *ProductController) UpdateProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -167,11 +210,21 @@ func (pc *ProductController) UpdateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	product, err := pc.ProductService.UpdateProduct(id, input)
+	product, err := pc.ProductService.UpdateProduct(c.UserContext(), id, input)
 	if err != nil {
-		log.Println("Error updating product:", err)
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
+		if errors.Is(err, productService.ErrInvalidProduct) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error updating product", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not update product",
 		})
 	}
 
@@ -191,8 +244,8 @@ func (pc *ProductController) UpdateProduct(c *fiber.Ctx) error {
 func (pc *ProductController) DeleteProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	if err := pc.ProductService.DeleteProduct(id); err != nil {
-		log.Println("Error deleting product:", err)
+	if err := pc.ProductService.DeleteProduct(c.UserContext(), id); err != nil {
+		slog.ErrorContext(c.UserContext(), "Error deleting product", "error", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not delete product",
 		})
@@ -210,9 +263,10 @@ func (pc *ProductController) DeleteProduct(c *fiber.Ctx) error {
 // @Accept       json
 // @Produce      json
 // @Param        Authorization  header    string  true   "Bearer {token}"
-// @Param        min_price       query     number  true   "Minimum price"
-// @Param        max_price       query     number  true   "Maximum price"
+// @Param        min_price       query     number  true   "Minimum price, in the requested currency"
+// @Param        max_price       query     number  true   "Maximum price, in the requested currency"
 // @Param        sort            query     string  false  "Sort order (asc or desc)"
+// @Param        currency        query     string  false  "ISO 4217 currency to price in"
 // @Success      200  {array}    models.Product
 // @Failure      400  {object}   map[string]string "Invalid request"
 // @Failure      500  {object}   map[string]string "Failed to fetch products"
@@ -228,33 +282,50 @@ func (pc *ProductController) GetProductsByPriceRange(c *fiber.Ctx) error {
 		})
 	}
 
-	min, err := strconv.ParseFloat(minPrice, 64)
+	min, err := models.ParseMoney(minPrice)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid min_price",
 		})
 	}
 
-	max, err := strconv.ParseFloat(maxPrice, 64)
+	max, err := models.ParseMoney(maxPrice)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid max_price",
 		})
 	}
 
-	products, err := pc.ProductService.GetProductsByPriceRange(min, max, sortOrder)
+	products, err := pc.ProductService.GetProductsByPriceRange(c.UserContext(), min, max, query.Currency(c), sortOrder, query.Visibility(c))
 	if err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch products",
 		})
 	}
 
+	if err := pc.PricingService.Localize(c.UserContext(), products, query.Currency(c)); err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error localizing prices", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not convert prices",
+		})
+	}
+
 	return c.JSON(products)
 }
 
 // UpdateProductStock godoc
 // @Summary      Update product stock
-// @Description  Updates the stock quantity of a product
+// @Description  Updates the stock quantity of a product. Products stocked in warehouses are updated per warehouse instead
 // @Tags         Products
 // @Accept       json
 // @Produce      json
@@ -262,6 +333,7 @@ func (pc *ProductController) GetProductsByPriceRange(c *fiber.Ctx) error {
 // @Param        stock body int    true "New Stock Quantity"
 // @Success      200  {object}  models.Product
 // @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
 // @Router       /products/{id}/stock [patch]
 func (pc *ProductController) UpdateProductStock(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -275,8 +347,13 @@ func (pc *ProductController) UpdateProductStock(c *fiber.Ctx) error {
 		})
 	}
 
-	product, err := pc.ProductService.UpdateProductStock(id, input.Stock)
+	product, err := pc.ProductService.UpdateProductStock(c.UserContext(), id, input.Stock)
 	if err != nil {
+		if errors.Is(err, productService.ErrStockManagedByWarehouses) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Stock of this product is managed per warehouse",
+			})
+		}
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Product not found",
 		})
@@ -305,7 +382,7 @@ func (pc *ProductController) BulkUpdatePrices(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := pc.ProductService.BulkUpdatePrices(priceUpdates); err != nil {
+	if err := pc.ProductService.BulkUpdatePrices(c.UserContext(), priceUpdates); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -316,26 +393,27 @@ func (pc *ProductController) BulkUpdatePrices(c *fiber.Ctx) error {
 
 // SearchProducts godoc
 // @Summary      Search products
-// @Description  Search for products by name, description, and price range
+// @Description  Search for products by name, description, price range and attr.<code> attribute filters
 // @Tags         Products
 // @Accept       json
 // @Produce      json
 // @Param        query      query string  false "Search query"
-// @Param        min_price  query number  false "Minimum price"
-// @Param        max_price  query number  false "Maximum price"
+// @Param        min_price  query number  false "Minimum price, in the requested currency"
+// @Param        max_price  query number  false "Maximum price, in the requested currency"
+// @Param        currency   query string  false "ISO 4217 currency to price in"
 // @Success      200  {array}  models.Product
 // @Failure      500  {object}  map[string]string "Failed to search products"
 // @Router       /products/search [get]
 func (pc *ProductController) SearchProducts(c *fiber.Ctx) error {
-	query := c.Query("query")
+	searchQuery := c.Query("query")
 	minPriceStr := c.Query("min_price")
 	maxPriceStr := c.Query("max_price")
 
-	var minPrice, maxPrice float64
+	var minPrice, maxPrice models.Money
 	var err error
 
 	if minPriceStr != "" {
-		minPrice, err = strconv.ParseFloat(minPriceStr, 64)
+		minPrice, err = models.ParseMoney(minPriceStr)
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid min_price",
@@ -344,7 +422,7 @@ func (pc *ProductController) SearchProducts(c *fiber.Ctx) error {
 	}
 
 	if maxPriceStr != "" {
-		maxPrice, err = strconv.ParseFloat(maxPriceStr, 64)
+		maxPrice, err = models.ParseMoney(maxPriceStr)
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid max_price",
@@ -352,12 +430,155 @@ func (pc *ProductController) SearchProducts(c *fiber.Ctx) error {
 		}
 	}
 
-	products, err := pc.ProductService.SearchProducts(query, minPrice, maxPrice)
+	attributes, err := query.ParseAttributeFilters(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	products, err := pc.ProductService.SearchProducts(c.UserContext(), searchQuery, minPrice, maxPrice, query.Currency(c), attributes, query.Visibility(c))
 	if err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to search products",
 		})
 	}
 
+	if err := pc.PricingService.Localize(c.UserContext(), products, query.Currency(c)); err != nil {
+		if errors.Is(err, currencyService.ErrUnsupportedCurrency) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error localizing prices", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not convert prices",
+		})
+	}
+
 	return c.JSON(products)
 }
+
+// GetProductPrices godoc
+// @Summary      List product price list
+// @Description  Returns the explicit per-currency prices of a product
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Product ID"
+// @Success      200  {array}   models.ProductPrice
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /products/{id}/prices [get]
+func (pc *ProductController) GetProductPrices(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	prices, err := pc.PricingService.GetPrices(c.UserContext(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error fetching product prices", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch product prices",
+		})
+	}
+
+	return c.JSON(prices)
+}
+
+// SetProductPrice godoc
+// @Summary      Set product price in a currency
+// @Description  Creates or replaces the explicit price of a product in the given currency
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header  string                    true  "Bearer {token}"
+// @Param        id             path    int                       true  "Product ID"
+// @Param        currency       path    string                    true  "ISO 4217 currency code"
+// @Param        price          body    models.ProductPriceInput  true  "Price in that currency"
+// @Success      200  {object}  models.ProductPrice
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/products/{id}/prices/{currency} [put]
+func (pc *ProductController) SetProductPrice(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	var input models.ProductPriceInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	price, err := pc.PricingService.SetPrice(c.UserContext(), uint(id), c.Params("currency"), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, currencyService.ErrUnsupportedCurrency):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Unsupported currency",
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Product not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error setting product price", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not set product price",
+		})
+	}
+
+	return c.JSON(price)
+}
+
+// DeleteProductPrice godoc
+// @Summary      Remove product price in a currency
+// @Description  Removes the explicit price so the converted price is used again
+// @Tags         Products
+// @Param        Authorization  header  string  true  "Bearer {token}"
+// @Param        id             path    int     true  "Product ID"
+// @Param        currency       path    string  true  "ISO 4217 currency code"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/products/{id}/prices/{currency} [delete]
+func (pc *ProductController) DeleteProductPrice(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	if err := pc.PricingService.DeletePrice(c.UserContext(), uint(id), c.Params("currency")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": "Product price not found",
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error deleting product price", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not delete product price",
+		})
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"errors"
+	"go-api/controller/shared"
+	"go-api/middleware"
+	"go-api/models"
+	cartService "go-api/services/cart"
+	promotionService "go-api/services/promotion"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type PromotionController struct {
+	PromotionService promotionService.PromotionService
+	CartService      cartService.CartService
+}
+
+func NewPromotionController(promotionService promotionService.PromotionService, cartService cartService.CartService) *PromotionController {
+	return &PromotionController{
+		PromotionService: promotionService,
+		CartService:      cartService,
+	}
+}
+
+// GetPromotions godoc
+// @Summary      List promotions
+// @Description  Returns all promotions ordered by priority
+// @Tags         Promotions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.Promotion
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/promotions [get]
+func (pc *PromotionController) GetPromotions(c *fiber.Ctx) error {
+	promotions, err := pc.PromotionService.GetPromotions(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching promotions", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch promotions",
+		})
+	}
+	return c.JSON(promotions)
+}
+
+// GetPromotionByID godoc
+// @Summary      Get promotion by ID
+// @Description  Returns a promotion with its product and category scope
+// @Tags         Promotions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Promotion ID"
+// @Success      200  {object}  models.Promotion
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/promotions/{id} [get]
+func (pc *PromotionController) GetPromotionByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid promotion ID",
+		})
+	}
+
+	promotion, err := pc.PromotionService.GetPromotionByID(c.UserContext(), uint(id))
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found",
+		})
+	}
+	return c.JSON(promotion)
+}
+
+// CreatePromotion godoc
+// @Summary      Create a promotion
+// @Description  Creates an automatic promotion, or a coupon when code is set
+// @Tags         Promotions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                 true  "Bearer {token}"
+// @Param        promotion      body      models.PromotionInput  true  "Promotion rule"
+// @Success      201  {object}  models.Promotion
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/promotions [post]
+func (pc *PromotionController) CreatePromotion(c *fiber.Ctx) error {
+	var input models.PromotionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	promotion, err := pc.PromotionService.CreatePromotion(c.UserContext(), input)
+	if err != nil {
+		return promotionError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(promotion)
+}
+
+// UpdatePromotion godoc
+// @Summary      Update a promotion
+// @Description  Replaces the rule, scope and limits of a promotion
+// @Tags         Promotions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                 true  "Bearer {token}"
+// @Param        id             path      int                    true  "Promotion ID"
+// @Param        promotion      body      models.PromotionInput  true  "Promotion rule"
+// @Success      200  {object}  models.Promotion
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/promotions/{id} [put]
+func (pc *PromotionController) UpdatePromotion(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid promotion ID",
+		})
+	}
+
+	var input models.PromotionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	promotion, err := pc.PromotionService.UpdatePromotion(c.UserContext(), uint(id), input)
+	if err != nil {
+		return promotionError(c, err)
+	}
+	return c.JSON(promotion)
+}
+
+// DeletePromotion godoc
+// @Summary      Delete a promotion
+// @Description  Soft deletes a promotion, past redemptions are kept
+// @Tags         Promotions
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Promotion ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/promotions/{id} [delete]
+func (pc *PromotionController) DeletePromotion(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid promotion ID",
+		})
+	}
+
+	if err := pc.PromotionService.DeletePromotion(c.UserContext(), uint(id)); err != nil {
+		return promotionError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// ValidateCart godoc
+// @Summary      Validate coupons against a cart
+// @Description  Prices the cart and evaluates automatic promotions and coupons without using them up
+// @Tags         Promotions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string            true  "Bearer {token}"
+// @Param        cart           body      models.CartInput  true  "Cart with coupon codes"
+// @Success      200  {object}  models.Cart
+// @Failure      400  {object}  map[string]string
+// @Router       /promotions/validate [post]
+func (pc *PromotionController) ValidateCart(c *fiber.Ctx) error {
+	var input models.CartInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	cart, err := pc.evaluate(c, input)
+	if err != nil {
+		return shared.CartError(c, err)
+	}
+
+	return c.JSON(cart)
+}
+
+// evaluate prices the cart and applies the promotions and coupons to it.
+func (pc *PromotionController) evaluate(c *fiber.Ctx, input models.CartInput) (models.Cart, error) {
+	cart, err := pc.CartService.Price(c.UserContext(), input)
+	if err != nil {
+		return models.Cart{}, err
+	}
+
+	if err := pc.PromotionService.Evaluate(c.UserContext(), &cart, middleware.UserID(c), input.Coupons); err != nil {
+		return models.Cart{}, err
+	}
+
+	return cart, nil
+}
+
+func promotionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, promotionService.ErrInvalidPromotion):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, promotionService.ErrDuplicateCode):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "Coupon code already exists",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Promotion not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving promotion", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save promotion",
+	})
+}
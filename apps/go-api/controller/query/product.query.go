@@ -0,0 +1,186 @@
+package query
+
+import (
+	"fmt"
+	"go-api/config"
+	"go-api/middleware"
+	"go-api/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Visibility hides inactive products from everyone but admins, and with
+// HIDE_OUT_OF_STOCK=true products without stock too.
+func Visibility(c *fiber.Ctx) models.ProductVisibility {
+	admin := middleware.HasRole(c, "ADMIN")
+	return models.ProductVisibility{
+		HideInactive:   !admin,
+		HideOutOfStock: !admin && config.Get("HIDE_OUT_OF_STOCK") == "true",
+	}
+}
+
+// ParseProductListQuery reads the product listing filters, sort keys and
+// pagination from the query string. Sort keys are validated by the service.
+func ParseProductListQuery(c *fiber.Ctx) (models.ProductListQuery, error) {
+	query := models.ProductListQuery{
+		ProductFilter: models.ProductFilter{
+			ProductVisibility: Visibility(c),
+			Search:            c.Query("q"),
+			Currency:          Currency(c),
+		},
+		Page:  c.QueryInt("page", 1),
+		Limit: c.QueryInt("limit", DefaultLimit),
+	}
+
+	if query.Page < 1 || query.Limit < 1 || query.Limit > MaxLimit {
+		return query, fmt.Errorf("page must be at least 1 and limit between 1 and %d", MaxLimit)
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		query.Sort = strings.Split(sort, ",")
+	}
+
+	var err error
+	filter := &query.ProductFilter
+
+	if filter.CategoryID, err = parseUint(c, "category_id"); err != nil {
+		return query, err
+	}
+	if filter.InStock, err = parseBool(c, "in_stock"); err != nil {
+		return query, err
+	}
+	if filter.Active, err = parseBool(c, "active"); err != nil {
+		return query, err
+	}
+	if filter.OnDiscount, err = parseBool(c, "on_discount"); err != nil {
+		return query, err
+	}
+	if filter.MinPrice, err = parseMoney(c, "min_price"); err != nil {
+		return query, err
+	}
+	if filter.MaxPrice, err = parseMoney(c, "max_price"); err != nil {
+		return query, err
+	}
+	if filter.CreatedAfter, err = parseTime(c, "created_after"); err != nil {
+		return query, err
+	}
+	if filter.Attributes, err = ParseAttributeFilters(c); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// ParseAttributeFilters reads attribute filters given as attr.<code>=a,b to
+// match any of the values, and attr.<code>.min / attr.<code>.max for number
+// ranges.
+func ParseAttributeFilters(c *fiber.Ctx) ([]models.AttributeFilter, error) {
+	byCode := map[string]*models.AttributeFilter{}
+	var codes []string
+
+	for key, raw := range c.Queries() {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok || raw == "" {
+			continue
+		}
+		code, bound, _ := strings.Cut(name, ".")
+		if code == "" {
+			return nil, fmt.Errorf("invalid %s", key)
+		}
+
+		filter, ok := byCode[code]
+		if !ok {
+			filter = &models.AttributeFilter{Code: code}
+			byCode[code] = filter
+			codes = append(codes, code)
+		}
+
+		switch bound {
+		case "":
+			filter.Values = strings.Split(raw, ",")
+		case "min", "max":
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", key)
+			}
+			if bound == "min" {
+				filter.Min = &value
+			} else {
+				filter.Max = &value
+			}
+		default:
+			return nil, fmt.Errorf("invalid %s, expected attr.<code>, attr.<code>.min or attr.<code>.max", key)
+		}
+	}
+
+	sort.Strings(codes)
+	filters := make([]models.AttributeFilter, 0, len(codes))
+	for _, code := range codes {
+		filters = append(filters, *byCode[code])
+	}
+	return filters, nil
+}
+
+func parseUint(c *fiber.Ctx, key string) (*uint, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	result := uint(value)
+	return &result, nil
+}
+
+func parseBool(c *fiber.Ctx, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &value, nil
+}
+
+func parseMoney(c *fiber.Ctx, key string) (*models.Money, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := models.ParseMoney(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &value, nil
+}
+
+func parseTime(c *fiber.Ctx, key string) (*time.Time, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if value, err := time.Parse(layout, raw); err == nil {
+			return &value, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s, expected RFC3339 or YYYY-MM-DD", key)
+}
+
+// Currency returns the ISO 4217 code requested with ?currency=, if any.
+func Currency(c *fiber.Ctx) string {
+	return strings.ToUpper(c.Query("currency"))
+}
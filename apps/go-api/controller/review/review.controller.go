@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"go-api/controller/query"
+	"go-api/middleware"
+	"go-api/models"
+	reviewService "go-api/services/review"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ReviewController struct {
+	ReviewService reviewService.ReviewService
+}
+
+func NewReviewController(reviewService reviewService.ReviewService) *ReviewController {
+	return &ReviewController{ReviewService: reviewService}
+}
+
+// GetProductReviews godoc
+// @Summary      List product reviews
+// @Description  Returns the approved reviews of a product, newest first
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        id     path   int  true   "Product ID"
+// @Param        page   query  int  false  "Page number (default 1)"
+// @Param        limit  query  int  false  "Page size (default 20, max 100)"
+// @Success      200  {object}  models.ReviewPage
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /products/{id}/reviews [get]
+func (rc *ReviewController) GetProductReviews(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", query.DefaultLimit)
+	if page < 1 || limit < 1 || limit > query.MaxLimit {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("page must be at least 1 and limit between 1 and %d", query.MaxLimit),
+		})
+	}
+
+	reviews, err := rc.ReviewService.GetProductReviews(c.UserContext(), uint(id), page, limit)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching reviews", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch reviews",
+		})
+	}
+	return c.JSON(reviews)
+}
+
+// CreateReview godoc
+// @Summary      Review a product
+// @Description  Rates a purchased product from 1 to 5. The review is published once a moderator approves it
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string              true  "Bearer {token}"
+// @Param        id             path      int                 true  "Product ID"
+// @Param        review         body      models.ReviewInput  true  "Review"
+// @Success      201  {object}  models.Review
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /products/{id}/reviews [post]
+func (rc *ReviewController) CreateReview(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	var input models.ReviewInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	review, err := rc.ReviewService.CreateReview(c.UserContext(), uint(id), middleware.UserID(c), input)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(review)
+}
+
+// GetReviewsForModeration godoc
+// @Summary      List reviews for moderation
+// @Description  Returns reviews with the given status, oldest first. Defaults to pending reviews
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true   "Bearer {token}"
+// @Param        status         query     string  false  "pending, approved or hidden"
+// @Success      200  {array}   models.Review
+// @Failure      500  {object}  map[string]string
+// @Router       /moderation/reviews [get]
+func (rc *ReviewController) GetReviewsForModeration(c *fiber.Ctx) error {
+	reviews, err := rc.ReviewService.GetReviewsByStatus(c.UserContext(), c.Query("status", models.ReviewPending))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching reviews", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch reviews",
+		})
+	}
+	return c.JSON(reviews)
+}
+
+// ModerateReview godoc
+// @Summary      Approve or hide a review
+// @Description  Publishes or hides a review and refreshes the product rating
+// @Tags         Reviews
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                    true  "Bearer {token}"
+// @Param        id             path      int                       true  "Review ID"
+// @Param        status         body      models.ReviewStatusInput  true  "approved or hidden"
+// @Success      200  {object}  models.Review
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /moderation/reviews/{id}/status [patch]
+func (rc *ReviewController) ModerateReview(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid review ID",
+		})
+	}
+
+	var input models.ReviewStatusInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	review, err := rc.ReviewService.SetStatus(c.UserContext(), uint(id), input.Status)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(review)
+}
+
+func reviewError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, reviewService.ErrInvalidReview):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, reviewService.ErrNotPurchased):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, reviewService.ErrAlreadyReviewed):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "You already reviewed this product",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Product or review not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving review", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save review",
+	})
+}
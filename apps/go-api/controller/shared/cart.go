@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"errors"
+	cartService "go-api/services/cart"
+	currencyService "go-api/services/currency"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CartError writes the response for an error returned while pricing or
+// evaluating a cart.
+func CartError(c *fiber.Ctx, err error) error {
+	var itemErr *cartService.CartItemError
+	switch {
+	case errors.Is(err, cartService.ErrEmptyCart):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cart is empty",
+		})
+	case errors.As(err, &itemErr):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":      itemErr.Err.Error(),
+			"product_id": itemErr.ProductID,
+		})
+	case errors.Is(err, currencyService.ErrUnsupportedCurrency):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Unsupported currency",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error evaluating cart", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not evaluate cart",
+	})
+}
@@ -0,0 +1,28 @@
+package shared
+
+import (
+	"errors"
+	shippingService "go-api/services/shipping"
+	taxService "go-api/services/tax"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CheckoutError extends CartError with the shipping and tax errors raised
+// while quoting or placing an order.
+func CheckoutError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, shippingService.ErrInvalidShipping),
+		errors.Is(err, taxService.ErrInvalidAddress):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, shippingService.ErrNoShippingZone),
+		errors.Is(err, shippingService.ErrMethodNotApplicable):
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return CartError(c, err)
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"errors"
+	"go-api/controller/shared"
+	"go-api/middleware"
+	"go-api/models"
+	cartService "go-api/services/cart"
+	promotionService "go-api/services/promotion"
+	shippingService "go-api/services/shipping"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ShippingController struct {
+	ShippingService  shippingService.ShippingService
+	CartService      cartService.CartService
+	PromotionService promotionService.PromotionService
+}
+
+func NewShippingController(shippingService shippingService.ShippingService, cartService cartService.CartService, promotionService promotionService.PromotionService) *ShippingController {
+	return &ShippingController{
+		ShippingService:  shippingService,
+		CartService:      cartService,
+		PromotionService: promotionService,
+	}
+}
+
+// QuoteShipping godoc
+// @Summary      Quote shipping for a cart
+// @Description  Prices the cart, applies promotions and returns the available shipping methods for the delivery address, cheapest first
+// @Tags         Shipping
+// @Accept       json
+// @Produce      json
+// @Param        checkout  body      models.CheckoutInput  true  "Cart and delivery address"
+// @Success      200  {array}   models.ShippingQuote
+// @Failure      400  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Router       /shipping/quote [post]
+func (sc *ShippingController) QuoteShipping(c *fiber.Ctx) error {
+	var input models.CheckoutInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	cart, err := sc.CartService.Price(c.UserContext(), input.CartInput)
+	if err != nil {
+		return shared.CartError(c, err)
+	}
+	if err := sc.PromotionService.Evaluate(c.UserContext(), &cart, middleware.UserID(c), input.Coupons); err != nil {
+		return shared.CartError(c, err)
+	}
+
+	quotes, err := sc.ShippingService.Quote(c.UserContext(), cart, input.Address)
+	if err != nil {
+		return shared.CheckoutError(c, err)
+	}
+	return c.JSON(quotes)
+}
+
+// GetShippingZones godoc
+// @Summary      List shipping zones
+// @Description  Returns all shipping zones with their methods
+// @Tags         Shipping
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.ShippingZone
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/shipping/zones [get]
+func (sc *ShippingController) GetShippingZones(c *fiber.Ctx) error {
+	zones, err := sc.ShippingService.GetZones(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching shipping zones", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch shipping zones",
+		})
+	}
+	return c.JSON(zones)
+}
+
+// CreateShippingZone godoc
+// @Summary      Create a shipping zone
+// @Description  Adds a zone covering a list of countries
+// @Tags         Shipping
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                    true  "Bearer {token}"
+// @Param        zone           body      models.ShippingZoneInput  true  "Shipping zone"
+// @Success      201  {object}  models.ShippingZone
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/shipping/zones [post]
+func (sc *ShippingController) CreateShippingZone(c *fiber.Ctx) error {
+	var input models.ShippingZoneInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	zone, err := sc.ShippingService.CreateZone(c.UserContext(), input)
+	if err != nil {
+		return shippingError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(zone)
+}
+
+// UpdateShippingZone godoc
+// @Summary      Update a shipping zone
+// @Description  Replaces the name and countries of a zone
+// @Tags         Shipping
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                    true  "Bearer {token}"
+// @Param        id             path      int                       true  "Zone ID"
+// @Param        zone           body      models.ShippingZoneInput  true  "Shipping zone"
+// @Success      200  {object}  models.ShippingZone
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/shipping/zones/{id} [put]
+func (sc *ShippingController) UpdateShippingZone(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid zone ID",
+		})
+	}
+
+	var input models.ShippingZoneInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	zone, err := sc.ShippingService.UpdateZone(c.UserContext(), uint(id), input)
+	if err != nil {
+		return shippingError(c, err)
+	}
+	return c.JSON(zone)
+}
+
+// DeleteShippingZone godoc
+// @Summary      Delete a shipping zone
+// @Description  Removes a zone and its methods
+// @Tags         Shipping
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Zone ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/shipping/zones/{id} [delete]
+func (sc *ShippingController) DeleteShippingZone(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid zone ID",
+		})
+	}
+
+	if err := sc.ShippingService.DeleteZone(c.UserContext(), uint(id)); err != nil {
+		return shippingError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// CreateShippingMethod godoc
+// @Summary      Create a shipping method
+// @Description  Adds a flat, weight based or free-over-threshold method to a zone. Amounts are in the base currency
+// @Tags         Shipping
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                      true  "Bearer {token}"
+// @Param        id             path      int                         true  "Zone ID"
+// @Param        method         body      models.ShippingMethodInput  true  "Shipping method"
+// @Success      201  {object}  models.ShippingMethod
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/shipping/zones/{id}/methods [post]
+func (sc *ShippingController) CreateShippingMethod(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid zone ID",
+		})
+	}
+
+	var input models.ShippingMethodInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	method, err := sc.ShippingService.CreateMethod(c.UserContext(), uint(id), input)
+	if err != nil {
+		return shippingError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(method)
+}
+
+// UpdateShippingMethod godoc
+// @Summary      Update a shipping method
+// @Description  Replaces a shipping method
+// @Tags         Shipping
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                      true  "Bearer {token}"
+// @Param        id             path      int                         true  "Method ID"
+// @Param        method         body      models.ShippingMethodInput  true  "Shipping method"
+// @Success      200  {object}  models.ShippingMethod
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/shipping/methods/{id} [put]
+func (sc *ShippingController) UpdateShippingMethod(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid method ID",
+		})
+	}
+
+	var input models.ShippingMethodInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	method, err := sc.ShippingService.UpdateMethod(c.UserContext(), uint(id), input)
+	if err != nil {
+		return shippingError(c, err)
+	}
+	return c.JSON(method)
+}
+
+// DeleteShippingMethod godoc
+// @Summary      Delete a shipping method
+// @Description  Removes a shipping method
+// @Tags         Shipping
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Method ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/shipping/methods/{id} [delete]
+func (sc *ShippingController) DeleteShippingMethod(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid method ID",
+		})
+	}
+
+	if err := sc.ShippingService.DeleteMethod(c.UserContext(), uint(id)); err != nil {
+		return shippingError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func shippingError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, shippingService.ErrInvalidShipping):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Shipping zone or method not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving shipping configuration", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save shipping configuration",
+	})
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"errors"
+	"go-api/middleware"
+	"go-api/models"
+	subscriptionService "go-api/services/subscription"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SubscriptionController struct {
+	SubscriptionService subscriptionService.SubscriptionService
+}
+
+func NewSubscriptionController(subscriptionService subscriptionService.SubscriptionService) *SubscriptionController {
+	return &SubscriptionController{SubscriptionService: subscriptionService}
+}
+
+// GetSubscriptions godoc
+// @Summary      List my product subscriptions
+// @Description  Returns the back-in-stock and price-drop subscriptions of the authenticated user
+// @Tags         Subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.ProductSubscription
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions [get]
+func (sc *SubscriptionController) GetSubscriptions(c *fiber.Ctx) error {
+	subscriptions, err := sc.SubscriptionService.GetSubscriptions(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching subscriptions", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch subscriptions",
+		})
+	}
+	return c.JSON(subscriptions)
+}
+
+// Subscribe godoc
+// @Summary      Subscribe to a product
+// @Description  Sends one notification when the product comes back in stock (back_in_stock) or its price falls to target_price or below (price_drop), compared in target_currency, the product currency by default. Subscribing again re-arms a notified subscription
+// @Tags         Subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                           true  "Bearer {token}"
+// @Param        id             path      int                              true  "Product ID"
+// @Param        subscription   body      models.ProductSubscriptionInput  true  "Subscription"
+// @Success      200  {object}  models.ProductSubscription
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /products/{id}/subscriptions [post]
+func (sc *SubscriptionController) Subscribe(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	var input models.ProductSubscriptionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	subscription, err := sc.SubscriptionService.Subscribe(c.UserContext(), uint(id), middleware.UserID(c), input)
+	if err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// Unsubscribe godoc
+// @Summary      Delete a product subscription
+// @Description  Removes a subscription of the authenticated user
+// @Tags         Subscriptions
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Subscription ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /subscriptions/{id} [delete]
+func (sc *SubscriptionController) Unsubscribe(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subscription ID",
+		})
+	}
+
+	if err := sc.SubscriptionService.Unsubscribe(c.UserContext(), uint(id), middleware.UserID(c)); err != nil {
+		return subscriptionError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func subscriptionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, subscriptionService.ErrInvalidSubscription):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Product or subscription not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving subscription", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save subscription",
+	})
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"errors"
+	"go-api/controller/shared"
+	"go-api/middleware"
+	"go-api/models"
+	cartService "go-api/services/cart"
+	promotionService "go-api/services/promotion"
+	taxService "go-api/services/tax"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type TaxController struct {
+	TaxService       taxService.TaxService
+	CartService      cartService.CartService
+	PromotionService promotionService.PromotionService
+}
+
+func NewTaxController(taxService taxService.TaxService, cartService cartService.CartService, promotionService promotionService.PromotionService) *TaxController {
+	return &TaxController{
+		TaxService:       taxService,
+		CartService:      cartService,
+		PromotionService: promotionService,
+	}
+}
+
+// CalculateTax godoc
+// @Summary      Calculate tax for a cart
+// @Description  Prices the cart, applies promotions and returns the tax breakdown per line for the delivery address
+// @Tags         Tax
+// @Accept       json
+// @Produce      json
+// @Param        checkout  body      models.CheckoutInput  true  "Cart and delivery address"
+// @Success      200  {object}  models.TaxQuote
+// @Failure      400  {object}  map[string]string
+// @Router       /tax/calculate [post]
+func (tc *TaxController) CalculateTax(c *fiber.Ctx) error {
+	var input models.CheckoutInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	cart, err := tc.CartService.Price(c.UserContext(), input.CartInput)
+	if err != nil {
+		return shared.CartError(c, err)
+	}
+	if err := tc.PromotionService.Evaluate(c.UserContext(), &cart, middleware.UserID(c), input.Coupons); err != nil {
+		return shared.CartError(c, err)
+	}
+
+	breakdown, err := tc.TaxService.Calculate(c.UserContext(), cart, input.Address)
+	if err != nil {
+		if errors.Is(err, taxService.ErrInvalidAddress) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		slog.ErrorContext(c.UserContext(), "Error calculating tax", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not calculate tax",
+		})
+	}
+
+	return c.JSON(models.TaxQuote{Cart: cart, Tax: breakdown})
+}
+
+// GetTaxRules godoc
+// @Summary      List tax rules
+// @Description  Returns all tax rules ordered by country, region and tax class
+// @Tags         Tax
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.TaxRule
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/tax-rules [get]
+func (tc *TaxController) GetTaxRules(c *fiber.Ctx) error {
+	rules, err := tc.TaxService.GetRules(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching tax rules", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch tax rules",
+		})
+	}
+	return c.JSON(rules)
+}
+
+// CreateTaxRule godoc
+// @Summary      Create a tax rule
+// @Description  Adds a rate for a tax class in a country or region
+// @Tags         Tax
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string               true  "Bearer {token}"
+// @Param        rule           body      models.TaxRuleInput  true  "Tax rule"
+// @Success      201  {object}  models.TaxRule
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/tax-rules [post]
+func (tc *TaxController) CreateTaxRule(c *fiber.Ctx) error {
+	var input models.TaxRuleInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	rule, err := tc.TaxService.CreateRule(c.UserContext(), input)
+	if err != nil {
+		return taxRuleError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(rule)
+}
+
+// UpdateTaxRule godoc
+// @Summary      Update a tax rule
+// @Description  Replaces a tax rule
+// @Tags         Tax
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string               true  "Bearer {token}"
+// @Param        id             path      int                  true  "Tax rule ID"
+// @Param        rule           body      models.TaxRuleInput  true  "Tax rule"
+// @Success      200  {object}  models.TaxRule
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/tax-rules/{id} [put]
+func (tc *TaxController) UpdateTaxRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tax rule ID",
+		})
+	}
+
+	var input models.TaxRuleInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	rule, err := tc.TaxService.UpdateRule(c.UserContext(), uint(id), input)
+	if err != nil {
+		return taxRuleError(c, err)
+	}
+	return c.JSON(rule)
+}
+
+// DeleteTaxRule godoc
+// @Summary      Delete a tax rule
+// @Description  Removes a tax rule
+// @Tags         Tax
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Tax rule ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/tax-rules/{id} [delete]
+func (tc *TaxController) DeleteTaxRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tax rule ID",
+		})
+	}
+
+	if err := tc.TaxService.DeleteRule(c.UserContext(), uint(id)); err != nil {
+		return taxRuleError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func taxRuleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, taxService.ErrInvalidTaxRule):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Tax rule not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving tax rule", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save tax rule",
+	})
+}
@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"errors"
+	"go-api/models"
+	warehouseService "go-api/services/warehouse"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type WarehouseController struct {
+	WarehouseService warehouseService.WarehouseService
+}
+
+func NewWarehouseController(warehouseService warehouseService.WarehouseService) *WarehouseController {
+	return &WarehouseController{WarehouseService: warehouseService}
+}
+
+// GetWarehouses godoc
+// @Summary      List warehouses
+// @Description  Returns all warehouses ordered by allocation priority
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false "Bearer {token}"
+// @Param        X-API-Key      header    string  false "API key with the scope of the route"
+// @Success      200  {array}   models.Warehouse
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/warehouses [get]
+func (wc *WarehouseController) GetWarehouses(c *fiber.Ctx) error {
+	warehouses, err := wc.WarehouseService.GetWarehouses(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching warehouses", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch warehouses",
+		})
+	}
+	return c.JSON(warehouses)
+}
+
+// CreateWarehouse godoc
+// @Summary      Create a warehouse
+// @Description  Adds a warehouse. Lower priority values are allocated first
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                 true  "Bearer {token}"
+// @Param        warehouse      body      models.WarehouseInput  true  "Warehouse"
+// @Success      201  {object}  models.Warehouse
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/warehouses [post]
+func (wc *WarehouseController) CreateWarehouse(c *fiber.Ctx) error {
+	var input models.WarehouseInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	warehouse, err := wc.WarehouseService.CreateWarehouse(c.UserContext(), input)
+	if err != nil {
+		return warehouseError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(warehouse)
+}
+
+// UpdateWarehouse godoc
+// @Summary      Update a warehouse
+// @Description  Replaces a warehouse. Disabling it removes its stock from the available stock of its products
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                 true  "Bearer {token}"
+// @Param        id             path      int                    true  "Warehouse ID"
+// @Param        warehouse      body      models.WarehouseInput  true  "Warehouse"
+// @Success      200  {object}  models.Warehouse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/warehouses/{id} [put]
+func (wc *WarehouseController) UpdateWarehouse(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid warehouse ID",
+		})
+	}
+
+	var input models.WarehouseInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	warehouse, err := wc.WarehouseService.UpdateWarehouse(c.UserContext(), uint(id), input)
+	if err != nil {
+		return warehouseError(c, err)
+	}
+	return c.JSON(warehouse)
+}
+
+// DeleteWarehouse godoc
+// @Summary      Delete a warehouse
+// @Description  Deletes a warehouse that no longer holds stock
+// @Tags         Warehouses
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Warehouse ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/warehouses/{id} [delete]
+func (wc *WarehouseController) DeleteWarehouse(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid warehouse ID",
+		})
+	}
+
+	if err := wc.WarehouseService.DeleteWarehouse(c.UserContext(), uint(id)); err != nil {
+		return warehouseError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetWarehouseStock godoc
+// @Summary      List the stock of a warehouse
+// @Description  Returns the stock level of every product held in a warehouse
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false "Bearer {token}"
+// @Param        X-API-Key      header    string  false "API key with the scope of the route"
+// @Param        id             path      int     true  "Warehouse ID"
+// @Success      200  {array}   models.WarehouseStock
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/warehouses/{id}/stock [get]
+func (wc *WarehouseController) GetWarehouseStock(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid warehouse ID",
+		})
+	}
+
+	levels, err := wc.WarehouseService.GetWarehouseStock(c.UserContext(), uint(id))
+	if err != nil {
+		return warehouseError(c, err)
+	}
+	return c.JSON(levels)
+}
+
+// SetWarehouseStock godoc
+// @Summary      Set the stock of a product in a warehouse
+// @Description  Sets the counted stock of a product in a warehouse. From then on the product stock is the sum of its levels in active warehouses
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                      false "Bearer {token}"
+// @Param        X-API-Key      header    string                      false "API key with the scope of the route"
+// @Param        id             path      int                         true  "Warehouse ID"
+// @Param        productId      path      int                         true  "Product ID"
+// @Param        stock          body      models.WarehouseStockInput  true  "Quantity"
+// @Success      200  {object}  models.WarehouseStock
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/warehouses/{id}/stock/{productId} [put]
+func (wc *WarehouseController) SetWarehouseStock(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid warehouse ID",
+		})
+	}
+	productID, err := strconv.ParseUint(c.Params("productId"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	var input models.WarehouseStockInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	level, err := wc.WarehouseService.SetStock(c.UserContext(), uint(id), uint(productID), input.Quantity)
+	if err != nil {
+		return warehouseError(c, err)
+	}
+	return c.JSON(level)
+}
+
+// GetProductStock godoc
+// @Summary      Get the stock of a product per warehouse
+// @Description  Returns the available stock of a product and its level in every warehouse
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false "Bearer {token}"
+// @Param        X-API-Key      header    string  false "API key with the scope of the route"
+// @Param        id             path      int     true  "Product ID"
+// @Success      200  {object}  models.ProductStock
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/products/{id}/stock [get]
+func (wc *WarehouseController) GetProductStock(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	stock, err := wc.WarehouseService.GetProductStock(c.UserContext(), uint(id))
+	if err != nil {
+		return warehouseError(c, err)
+	}
+	return c.JSON(stock)
+}
+
+// CreateStockTransfer godoc
+// @Summary      Transfer stock between warehouses
+// @Description  Moves stock of a product from one warehouse to another
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                     false "Bearer {token}"
+// @Param        X-API-Key      header    string                     false "API key with the scope of the route"
+// @Param        transfer       body      models.StockTransferInput  true  "Transfer"
+// @Success      201  {object}  models.StockTransfer
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/stock-transfers [post]
+func (wc *WarehouseController) CreateStockTransfer(c *fiber.Ctx) error {
+	var input models.StockTransferInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	transfer, err := wc.WarehouseService.Transfer(c.UserContext(), input)
+	if err != nil {
+		return warehouseError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(transfer)
+}
+
+// GetStockTransfers godoc
+// @Summary      List stock transfers
+// @Description  Returns stock transfers, newest first, optionally for one product
+// @Tags         Warehouses
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  false  "Bearer {token}"
+// @Param        X-API-Key      header    string  false  "API key with the scope of the route"
+// @Param        product_id     query     int     false  "Product ID"
+// @Success      200  {array}   models.StockTransfer
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/stock-transfers [get]
+func (wc *WarehouseController) GetStockTransfers(c *fiber.Ctx) error {
+	transfers, err := wc.WarehouseService.GetTransfers(c.UserContext(), uint(c.QueryInt("product_id")))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching stock transfers", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch stock transfers",
+		})
+	}
+	return c.JSON(transfers)
+}
+
+func warehouseError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, warehouseService.ErrInvalidWarehouse),
+		errors.Is(err, warehouseService.ErrInvalidTransfer):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, warehouseService.ErrWarehouseNotEmpty):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Warehouse or product not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving warehouse stock", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save warehouse stock",
+	})
+}
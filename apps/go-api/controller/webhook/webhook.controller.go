@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"errors"
+	"go-api/models"
+	webhookService "go-api/services/webhook"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type WebhookController struct {
+	WebhookService webhookService.WebhookService
+}
+
+func NewWebhookController(webhookService webhookService.WebhookService) *WebhookController {
+	return &WebhookController{WebhookService: webhookService}
+}
+
+// GetSubscriptions godoc
+// @Summary      List webhook subscriptions
+// @Description  Returns every webhook subscription. Secrets are never returned
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.WebhookSubscription
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/webhooks [get]
+func (wc *WebhookController) GetSubscriptions(c *fiber.Ctx) error {
+	subscriptions, err := wc.WebhookService.GetSubscriptions(c.UserContext())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching webhook subscriptions", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch webhook subscriptions",
+		})
+	}
+	return c.JSON(subscriptions)
+}
+
+// GetSubscription godoc
+// @Summary      Get a webhook subscription
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Subscription ID"
+// @Success      200  {object}  models.WebhookSubscription
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id} [get]
+func (wc *WebhookController) GetSubscription(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subscription ID",
+		})
+	}
+
+	subscription, err := wc.WebhookService.GetSubscription(c.UserContext(), uint(id))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// CreateSubscription godoc
+// @Summary      Create a webhook subscription
+// @Description  Subscribes a partner URL to a comma separated list of product.created, product.updated, product.deleted, stock.changed, order.created and order.status_changed, or * for all of them. Deliveries are POSTed as JSON and signed in the X-Webhook-Signature header with the hex HMAC-SHA256 of the X-Webhook-Timestamp value, a dot and the body. The secret is generated when omitted and only returned in this response
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                           true  "Bearer {token}"
+// @Param        subscription   body      models.WebhookSubscriptionInput  true  "Subscription"
+// @Success      201  {object}  models.NewWebhookSubscription
+// @Failure      400  {object}  map[string]string
+// @Router       /admin/webhooks [post]
+func (wc *WebhookController) CreateSubscription(c *fiber.Ctx) error {
+	var input models.WebhookSubscriptionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	subscription, err := wc.WebhookService.CreateSubscription(c.UserContext(), input)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(subscription)
+}
+
+// UpdateSubscription godoc
+// @Summary      Update a webhook subscription
+// @Description  Replaces a subscription. A new secret rotates the current one and is returned once, an empty secret keeps it
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                           true  "Bearer {token}"
+// @Param        id             path      int                              true  "Subscription ID"
+// @Param        subscription   body      models.WebhookSubscriptionInput  true  "Subscription"
+// @Success      200  {object}  models.NewWebhookSubscription
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id} [put]
+func (wc *WebhookController) UpdateSubscription(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subscription ID",
+		})
+	}
+
+	var input models.WebhookSubscriptionInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	subscription, err := wc.WebhookService.UpdateSubscription(c.UserContext(), uint(id), input)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// DeleteSubscription godoc
+// @Summary      Delete a webhook subscription
+// @Description  Stops a subscription. Its pending deliveries fail, the delivery log is kept
+// @Tags         Webhooks
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Subscription ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id} [delete]
+func (wc *WebhookController) DeleteSubscription(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subscription ID",
+		})
+	}
+
+	if err := wc.WebhookService.DeleteSubscription(c.UserContext(), uint(id)); err != nil {
+		return webhookError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary      List the deliveries of a webhook subscription
+// @Description  Returns the latest 100 deliveries of a subscription, newest first, with the outcome of their last attempt
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true   "Bearer {token}"
+// @Param        id             path      int     true   "Subscription ID"
+// @Param        status         query     string  false  "pending, succeeded or failed"
+// @Success      200  {array}   models.WebhookDelivery
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/webhooks/{id}/deliveries [get]
+func (wc *WebhookController) GetDeliveries(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid subscription ID",
+		})
+	}
+
+	deliveries, err := wc.WebhookService.GetDeliveries(c.UserContext(), uint(id), c.Query("status"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(deliveries)
+}
+
+// ReplayDelivery godoc
+// @Summary      Replay a webhook delivery
+// @Description  Queues a finished delivery again with the same event ID and body. The original delivery stays in the log
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Delivery ID"
+// @Success      202  {object}  models.WebhookDelivery
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/webhook-deliveries/{id}/replay [post]
+func (wc *WebhookController) ReplayDelivery(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid delivery ID",
+		})
+	}
+
+	delivery, err := wc.WebhookService.Replay(c.UserContext(), uint(id))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.Status(http.StatusAccepted).JSON(delivery)
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, webhookService.ErrInvalidWebhook):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, webhookService.ErrDeliveryPending):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Webhook subscription or delivery not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving webhook subscription", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save webhook subscription",
+	})
+}
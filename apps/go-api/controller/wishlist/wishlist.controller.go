@@ -0,0 +1,313 @@
+package controller
+
+import (
+	"errors"
+	"go-api/middleware"
+	"go-api/models"
+	wishlistService "go-api/services/wishlist"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type WishlistController struct {
+	WishlistService wishlistService.WishlistService
+}
+
+func NewWishlistController(wishlistService wishlistService.WishlistService) *WishlistController {
+	return &WishlistController{WishlistService: wishlistService}
+}
+
+// GetWishlists godoc
+// @Summary      List my wishlists
+// @Description  Returns the wishlists of the authenticated user without their items
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Success      200  {array}   models.Wishlist
+// @Failure      500  {object}  map[string]string
+// @Router       /wishlists [get]
+func (wc *WishlistController) GetWishlists(c *fiber.Ctx) error {
+	wishlists, err := wc.WishlistService.GetWishlists(c.UserContext(), middleware.UserID(c))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error fetching wishlists", "error", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch wishlists",
+		})
+	}
+	return c.JSON(wishlists)
+}
+
+// CreateWishlist godoc
+// @Summary      Create a wishlist
+// @Description  Creates a named list for the authenticated user
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                true  "Bearer {token}"
+// @Param        wishlist       body      models.WishlistInput  true  "Wishlist"
+// @Success      201  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Router       /wishlists [post]
+func (wc *WishlistController) CreateWishlist(c *fiber.Ctx) error {
+	var input models.WishlistInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.CreateWishlist(c.UserContext(), middleware.UserID(c), input)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(wishlist)
+}
+
+// GetWishlistByID godoc
+// @Summary      Get one of my wishlists
+// @Description  Returns a wishlist of the authenticated user with its products
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Wishlist ID"
+// @Success      200  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id} [get]
+func (wc *WishlistController) GetWishlistByID(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.GetWishlist(c.UserContext(), uint(id), middleware.UserID(c))
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+// RenameWishlist godoc
+// @Summary      Rename a wishlist
+// @Description  Changes the name of a wishlist of the authenticated user
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                true  "Bearer {token}"
+// @Param        id             path      int                   true  "Wishlist ID"
+// @Param        wishlist       body      models.WishlistInput  true  "Wishlist"
+// @Success      200  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id} [put]
+func (wc *WishlistController) RenameWishlist(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+
+	var input models.WishlistInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.RenameWishlist(c.UserContext(), uint(id), middleware.UserID(c), input)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+// DeleteWishlist godoc
+// @Summary      Delete a wishlist
+// @Description  Deletes a wishlist of the authenticated user and its items
+// @Tags         Wishlists
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Wishlist ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id} [delete]
+func (wc *WishlistController) DeleteWishlist(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+
+	if err := wc.WishlistService.DeleteWishlist(c.UserContext(), uint(id), middleware.UserID(c)); err != nil {
+		return wishlistError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// AddWishlistItem godoc
+// @Summary      Add a product to a wishlist
+// @Description  Saves a product to a wishlist. The owner is notified when it goes on discount or comes back in stock
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string                    true  "Bearer {token}"
+// @Param        id             path      int                       true  "Wishlist ID"
+// @Param        item           body      models.WishlistItemInput  true  "Product"
+// @Success      200  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id}/items [post]
+func (wc *WishlistController) AddWishlistItem(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+
+	var input models.WishlistItemInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.AddItem(c.UserContext(), uint(id), middleware.UserID(c), input)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+// RemoveWishlistItem godoc
+// @Summary      Remove a product from a wishlist
+// @Description  Removes a product from a wishlist of the authenticated user
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Wishlist ID"
+// @Param        productId      path      int     true  "Product ID"
+// @Success      200  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id}/items/{productId} [delete]
+func (wc *WishlistController) RemoveWishlistItem(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+	productID, err := strconv.ParseUint(c.Params("productId"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.RemoveItem(c.UserContext(), uint(id), middleware.UserID(c), uint(productID))
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+// ShareWishlist godoc
+// @Summary      Share a wishlist
+// @Description  Gives the wishlist a share token that lets anyone view it
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Wishlist ID"
+// @Success      200  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id}/share [post]
+func (wc *WishlistController) ShareWishlist(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.Share(c.UserContext(), uint(id), middleware.UserID(c))
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+// UnshareWishlist godoc
+// @Summary      Stop sharing a wishlist
+// @Description  Revokes the share token of a wishlist
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        Authorization  header    string  true  "Bearer {token}"
+// @Param        id             path      int     true  "Wishlist ID"
+// @Success      200  {object}  models.Wishlist
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /wishlists/{id}/share [delete]
+func (wc *WishlistController) UnshareWishlist(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid wishlist ID",
+		})
+	}
+
+	wishlist, err := wc.WishlistService.Unshare(c.UserContext(), uint(id), middleware.UserID(c))
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+// GetSharedWishlist godoc
+// @Summary      View a shared wishlist
+// @Description  Returns a wishlist by its share token
+// @Tags         Wishlists
+// @Accept       json
+// @Produce      json
+// @Param        token  path      string  true  "Share token"
+// @Success      200  {object}  models.Wishlist
+// @Failure      404  {object}  map[string]string
+// @Router       /shared/wishlists/{token} [get]
+func (wc *WishlistController) GetSharedWishlist(c *fiber.Ctx) error {
+	wishlist, err := wc.WishlistService.GetSharedWishlist(c.UserContext(), c.Params("token"))
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(wishlist)
+}
+
+func wishlistError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, wishlistService.ErrInvalidWishlist):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Wishlist or product not found",
+		})
+	}
+	slog.ErrorContext(c.UserContext(), "Error saving wishlist", "error", err)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Could not save wishlist",
+	})
+}
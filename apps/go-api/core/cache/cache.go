@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUnknownDriver = errors.New("unknown cache driver")
+
+// Key prefixes of the cached reads. Writes drop whole prefixes, so every
+// key of a namespace has to start with it.
+const (
+	ProductsNamespace   = "products:"
+	CategoriesNamespace = "categories:"
+)
+
+// Cache stores serialized values for a limited time. It is only an
+// optimisation: backends log their failures and report a miss instead of
+// returning errors.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(keys ...string)
+	DeletePrefix(prefix string)
+}
+
+type Config struct {
+	Driver     string
+	RedisURL   string
+	MaxEntries int
+}
+
+// New returns the cache selected by cfg.Driver. The in-memory cache is
+// private to the replica, when a Redis URL is configured its deletions are
+// broadcast so the other replicas drop the same entries.
+func New(cfg Config) (Cache, error) {
+	var client *redis.Client
+	if cfg.RedisURL != "" {
+		options, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		client = redis.NewClient(options)
+	}
+
+	switch strings.ToLower(cfg.Driver) {
+	case "memory":
+		local := NewMemoryCache(cfg.MaxEntries)
+		if client == nil {
+			return local, nil
+		}
+		return NewReplicated(local, client, InvalidationChannel), nil
+	case "redis":
+		if client == nil {
+			return nil, errors.New("the redis cache needs REDIS_URL")
+		}
+		return NewRedisCache(client, "go-api:"), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, cfg.Driver)
+	}
+}
+
+// GetJSON decodes the value cached under key into target and reports
+// whether it was found. Entries that no longer decode count as misses.
+func GetJSON(c Cache, key string, target interface{}) bool {
+	value, ok := c.Get(key)
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(value, target) == nil
+}
+
+// SetJSON caches value encoded as JSON.
+func SetJSON(c Cache, key string, value interface{}, ttl time.Duration) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		slog.Warn("Error encoding cache value", "error", err)
+		return
+	}
+	c.Set(key, encoded, ttl)
+}
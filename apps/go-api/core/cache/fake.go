@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeCache is an in-memory Cache without expiry or eviction that records
+// how it was used, for tests of cached services.
+type FakeCache struct {
+	mu      sync.Mutex
+	Values  map[string][]byte
+	Hits    int
+	Misses  int
+	Deleted []string
+}
+
+func NewFakeCache() *FakeCache {
+	return &FakeCache{Values: map[string][]byte{}}
+}
+
+func (c *FakeCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.Values[key]
+	if ok {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+	return value, ok
+}
+
+func (c *FakeCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Values[key] = value
+}
+
+func (c *FakeCache) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.Values, key)
+		c.Deleted = append(c.Deleted, key)
+	}
+}
+
+// DeletePrefix records the prefix followed by "*" in Deleted.
+func (c *FakeCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.Values {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.Values, key)
+		}
+	}
+	c.Deleted = append(c.Deleted, prefix+"*")
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds every cache call, a slow Redis must not slow down
+// requests more than a miss would.
+const redisTimeout = 200 * time.Millisecond
+
+// RedisCache keeps values in Redis, shared by every replica. Keys are
+// stored under Prefix.
+type RedisCache struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func NewRedisCache(client *redis.Client, prefix string) *RedisCache {
+	return &RedisCache{Client: client, Prefix: prefix}
+}
+
+func (c *RedisCache) Get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := c.Client.Get(ctx, c.Prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.Warn("Error reading cache", "error", err)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *RedisCache) Set(key string, value []byte, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.Client.Set(ctx, c.Prefix+key, value, ttl).Err(); err != nil {
+		slog.Warn("Error writing cache", "error", err)
+	}
+}
+
+func (c *RedisCache) Delete(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.Prefix + key
+	}
+	if err := c.Client.Del(ctx, prefixed...).Err(); err != nil {
+		slog.Warn("Error deleting cache keys", "error", err)
+	}
+}
+
+// DeletePrefix scans for the matching keys and deletes them in batches.
+func (c *RedisCache) DeletePrefix(prefix string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	iter := c.Client.Scan(ctx, 0, c.Prefix+prefix+"*", 500).Iterator()
+	var batch []string
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == 500 {
+			if err := c.Client.Del(ctx, batch...).Err(); err != nil {
+				slog.Warn("Error deleting cache keys", "error", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		slog.Warn("Error scanning cache keys", "error", err)
+	}
+	if len(batch) > 0 {
+		if err := c.Client.Del(ctx, batch...).Err(); err != nil {
+			slog.Warn("Error deleting cache keys", "error", err)
+		}
+	}
+}
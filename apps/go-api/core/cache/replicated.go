@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationChannel is the Redis pub/sub channel replicas announce their
+// deletions on.
+const InvalidationChannel = "go-api:cache:invalidate"
+
+type invalidation struct {
+	Origin   string   `json:"origin"`
+	Keys     []string `json:"keys,omitempty"`
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// Replicated wraps the private cache of a replica. Its deletions are
+// published on Redis and the deletions published by other replicas are
+// applied to it, so a write on one replica is not served stale by another.
+type Replicated struct {
+	Cache
+	client  *redis.Client
+	channel string
+	origin  string
+}
+
+func NewReplicated(local Cache, client *redis.Client, channel string) *Replicated {
+	origin := make([]byte, 8)
+	rand.Read(origin)
+
+	r := &Replicated{
+		Cache:   local,
+		client:  client,
+		channel: channel,
+		origin:  hex.EncodeToString(origin),
+	}
+	go r.listen()
+	return r
+}
+
+func (r *Replicated) Delete(keys ...string) {
+	r.Cache.Delete(keys...)
+	r.publish(invalidation{Keys: keys})
+}
+
+func (r *Replicated) DeletePrefix(prefix string) {
+	r.Cache.DeletePrefix(prefix)
+	r.publish(invalidation{Prefixes: []string{prefix}})
+}
+
+func (r *Replicated) publish(message invalidation) {
+	message.Origin = r.origin
+	payload, err := json.Marshal(message)
+	if err != nil {
+		slog.Warn("Error encoding cache invalidation", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Publish(ctx, r.channel, payload).Err(); err != nil {
+		slog.Warn("Error publishing cache invalidation", "error", err)
+	}
+}
+
+// listen applies the invalidations of the other replicas. The subscription
+// reconnects on its own when Redis goes away.
+func (r *Replicated) listen() {
+	subscription := r.client.Subscribe(context.Background(), r.channel)
+	for msg := range subscription.Channel() {
+		var message invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+			slog.Warn("Error decoding cache invalidation", "error", err)
+			continue
+		}
+		if message.Origin == r.origin {
+			continue
+		}
+		if len(message.Keys) > 0 {
+			r.Cache.Delete(message.Keys...)
+		}
+		for _, prefix := range message.Prefixes {
+			r.Cache.DeletePrefix(prefix)
+		}
+	}
+}
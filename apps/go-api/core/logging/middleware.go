@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits the ids accepted from clients, they end up in logs
+// and in messages sent to other services.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Middleware assigns every request an id, taken from the X-Request-ID header
+// when the caller sent a usable one, returns it in the response and stores it
+// in the user context. Controllers hand that context to the services, so the
+// records they log with the slog ...Context functions, the GORM query logs and
+// the messages they publish with rabbitmq.PublishContext carry the id. Once the
+// request is served it logs one record for it.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		id := c.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set(RequestIDHeader, id)
+		c.Locals("requestID", id)
+		c.SetUserContext(WithRequestID(c.UserContext(), id))
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		slog.LogAttrs(c.UserContext(), level, "Request handled", attrs...)
+		return err
+	}
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "client id", header: "checkout-42.retry_1", keep: true},
+		{name: "missing id"},
+		{name: "invalid id", header: "no spaces allowed"},
+		{name: "too long id", header: string(bytes.Repeat([]byte("a"), 129))},
+	}
+
+	var output bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(New(Config{Output: &output}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/", func(c *fiber.Ctx) error {
+		slog.InfoContext(c.UserContext(), "Handler ran")
+		return c.SendStatus(http.StatusOK)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			id := resp.Header.Get(RequestIDHeader)
+			if id == "" {
+				t.Fatal("response has no request id")
+			}
+			if tt.keep && id != tt.header {
+				t.Errorf("request id = %q, want %q", id, tt.header)
+			}
+			if !tt.keep && id == tt.header {
+				t.Errorf("request id %q was accepted", id)
+			}
+
+			// The handler's record and the request record both carry the id.
+			records := 0
+			scanner := bufio.NewScanner(&output)
+			for scanner.Scan() {
+				var record map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					t.Fatal(err)
+				}
+				if record["request_id"] != id {
+					t.Errorf("record %q has request_id %v, want %q", record["msg"], record["request_id"], id)
+				}
+				records++
+			}
+			if records != 2 {
+				t.Errorf("logged %d records, want 2", records)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are matched against attribute keys in lower case, an
+// attribute whose key contains one of them is never written.
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"authorization",
+	"api_key",
+	"apikey",
+	"cookie",
+	"signature",
+}
+
+var (
+	// jwtPattern matches JSON web tokens, whose header always starts with
+	// the encoding of `{"`.
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+// redactAttr hides the values of sensitive attributes and masks tokens that
+// end up in messages or other strings.
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if isSensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	case slog.KindAny:
+		// Errors and values printed with fmt may quote a token.
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+		if stringer, ok := attr.Value.Any().(fmt.Stringer); ok {
+			return slog.String(attr.Key, Redact(stringer.String()))
+		}
+	}
+	return attr
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact masks JSON web tokens and authorization credentials in s.
+func Redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	return bearerPattern.ReplaceAllString(s, "$1 "+redacted)
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Collectors are registered on the default Prometheus registry, which also
+// carries the Go runtime and process metrics, and served on /metrics.
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Duration of GORM statements by operation and table.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "GORM statements that failed, not counting record not found.",
+	}, []string{"operation", "table"})
+
+	RabbitMQConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_consumed_total",
+		Help: "Messages received from RabbitMQ queues.",
+	}, []string{"queue"})
+
+	RabbitMQAcked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_acked_total",
+		Help: "Consumed messages that were processed and acknowledged.",
+	}, []string{"queue"})
+
+	RabbitMQFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_failed_total",
+		Help: "Consumed messages that could not be processed and were rejected.",
+	}, []string{"queue"})
+
+	RabbitMQPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_published_total",
+		Help: "Messages published to RabbitMQ queues by result.",
+	}, []string{"queue", "result"})
+
+	OrdersPlaced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shop_orders_placed_total",
+		Help: "Orders placed by currency.",
+	}, []string{"currency"})
+
+	OrderStatusChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shop_order_status_changes_total",
+		Help: "Order status transitions by new status.",
+	}, []string{"status"})
+
+	StockOuts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "shop_stock_outs_total",
+		Help: "Times a product ran out of stock.",
+	})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_delivery_attempts_total",
+		Help: "Webhook delivery attempts by event type and result.",
+	}, []string{"event", "result"})
+)
@@ -1,10 +1,17 @@
 package rabbitmq
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"go-api/core/logging"
+	"go-api/core/metrics"
+	"go-api/core/tracing"
 	"go-api/middleware"
-	"log"
+	"log/slog"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/streadway/amqp"
 )
@@ -22,6 +29,24 @@ type Message struct {
 var Conn *amqp.Connection
 var Ch *amqp.Channel
 
+// consuming reports whether the token consumer is receiving deliveries.
+var consuming atomic.Bool
+
+// publishMu serializes publishing, an amqp channel is not safe for
+// concurrent use.
+var publishMu sync.Mutex
+
+// requestIDHeader carries the id of the request that caused a message, so
+// both services log it.
+const requestIDHeader = "x-request-id"
+
+// event is the envelope of the Nest RMQ transport, so Nest handlers can
+// consume published messages with @EventPattern(pattern).
+type event struct {
+	Pattern string      `json:"pattern"`
+	Data    interface{} `json:"data"`
+}
+
 func InitializeRabbitMQ() (*amqp.Connection, *amqp.Channel, error) {
 	conn, err := amqp.Dial(os.Getenv("RABBITMQ_URL"))
 	if err != nil {
@@ -40,49 +65,128 @@ func InitializeRabbitMQ() (*amqp.Connection, *amqp.Channel, error) {
 	return conn, ch, nil
 }
 
+const tokenQueue = "token_created_queue"
+
 func ConsumeMessages(ch *amqp.Channel) {
 	msgs, err := ch.Consume(
-		"token_created_queue",
+		tokenQueue,
 		"",
-		true,  // Auto-acknowledge
+		false, // Auto-acknowledge
 		false, // Exclusive
 		false, // No-local
 		false, // No-wait
 		nil,   // Arguments
 	)
 	if err != nil {
-		log.Fatalf("Queue messages could not be received: %v", err)
+		logging.Fatal("Queue messages could not be received", "error", err)
 	}
 
 	forever := make(chan bool)
 
+	consuming.Store(true)
 	go func() {
 		for d := range msgs {
-			log.Printf("Incoming message: %s", d.Body)
-
-			var message Message
-			err := json.Unmarshal(d.Body, &message)
-			if err != nil {
-				log.Printf("Message parsing error: %s", err)
-				continue
-			}
+			handleTokenMessage(d)
+		}
+		// The delivery channel closes with the connection or the channel.
+		consuming.Store(false)
+		slog.Error("Stopped consuming messages", "queue", tokenQueue)
+	}()
 
-			payload := message.Result
+	<-forever
+}
 
-			log.Printf("Message content: %+v", payload)
-			log.Println("Access token:", payload.AccessToken)
+func handleTokenMessage(d amqp.Delivery) {
+	ctx, span := tracing.StartConsume(tokenQueue, d)
+	defer span.End()
+	if id, ok := d.Headers[requestIDHeader].(string); ok {
+		ctx = logging.WithRequestID(ctx, id)
+	}
 
-			claims, err := middleware.ValidateToken(payload.AccessToken)
-			if err != nil {
-				log.Println("Invalid token:", err)
-				continue
-			}
+	// The body carries the tokens themselves, only its size is logged.
+	slog.DebugContext(ctx, "Message received", "queue", tokenQueue, "bytes", len(d.Body))
+	metrics.RabbitMQConsumed.WithLabelValues(tokenQueue).Inc()
 
-			log.Printf("Token is valid, user: %v", claims["id"])
+	var message Message
+	err := json.Unmarshal(d.Body, &message)
+	if err != nil {
+		slog.ErrorContext(ctx, "Message parsing error", "queue", tokenQueue, "error", err)
+		tracing.Fail(span, err)
+		// A malformed message fails the same way on every delivery, so it
+		// is dropped rather than requeued.
+		if err := d.Nack(false, false); err != nil {
+			slog.ErrorContext(ctx, "Error rejecting message", "error", err)
 		}
-	}()
+		metrics.RabbitMQFailed.WithLabelValues(tokenQueue).Inc()
+		return
+	}
 
-	<-forever
+	claims, err := middleware.ValidateToken(message.Result.AccessToken)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid token", "error", err)
+	} else {
+		slog.InfoContext(ctx, "Token is valid", "user_id", claims["id"])
+	}
+
+	if err := d.Ack(false); err != nil {
+		slog.ErrorContext(ctx, "Error acknowledging message", "error", err)
+		tracing.Fail(span, err)
+		metrics.RabbitMQFailed.WithLabelValues(tokenQueue).Inc()
+		return
+	}
+	metrics.RabbitMQAcked.WithLabelValues(tokenQueue).Inc()
+}
+
+// PublishContext sends data to queue as a Nest event with the given pattern,
+// within the trace of ctx. The trace context and the request ID of ctx are
+// sent in the message headers.
+func PublishContext(ctx context.Context, queue, pattern string, data interface{}) error {
+	if Ch == nil {
+		return errors.New("rabbitmq is not initialized")
+	}
+
+	body, err := json.Marshal(event{Pattern: pattern, Data: data})
+	if err != nil {
+		return err
+	}
+
+	headers := amqp.Table{}
+	if id := logging.RequestID(ctx); id != "" {
+		headers[requestIDHeader] = id
+	}
+	_, span := tracing.StartPublish(ctx, queue, pattern, headers)
+	defer span.End()
+
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	if _, err := Ch.QueueDeclare(queue, false, false, false, false, nil); err != nil {
+		tracing.Fail(span, err)
+		metrics.RabbitMQPublished.WithLabelValues(queue, "error").Inc()
+		return err
+	}
+	err = Ch.Publish("", queue, false, false, amqp.Publishing{
+		Headers:     headers,
+		ContentType: "application/json",
+		Body:        body,
+	})
+	if err != nil {
+		tracing.Fail(span, err)
+		metrics.RabbitMQPublished.WithLabelValues(queue, "error").Inc()
+		return err
+	}
+	metrics.RabbitMQPublished.WithLabelValues(queue, "ok").Inc()
+	return nil
+}
+
+// Connected reports whether the connection to the broker is open.
+func Connected() bool {
+	return Conn != nil && !Conn.IsClosed()
+}
+
+// Consuming reports whether the token consumer is running.
+func Consuming() bool {
+	return consuming.Load()
 }
 
 func CloseRabbitMQ() {
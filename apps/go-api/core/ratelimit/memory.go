@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the counters of this replica only.
+type MemoryStore struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+type window struct {
+	count   int64
+	resetAt time.Time
+}
+
+// sweepInterval is how often expired windows are dropped, so keys of callers
+// that went away do not pile up.
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: make(map[string]*window), lastSweep: time.Now()}
+}
+
+func (m *MemoryStore) Increment(_ context.Context, key string, length time.Duration) (int64, time.Duration, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, w := range m.windows {
+			if !now.Before(w.resetAt) {
+				delete(m.windows, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	w, ok := m.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(length)}
+		m.windows[key] = w
+	}
+	w.count++
+	return w.count, w.resetAt.Sub(now), nil
+}
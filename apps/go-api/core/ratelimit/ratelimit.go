@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUnknownDriver = errors.New("unknown rate limit driver")
+
+// Store counts the requests of a key within fixed windows. Increment adds one
+// request and returns the count of the current window and the time left
+// until it resets.
+type Store interface {
+	Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
+
+type Config struct {
+	// Driver is memory or redis. Only the Redis store is shared by the
+	// replicas, with the memory store each replica allows the full limit.
+	Driver   string
+	RedisURL string
+}
+
+func NewStore(cfg Config) (Store, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "redis":
+		if cfg.RedisURL == "" {
+			return nil, errors.New("the redis rate limit store needs REDIS_URL")
+		}
+		options, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(redis.NewClient(options), "go-api:ratelimit:"), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, cfg.Driver)
+	}
+}
+
+// Tier groups callers that share the same limits.
+type Tier string
+
+const (
+	Anonymous   Tier = "anonymous"
+	User        Tier = "user"
+	Admin       Tier = "admin"
+	Integration Tier = "integration"
+)
+
+// Identity is who a request is counted against.
+type Identity struct {
+	Tier Tier
+	// Key identifies the caller within the tier, such as a user id or,
+	// for anonymous callers, the client IP.
+	Key string
+}
+
+// Policy is the number of requests each tier may make per window. A tier
+// without a positive limit is not limited.
+type Policy struct {
+	Window time.Duration
+	Limits map[Tier]int
+}
+
+type Options struct {
+	Store    Store
+	Policies map[string]Policy
+	// Classify names the policy of a request, "" exempts it.
+	Classify func(c *fiber.Ctx) string
+	Identify func(c *fiber.Ctx) Identity
+}
+
+// New limits requests per policy and caller. Every limited response carries
+// the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers, rejected requests get a 429 with Retry-After. A
+// failing store lets requests through, an outage of Redis must not take the
+// API down with it.
+func New(options Options) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name := options.Classify(c)
+		policy, ok := options.Policies[name]
+		if name == "" || !ok {
+			return c.Next()
+		}
+
+		identity := options.Identify(c)
+		limit := policy.Limits[identity.Tier]
+		if limit <= 0 {
+			return c.Next()
+		}
+
+		key := name + ":" + string(identity.Tier) + ":" + identity.Key
+		count, reset, err := options.Store.Increment(c.UserContext(), key, policy.Window)
+		if err != nil {
+			slog.WarnContext(c.UserContext(), "Error counting request for rate limit", "policy", name, "error", err)
+			return c.Next()
+		}
+
+		resetSeconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+		c.Set("RateLimit-Limit", strconv.Itoa(limit))
+		c.Set("RateLimit-Remaining", strconv.FormatInt(max(int64(limit)-count, 0), 10))
+		c.Set("RateLimit-Reset", resetSeconds)
+		c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit, int(policy.Window.Seconds())))
+
+		if count > int64(limit) {
+			c.Set(fiber.HeaderRetryAfter, resetSeconds)
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many requests, retry in " + resetSeconds + " seconds",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds every counter update, past it the request goes through
+// uncounted.
+const redisTimeout = 200 * time.Millisecond
+
+// incrementScript starts the window on the first request so the counter and
+// its expiry are set atomically.
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+// RedisStore shares the counters between replicas. Keys are stored under
+// Prefix.
+type RedisStore struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{Client: client, Prefix: prefix}
+}
+
+func (r *RedisStore) Increment(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	result, err := incrementScript.Run(ctx, r.Client, []string{r.Prefix + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(result) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rate limit reply %v", result)
+	}
+
+	reset := time.Duration(result[1]) * time.Millisecond
+	if reset < 0 {
+		// The key lost its expiry, start a new window on the next request.
+		r.Client.PExpire(ctx, r.Prefix+key, window)
+		reset = window
+	}
+	return result[0], reset, nil
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// Every runs job.Run in the background once per interval until Stop is
+// called. Stop cancels the context of a run in progress.
+func Every(name string, interval time.Duration, run func(ctx context.Context) error) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		Name:     name,
+		Interval: interval,
+		Run:      run,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go job.loop()
+
+	return job
+}
+
+func (j *Job) loop() {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := j.Run(j.ctx); err != nil {
+				slog.ErrorContext(j.ctx, "Scheduled job failed", "job", j.Name, "error", err)
+			}
+		case <-j.ctx.Done():
+			return
+		}
+	}
+}
+
+func (j *Job) Stop() {
+	j.cancel()
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownDriver = errors.New("unknown storage driver")
+
+// Storage keeps uploaded files under slash separated keys and tells where
+// they can be downloaded from.
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+type Config struct {
+	Driver    string
+	LocalDir  string
+	PublicURL string
+	S3        S3Config
+}
+
+// New returns the storage backend selected by cfg.Driver, the local
+// filesystem when it is empty.
+func New(cfg Config) (Storage, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalDir, cfg.PublicURL)
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, cfg.Driver)
+	}
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing the trace
+// of the caller when it sent a traceparent header. The span context is
+// stored as the user context of the request, handlers pass c.UserContext()
+// on to record their work in the same trace.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestCarrier{c})
+
+		ctx, span := Tracer().Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+				semconv.ClientAddress(c.IP()),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+			span.RecordError(err)
+		}
+
+		// The route is only known once the router matched it.
+		if route := c.Route().Path; route != "" {
+			span.SetName(c.Method() + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+
+		otel.GetTextMapPropagator().Inject(ctx, responseCarrier{c})
+		return err
+	}
+}
+
+// requestCarrier reads propagation headers from the request.
+type requestCarrier struct {
+	c *fiber.Ctx
+}
+
+func (rc requestCarrier) Get(key string) string {
+	return rc.c.Get(key)
+}
+
+func (rc requestCarrier) Set(key, value string) {
+	rc.c.Request().Header.Set(key, value)
+}
+
+func (rc requestCarrier) Keys() []string {
+	var keys []string
+	rc.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// responseCarrier writes propagation headers to the response so clients can
+// look the trace up.
+type responseCarrier struct {
+	c *fiber.Ctx
+}
+
+func (rc responseCarrier) Get(key string) string {
+	return string(rc.c.Response().Header.Peek(key))
+}
+
+func (rc responseCarrier) Set(key, value string) {
+	rc.c.Set(key, value)
+}
+
+func (rc responseCarrier) Keys() []string {
+	var keys []string
+	rc.c.Response().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
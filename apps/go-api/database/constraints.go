@@ -0,0 +1,114 @@
+package database
+
+import (
+	"fmt"
+	"go-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// productCategoryConstraint is the name GORM gives the foreign key of
+// Product.Category.
+const productCategoryConstraint = "fk_products_category"
+
+// migrateConstraints brings existing foreign keys in line with the model
+// tags. AutoMigrate only creates missing constraints, it never changes the
+// rules of one that already exists.
+func migrateConstraints(db *gorm.DB) error {
+	var deleteRule string
+	err := db.Raw(`SELECT delete_rule FROM information_schema.referential_constraints
+		WHERE constraint_schema = CURRENT_SCHEMA() AND constraint_name = ?`, productCategoryConstraint).
+		Scan(&deleteRule).Error
+	if err != nil {
+		return err
+	}
+	if deleteRule == "RESTRICT" {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		if deleteRule != "" {
+			if err := migrator.DropConstraint(&models.Product{}, productCategoryConstraint); err != nil {
+				return err
+			}
+		}
+		return migrator.CreateConstraint(&models.Product{}, "Category")
+	})
+}
+
+// checkUniqueSKUs fails the migration with the offending SKUs when products
+// share one, AutoMigrate would otherwise fail on idx_products_sku with a bare
+// database error. They have to be fixed by hand.
+func checkUniqueSKUs(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.Product{}) {
+		return nil
+	}
+	var duplicates []string
+	err := db.Unscoped().Model(&models.Product{}).Where("sku <> ''").
+		Group("sku").Having("COUNT(*) > 1").Order("sku").Limit(20).
+		Pluck("sku", &duplicates).Error
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("products share the SKUs %s, give them unique SKUs", strings.Join(duplicates, ", "))
+	}
+	return nil
+}
+
+// backfillProductCurrency gives the products without a currency the base
+// one, AutoMigrate makes the column NOT NULL afterwards. Products created
+// before the column existed get it added here first, Postgres cannot add a
+// NOT NULL column to a table with rows.
+func backfillProductCurrency(db *gorm.DB, currency string) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Product{}) {
+		return nil
+	}
+	if !migrator.HasColumn(&models.Product{}, "Currency") {
+		if err := db.Exec("ALTER TABLE products ADD COLUMN currency varchar(3)").Error; err != nil {
+			return err
+		}
+	}
+	return db.Exec("UPDATE products SET currency = ? WHERE currency IS NULL OR currency = ''", currency).Error
+}
+
+// defaultWarehouseCode is the code of the warehouse seeded with the stock the
+// products held before they were stocked in warehouses.
+const defaultWarehouseCode = "DEFAULT"
+
+// seedDefaultWarehouse creates the default warehouse in country and moves
+// into it the stock of every product not stocked in any warehouse, which the
+// first warehouse count of the product would otherwise overwrite. It runs
+// once, the default warehouse marks it done even after it was deleted.
+func seedDefaultWarehouse(db *gorm.DB, country string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var seeded int64
+		if err := tx.Unscoped().Model(&models.Warehouse{}).Where("code = ?", defaultWarehouseCode).Count(&seeded).Error; err != nil {
+			return err
+		}
+		if seeded > 0 {
+			return nil
+		}
+
+		warehouse := models.Warehouse{Code: defaultWarehouseCode, Name: "Default warehouse", Country: strings.ToUpper(country), IsActive: true}
+		if err := tx.Create(&warehouse).Error; err != nil {
+			return err
+		}
+
+		var products []models.Product
+		err := tx.Select("id", "stock").
+			Where("stock > 0 AND NOT EXISTS (SELECT 1 FROM warehouse_stocks WHERE warehouse_stocks.product_id = products.id)").
+			Find(&products).Error
+		if err != nil || len(products) == 0 {
+			return err
+		}
+		levels := make([]models.WarehouseStock, len(products))
+		for i, product := range products {
+			levels[i] = models.WarehouseStock{WarehouseID: warehouse.ID, ProductID: product.ID, Quantity: product.Stock}
+		}
+		return tx.CreateInBatches(levels, 500).Error
+	})
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"fmt"
+	"go-api/database/dbtest"
+	"go-api/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckUniqueSKUs(t *testing.T) {
+	tests := []struct {
+		name    string
+		skus    []string
+		trashed bool
+		want    string
+	}{
+		{name: "unique", skus: []string{"LAMP-1", "DESK-1"}},
+		{name: "without skus", skus: []string{"", ""}},
+		{name: "shared", skus: []string{"LAMP-1", "LAMP-1", "DESK-1", "DESK-1", "CHAIR-1"}, want: "DESK-1, LAMP-1"},
+		{name: "shared with a trashed product", skus: []string{"LAMP-1", "LAMP-1"}, trashed: true, want: "LAMP-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t, &models.Category{}, &models.Product{})
+			// Databases migrated before the index could hold duplicates.
+			if err := db.Migrator().DropIndex(&models.Product{}, "idx_products_sku"); err != nil {
+				t.Fatal(err)
+			}
+			category := models.Category{Name: "Furniture"}
+			dbtest.Create(t, db, &category)
+			for i, sku := range tt.skus {
+				product := models.Product{Name: "Product", SKU: sku, CategoryID: category.ID}
+				dbtest.Create(t, db, &product)
+				if tt.trashed && i == 0 {
+					if err := db.Delete(&product).Error; err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			err := checkUniqueSKUs(db)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkUniqueSKUs() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "the SKUs "+tt.want+",") {
+				t.Errorf("checkUniqueSKUs() = %v, want the SKUs %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateConstraints(t *testing.T) {
+	const drop = "ALTER TABLE products DROP CONSTRAINT " + productCategoryConstraint
+	tests := []struct {
+		name  string
+		setup []string
+	}{
+		{name: "up to date"},
+		{name: "missing", setup: []string{drop}},
+		{name: "cascading", setup: []string{drop, "ALTER TABLE products ADD CONSTRAINT " + productCategoryConstraint +
+			" FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The rules are read from information_schema.
+			db := dbtest.Postgres(t, &models.Category{}, &models.Product{})
+			for _, statement := range tt.setup {
+				if err := db.Exec(statement).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := migrateConstraints(db); err != nil {
+				t.Fatal(err)
+			}
+
+			var deleteRule string
+			err := db.Raw(`SELECT delete_rule FROM information_schema.referential_constraints
+				WHERE constraint_schema = CURRENT_SCHEMA() AND constraint_name = ?`, productCategoryConstraint).
+				Scan(&deleteRule).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleteRule != "RESTRICT" {
+				t.Errorf("delete rule = %q, want RESTRICT", deleteRule)
+			}
+		})
+	}
+}
+
+func TestBackfillProductCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		products []string
+		want     []string
+	}{
+		{name: "without products table"},
+		{
+			name:     "without currency column",
+			table:    "CREATE TABLE products (id integer PRIMARY KEY, name text)",
+			products: []string{"INSERT INTO products (id, name) VALUES (1, 'Lamp')"},
+			want:     []string{"USD"},
+		},
+		{
+			name:  "missing currencies",
+			table: "CREATE TABLE products (id integer PRIMARY KEY, name text, currency varchar(3))",
+			products: []string{
+				"INSERT INTO products (id, name, currency) VALUES (1, 'Lamp', NULL)",
+				"INSERT INTO products (id, name, currency) VALUES (2, 'Desk', '')",
+				"INSERT INTO products (id, name, currency) VALUES (3, 'Chair', 'EUR')",
+			},
+			want: []string{"USD", "USD", "EUR"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t)
+			for _, statement := range append([]string{tt.table}, tt.products...) {
+				if statement == "" {
+					continue
+				}
+				if err := db.Exec(statement).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := backfillProductCurrency(db, "USD"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.table == "" {
+				return
+			}
+
+			var currencies []string
+			if err := db.Table("products").Order("id").Pluck("currency", &currencies).Error; err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(currencies, tt.want) {
+				t.Errorf("currencies = %q, want %q", currencies, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeedDefaultWarehouse(t *testing.T) {
+	db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Warehouse{}, &models.WarehouseStock{})
+	counted := models.Warehouse{Code: "MAIN", Name: "Main", Country: "DE", IsActive: true}
+	dbtest.Create(t, db, &counted)
+	lamp := dbtest.Product(t, db, models.Product{Name: "Lamp", SKU: "LAMP-1", Stock: 5})
+	desk := dbtest.Product(t, db, models.Product{Name: "Desk", SKU: "DESK-1", Stock: 2})
+	chair := dbtest.Product(t, db, models.Product{Name: "Chair", SKU: "CHAIR-1"})
+	dbtest.Create(t, db, &models.WarehouseStock{WarehouseID: counted.ID, ProductID: desk.ID, Quantity: 2})
+
+	for i := 0; i < 2; i++ {
+		if err := seedDefaultWarehouse(db, "us"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var warehouses []models.Warehouse
+	if err := db.Where("code = ?", defaultWarehouseCode).Find(&warehouses).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(warehouses) != 1 || warehouses[0].Country != "US" || !warehouses[0].IsActive {
+		t.Fatalf("default warehouses = %+v, want one active in US", warehouses)
+	}
+
+	var levels []models.WarehouseStock
+	if err := db.Order("product_id").Order("warehouse_id").Find(&levels).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, level := range levels {
+		got = append(got, fmt.Sprintf("%d@%d:%d", level.ProductID, level.WarehouseID, level.Quantity))
+	}
+	want := []string{
+		fmt.Sprintf("%d@%d:5", lamp.ID, warehouses[0].ID),
+		fmt.Sprintf("%d@%d:2", desk.ID, counted.ID),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("warehouse stock = %v, want %v, nothing for product %d", got, want, chair.ID)
+	}
+
+	// Deleting the default warehouse does not seed it again.
+	if err := db.Delete(&warehouses[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := seedDefaultWarehouse(db, "US"); err != nil {
+		t.Fatal(err)
+	}
+	var active int64
+	if err := db.Model(&models.Warehouse{}).Where("code = ?", defaultWarehouseCode).Count(&active).Error; err != nil {
+		t.Fatal(err)
+	}
+	if active != 0 {
+		t.Error("default warehouse seeded again after it was deleted")
+	}
+}
@@ -1,9 +1,15 @@
 package database
 
 import (
+	"go-api/config"
+	"go-api/core/logging"
+	"go-api/core/metrics"
+	"go-api/core/tracing"
 	"go-api/models"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	seeders "go-api/seeder"
 
@@ -14,28 +20,90 @@ import (
 
 var DB *gorm.DB
 
+// Models are the tables migrated on startup.
+var Models = []interface{}{
+	&models.Product{},
+	&models.ProductImage{},
+	&models.Category{},
+	&models.ProductPrice{},
+	&models.Promotion{},
+	&models.PromotionRedemption{},
+	&models.TaxRule{},
+	&models.ShippingZone{},
+	&models.ShippingMethod{},
+	&models.Order{},
+	&models.OrderItem{},
+	&models.Shipment{},
+	&models.ShipmentEvent{},
+	&models.Payment{},
+	&models.PaymentWebhookEvent{},
+	&models.Review{},
+	&models.Wishlist{},
+	&models.WishlistItem{},
+	&models.ProductSubscription{},
+	&models.Warehouse{},
+	&models.WarehouseStock{},
+	&models.StockTransfer{},
+	&models.OrderAllocation{},
+	&models.ImportJob{},
+	&models.ImportRowError{},
+	&models.CategoryAttribute{},
+	&models.ProductAttributeValue{},
+	&models.APIKey{},
+	&models.WebhookSubscription{},
+	&models.WebhookDelivery{},
+}
+
+// MigratedAt is when the migrations last completed, zero until they have.
+var MigratedAt time.Time
+
 func ConnectDB() {
 
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		logging.Fatal("Error loading .env file")
 	}
 
 	dsn := os.Getenv("DATABASE_URL")
 
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logging.NewGormLogger(200 * time.Millisecond),
+		// Unique violations surface as gorm.ErrDuplicatedKey.
+		TranslateError: true,
+	})
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal("Failed to connect to database", "error", err)
+	}
+
+	if err := DB.Use(metrics.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register database metrics", "error", err)
+	}
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		logging.Fatal("Failed to register database tracing", "error", err)
 	}
 
-	err = DB.AutoMigrate(&models.Product{}, &models.Category{})
+	baseCurrency := strings.ToUpper(config.GetString("BASE_CURRENCY", "USD"))
+	if err := checkUniqueSKUs(DB); err != nil {
+		logging.Fatal("Failed to run migrations", "error", err)
+	}
+	if err := backfillProductCurrency(DB, baseCurrency); err != nil {
+		logging.Fatal("Failed to run migrations", "error", err)
+	}
+	err = DB.AutoMigrate(Models...)
 	if err != nil {
-		log.Fatal("Failed to run migrations:", err)
+		logging.Fatal("Failed to run migrations", "error", err)
+	}
+	if err := migrateConstraints(DB); err != nil {
+		logging.Fatal("Failed to migrate constraints", "error", err)
+	}
+	if err := seedDefaultWarehouse(DB, config.GetString("DEFAULT_WAREHOUSE_COUNTRY", "US")); err != nil {
+		logging.Fatal("Failed to run migrations", "error", err)
 	}
+	MigratedAt = time.Now()
 
-	log.Println("Database connection established")
+	slog.Info("Database connection established")
 
 	seeders.SeedCategories(DB, 5)
-	seeders.SeedProducts(DB, 20)
+	seeders.SeedProducts(DB, 20, baseCurrency)
 
 }
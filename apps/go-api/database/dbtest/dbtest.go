@@ -0,0 +1,122 @@
+// Package dbtest opens throwaway databases for tests. They run on an
+// in-memory SQLite database unless TEST_DATABASE_URL points at a Postgres
+// server, which also covers the SQL only Postgres understands.
+package dbtest
+
+import (
+	"fmt"
+	"go-api/models"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns an empty database with the tables of the given models. Its
+// errors are translated like those of the application database.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	if os.Getenv("TEST_DATABASE_URL") != "" {
+		return Postgres(t, models...)
+	}
+
+	// Foreign keys are enforced as they are on Postgres.
+	db, err := gorm.Open(sqlite.Open("file::memory:?_pragma=foreign_keys(1)"), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection opens its own in-memory database, keep to one.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrate(t, db, models)
+	return db
+}
+
+// Postgres returns an empty database like Open on the Postgres server of
+// TEST_DATABASE_URL, in a schema of its own that is dropped after the test.
+// The test is skipped without a server.
+func Postgres(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrate(t, db, models)
+	return db
+}
+
+func migrate(t testing.TB, db *gorm.DB, models []interface{}) {
+	t.Helper()
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withSearchPath adds the schema to a URL or keyword/value connection string.
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// Create saves the records in order and fails the test on the first error.
+func Create(t testing.TB, db *gorm.DB, records ...interface{}) {
+	t.Helper()
+	for _, record := range records {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Product saves the product in a category of its own when it has none.
+func Product(t testing.TB, db *gorm.DB, product models.Product) models.Product {
+	t.Helper()
+	if product.CategoryID == 0 {
+		category := models.Category{Name: "Category of " + product.Name}
+		Create(t, db, &category)
+		product.CategoryID = category.ID
+	}
+	Create(t, db, &product)
+	return product
+}
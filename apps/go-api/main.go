@@ -1,13 +1,24 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"go-api/config"
+	"go-api/core/logging"
+	"go-api/core/metrics"
 	"go-api/core/rabbitmq"
+	"go-api/core/ratelimit"
+	"go-api/core/scheduler"
+	"go-api/core/tracing"
 	"go-api/database"
+	"go-api/middleware"
 	"go-api/routes"
-	"log"
+	apiKeyService "go-api/services/apikey"
+	inventoryService "go-api/services/inventory"
+	notificationService "go-api/services/notification"
+	webhookService "go-api/services/webhook"
+	"log/slog"
 	"os"
+	"strconv"
 	"time"
 
 	_ "go-api/docs"
@@ -15,10 +26,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/healthcheck"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/swagger"
 )
 
@@ -49,57 +57,141 @@ type TokenPayload struct {
 func main() {
 	config.LoadConfig()
 
+	logging.Init(logging.Config{
+		Level:  config.GetString("LOG_LEVEL", "info"),
+		Format: config.GetString("LOG_FORMAT", "json"),
+	})
+
+	sampleRatio, _ := strconv.ParseFloat(config.GetString("OTEL_TRACES_SAMPLE_RATIO", "1"), 64)
+	shutdownTracing, err := tracing.Init(tracing.Config{
+		Exporter:     config.GetString("OTEL_TRACES_EXPORTER", "none"),
+		ServiceName:  config.GetString("OTEL_SERVICE_NAME", "go-api"),
+		OTLPEndpoint: config.Get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		SampleRatio:  sampleRatio,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	app := fiber.New(fiber.Config{
 		AppName: "Mock Store API v.1.0",
+		// Leave room for the multipart envelope around an image or a
+		// catalog import upload.
+		BodyLimit: max(config.GetInt("IMAGE_MAX_BYTES", 5<<20), config.GetInt("IMPORT_MAX_BYTES", 20<<20)) + 1<<20,
 	})
 
-	// http.Handle("/metrics", promhttp.Handler())
-	// http.ListenAndServe(":3011", nil)
+	app.Use(metrics.Middleware())
+	app.Get("/metrics", metrics.Handler())
+	app.Use(logging.Middleware())
+	app.Use(tracing.Middleware())
 
 	app.Use(compress.New())
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "https://mock-store.tariksogukpinar.dev, https://mock-api.tariksogukpinar.dev",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Request-ID",
+		ExposeHeaders:    "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After",
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 	}))
 
 	database.ConnectDB()
 
-	app.Use(limiter.New(limiter.Config{
-		Max:        100,
-		Expiration: 30 * time.Second,
+	limitStore, err := ratelimit.NewStore(ratelimit.Config{
+		Driver:   config.Get("RATE_LIMIT_DRIVER"),
+		RedisURL: config.Get("REDIS_URL"),
+	})
+	if err != nil {
+		logging.Fatal("Invalid rate limit configuration", "error", err)
+	}
+	app.Use(ratelimit.New(ratelimit.Options{
+		Store:    limitStore,
+		Policies: routes.RateLimitPolicies(),
+		Classify: routes.RateLimitPolicy,
+		Identify: middleware.RateLimitIdentity(apiKeyService.NewAPIKeyService(database.DB)),
 	}))
 
 	app.Use(helmet.New())
 
-	app.Use(healthcheck.New())
+	services := routes.SetupRoutes(app)
+	defer services.Notifications.Close()
 
-	app.Use(logger.New(logger.Config{
-		Format:     "[${time}] ${status} - ${method} ${path}\n",
-		TimeFormat: "02-Jan-2006",
-		TimeZone:   "Local",
-	}))
+	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	routes.SetupRoutes(app)
+	// The purge goes through the services of the routes, so it drops their
+	// cached reads.
+	retention := time.Duration(config.GetInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+	purgeJob := scheduler.Every("trash-purge", time.Hour, func(ctx context.Context) error {
+		deletedBefore := time.Now().Add(-retention)
+
+		products, err := services.Products.PurgeDeletedProducts(ctx, deletedBefore)
+		if err != nil {
+			return err
+		}
+		categories, err := services.Categories.PurgeDeletedCategories(ctx, deletedBefore)
+		if err != nil {
+			return err
+		}
+
+		if products > 0 || categories > 0 {
+			slog.Info("Purged trash", "products", products, "categories", categories)
+		}
+		return nil
+	})
+	defer purgeJob.Stop()
+
+	// Pending orders hold their stock and promotion uses until they are
+	// paid, those left unpaid are cancelled to give them back.
+	pendingTTL := time.Duration(config.GetInt("ORDER_PENDING_TTL_HOURS", 24)) * time.Hour
+	expiryJob := scheduler.Every("order-expiry", 15*time.Minute, func(ctx context.Context) error {
+		cancelled, err := services.Orders.CancelExpired(ctx, time.Now().Add(-pendingTTL))
+		if cancelled > 0 {
+			slog.Info("Cancelled expired orders", "orders", cancelled)
+		}
+		return err
+	})
+	defer expiryJob.Stop()
+
+	hookService := webhookService.NewWebhookService(database.DB, webhookService.Options{
+		Timeout:     time.Duration(config.GetInt("WEBHOOK_TIMEOUT_MS", 5000)) * time.Millisecond,
+		MaxAttempts: config.GetInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		Backoff:     time.Duration(config.GetInt("WEBHOOK_BACKOFF_SECONDS", 30)) * time.Second,
+		MaxBackoff:  time.Duration(config.GetInt("WEBHOOK_MAX_BACKOFF_SECONDS", 6*60*60)) * time.Second,
+		// Local tests deliver to endpoints on this machine.
+		AllowPrivateNetworks: config.Get("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+	})
+	webhookPoll := time.Duration(config.GetInt("WEBHOOK_POLL_SECONDS", 5)) * time.Second
 
-	app.Get("/swagger/*", swagger.HandlerDefault)
+	webhookJob := scheduler.Every("webhook-delivery", webhookPoll, hookService.DeliverDue)
+	defer webhookJob.Stop()
 
-	conn, ch, err := rabbitmq.InitializeRabbitMQ()
+	_, ch, err := rabbitmq.InitializeRabbitMQ()
 	if err != nil {
-		log.Fatalf("Failed to initialize RabbitMQ: %v", err)
+		logging.Fatal("Failed to initialize RabbitMQ", "error", err)
 	}
 	defer rabbitmq.CloseRabbitMQ()
 
-	fmt.Println("RabbitMQ connection established", conn)
+	slog.Info("RabbitMQ connection established")
 
 	go rabbitmq.ConsumeMessages(ch)
 
+	// The check publishes its events, it starts once RabbitMQ is connected.
+	publisher := notificationService.NewRabbitPublisher(config.GetString("NOTIFICATION_QUEUE", "notification_queue"))
+	invService := inventoryService.NewInventoryService(database.DB, publisher)
+	stockCheck := time.Duration(config.GetInt("LOW_STOCK_CHECK_MINUTES", 5)) * time.Minute
+
+	lowStockJob := scheduler.Every("low-stock-check", stockCheck, func(ctx context.Context) error {
+		return invService.CheckThresholds(ctx)
+	})
+	defer lowStockJob.Stop()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "0.0.0.0:3011"
 	}
 
-	log.Fatal(app.Listen(port))
+	if err := app.Listen(port); err != nil {
+		logging.Fatal("Server stopped", "error", err)
+	}
 }
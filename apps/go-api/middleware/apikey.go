@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"go-api/models"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator resolves the key sent with a request.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// apiKeyFrom returns the API key of the request, sent in X-API-Key or as a
+// bearer token, or "" when the request carries none.
+func apiKeyFrom(c *fiber.Ctx) string {
+	if key := c.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(c.Get("Authorization"), "Bearer "); strings.HasPrefix(token, "gk_") {
+		return token
+	}
+	return ""
+}
+
+// authenticateKey resolves the API key of the request once and stores it in
+// c.Locals("apiKey").
+func authenticateKey(c *fiber.Ctx, keys APIKeyAuthenticator) (models.APIKey, error) {
+	if apiKey, ok := c.Locals("apiKey").(models.APIKey); ok {
+		return apiKey, nil
+	}
+	apiKey, err := keys.Authenticate(c.UserContext(), apiKeyFrom(c))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	c.Locals("apiKey", apiKey)
+	return apiKey, nil
+}
+
+// Authenticated accepts either a JWT, validated like Protected does, or an
+// API key, stored in c.Locals("apiKey").
+func Authenticated(keys APIKeyAuthenticator) fiber.Handler {
+	protected := Protected()
+	return func(c *fiber.Ctx) error {
+		if apiKeyFrom(c) == "" {
+			return protected(c)
+		}
+		if _, err := authenticateKey(c, keys); err != nil {
+			// Why a key was rejected stays in the logs, the caller could
+			// otherwise probe which keys exist or were revoked.
+			slog.WarnContext(c.UserContext(), "Rejected API key", "error", err)
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid API key",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireScope must run after Authenticated. API keys need scope, tokens
+// one of the roles.
+func RequireScope(scope string, roles ...string) fiber.Handler {
+	requireRole := RequireRole(roles...)
+	return func(c *fiber.Ctx) error {
+		apiKey, ok := c.Locals("apiKey").(models.APIKey)
+		if !ok {
+			return requireRole(c)
+		}
+		if !apiKey.HasScope(scope) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "API key lacks the " + scope + " scope",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-api/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type keyStub map[string]models.APIKey
+
+func (k keyStub) Authenticate(ctx context.Context, key string) (models.APIKey, error) {
+	if apiKey, ok := k[key]; ok {
+		return apiKey, nil
+	}
+	return models.APIKey{}, errors.New("api key rejected: key was revoked")
+}
+
+func TestAuthenticatedAPIKey(t *testing.T) {
+	keys := keyStub{
+		"gk_read_secret":  {Name: "reader", Scopes: "catalog:read"},
+		"gk_write_secret": {Name: "writer", Scopes: "catalog:write"},
+	}
+
+	tests := []struct {
+		name      string
+		header    string
+		value     string
+		wantCode  int
+		wantError string
+	}{
+		{name: "header key with scope", header: APIKeyHeader, value: "gk_write_secret", wantCode: http.StatusOK},
+		{name: "bearer key with scope", header: "Authorization", value: "Bearer gk_write_secret", wantCode: http.StatusOK},
+		{name: "key without scope", header: APIKeyHeader, value: "gk_read_secret", wantCode: http.StatusForbidden, wantError: "API key lacks the catalog:write scope"},
+		{name: "rejected key", header: APIKeyHeader, value: "gk_revoked", wantCode: http.StatusUnauthorized, wantError: "Invalid API key"},
+		{name: "rejected bearer key", header: "Authorization", value: "Bearer gk_revoked", wantCode: http.StatusUnauthorized, wantError: "Invalid API key"},
+	}
+
+	app := fiber.New()
+	app.Post("/", Authenticated(keys), RequireScope("catalog:write", "ADMIN"), func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(tt.header, tt.value)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Protected validates the Bearer token and stores its claims in c.Locals("claims").
+func Protected() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authorization header required",
+			})
+		}
+
+		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
+
+		claims, err := ValidateToken(tokenString)
+		if err != nil {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid token: " + err.Error(),
+			})
+		}
+
+		c.Locals("claims", claims)
+		return c.Next()
+	}
+}
+
+// RequireRole must run after Protected and checks the token's role claim.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("claims").(jwt.MapClaims)
+		if !ok {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Unauthorized",
+			})
+		}
+
+		role, _ := claims["role"].(string)
+		for _, r := range roles {
+			if role == r {
+				return c.Next()
+			}
+		}
+
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": "You do not have the required role",
+		})
+	}
+}
+
+// UserID returns the id claim of the authenticated user, or an empty string.
+func UserID(c *fiber.Ctx) string {
+	claims, ok := c.Locals("claims").(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	if id, ok := claims["id"]; ok && id != nil {
+		return fmt.Sprint(id)
+	}
+	return ""
+}
+
+// HasRole reports whether the request carries a valid token with one of the
+// roles. Unlike RequireRole it also works on public routes, where the token
+// is optional and only checked when present.
+func HasRole(c *fiber.Ctx, roles ...string) bool {
+	claims, ok := c.Locals("claims").(jwt.MapClaims)
+	if !ok {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return false
+		}
+		var err error
+		claims, err = ValidateToken(strings.Replace(authHeader, "Bearer ", "", 1))
+		if err != nil {
+			return false
+		}
+	}
+
+	role, _ := claims["role"].(string)
+	for _, r := range roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
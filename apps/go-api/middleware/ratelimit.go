@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+	"go-api/core/ratelimit"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimitIdentity counts requests with a valid API key against the key,
+// those with a valid token against their user and the others against the
+// client IP. The limiter runs before the routes authenticate, so credentials
+// are checked here; invalid ones count as anonymous rather than being
+// rejected. A valid key is kept for Authenticated.
+func RateLimitIdentity(keys APIKeyAuthenticator) func(c *fiber.Ctx) ratelimit.Identity {
+	return func(c *fiber.Ctx) ratelimit.Identity {
+		if apiKeyFrom(c) != "" {
+			if apiKey, err := authenticateKey(c, keys); err == nil {
+				return ratelimit.Identity{Tier: ratelimit.Integration, Key: "key:" + fmt.Sprint(apiKey.ID)}
+			}
+			return anonymous(c)
+		}
+		return userIdentity(c)
+	}
+}
+
+func userIdentity(c *fiber.Ctx) ratelimit.Identity {
+	authHeader := c.Get("Authorization")
+	if authHeader != "" {
+		claims, err := ValidateToken(strings.Replace(authHeader, "Bearer ", "", 1))
+		if err == nil && claims["id"] != nil {
+			tier := ratelimit.User
+			if role, _ := claims["role"].(string); role == "ADMIN" {
+				tier = ratelimit.Admin
+			}
+			return ratelimit.Identity{Tier: tier, Key: "user:" + fmt.Sprint(claims["id"])}
+		}
+	}
+	return anonymous(c)
+}
+
+func anonymous(c *fiber.Ctx) ratelimit.Identity {
+	return ratelimit.Identity{Tier: ratelimit.Anonymous, Key: "ip:" + c.IP()}
+}
@@ -0,0 +1,10 @@
+package models
+
+type Address struct {
+	Country    string `json:"country"`
+	Region     string `json:"region"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API key scopes. A write scope also grants the matching read scope.
+const (
+	ScopeCatalogRead    = "catalog:read"
+	ScopeInventoryRead  = "inventory:read"
+	ScopeInventoryWrite = "inventory:write"
+	ScopeOrdersRead     = "orders:read"
+)
+
+// APIKey lets a system without a user account, such as a warehouse or ERP
+// integration, call the routes its scopes allow. Only a hash of the key is
+// stored, Prefix is its public first part, used to look it up and to tell
+// keys apart. Scopes is a comma separated list.
+type APIKey struct {
+	Model
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"size:24;uniqueIndex"`
+	Hash       string     `json:"-" gorm:"size:64"`
+	Scopes     string     `json:"scopes" example:"catalog:read,inventory:write"`
+	CreatedBy  string     `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type APIKeyInput struct {
+	Name      string     `json:"name"`
+	Scopes    string     `json:"scopes" example:"catalog:read,inventory:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// NewAPIKey is returned once, when the key is created. The key itself cannot
+// be read again afterwards.
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key" example:"gk_3f9a1c2be0d84f6a_Zm9vYmFyYmF6cXV4cXV1eGNvcmdlZ3JhdWx0"`
+}
+
+// HasScope reports whether the key was granted scope. A write scope also
+// grants reading the same resource.
+func (k APIKey) HasScope(scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, granted := range strings.Split(k.Scopes, ",") {
+		if granted == scope || (strings.HasSuffix(scope, ":read") && granted == resource+":write") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package models
+
+const (
+	AttributeText    = "text"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeEnum    = "enum"
+)
+
+// CategoryAttribute defines a specification the products of a category can
+// carry, such as "ram" in GB for laptops. AllowedValues is a comma separated
+// list of the values an enum attribute accepts. Required attributes have to
+// be given whenever the attributes of a product are set.
+type CategoryAttribute struct {
+	Model
+	CategoryID    uint   `json:"category_id" gorm:"uniqueIndex:idx_category_attributes_category_code"`
+	Code          string `json:"code" gorm:"uniqueIndex:idx_category_attributes_category_code"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Unit          string `json:"unit"`
+	AllowedValues string `json:"allowed_values"`
+	Required      bool   `json:"required"`
+	Position      int    `json:"position"`
+}
+
+type CategoryAttributeInput struct {
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Unit          string `json:"unit"`
+	AllowedValues string `json:"allowed_values"`
+	Required      bool   `json:"required"`
+	Position      int    `json:"position"`
+}
+
+// ProductAttributeValue is the value of one attribute for a product, stored
+// in the column matching the attribute type so it can be filtered on.
+type ProductAttributeValue struct {
+	ID          uint              `json:"id" gorm:"primaryKey"`
+	ProductID   uint              `json:"product_id" gorm:"uniqueIndex:idx_product_attribute_values_product_attribute"`
+	AttributeID uint              `json:"attribute_id" gorm:"uniqueIndex:idx_product_attribute_values_product_attribute;index"`
+	Attribute   CategoryAttribute `json:"attribute"`
+	TextValue   *string           `json:"text_value,omitempty" gorm:"index"`
+	NumberValue *float64          `json:"number_value,omitempty" gorm:"index"`
+	BoolValue   *bool             `json:"bool_value,omitempty"`
+}
+
+// ProductAttributesInput maps attribute codes to values. It replaces all the
+// attribute values of the product, a null value leaves an attribute unset.
+type ProductAttributesInput struct {
+	Values map[string]interface{} `json:"values"`
+}
+
+// AttributeFilter restricts a listing to products whose attribute Code has
+// one of Values, or a number within Min and Max.
+type AttributeFilter struct {
+	Code   string
+	Values []string
+	Min    *float64
+	Max    *float64
+}
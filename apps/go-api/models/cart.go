@@ -0,0 +1,65 @@
+package models
+
+// CartInput is a cart submitted by the client. Carts are not persisted, they
+// are priced on every request from the current product data.
+type CartInput struct {
+	Items    []CartItemInput `json:"items"`
+	Coupons  []string        `json:"coupons"`
+	Currency string          `json:"currency"`
+}
+
+// CheckoutInput is a cart together with the address it is delivered to.
+type CheckoutInput struct {
+	CartInput
+	Address Address `json:"address"`
+}
+
+type CartItemInput struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+type CartLine struct {
+	ProductID   uint   `json:"product_id"`
+	CategoryID  uint   `json:"category_id"`
+	Name        string `json:"name"`
+	TaxClass    string `json:"tax_class"`
+	WeightGrams int    `json:"weight_grams"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   Money  `json:"unit_price" swaggertype:"number"`
+	Subtotal    Money  `json:"subtotal" swaggertype:"number"`
+	Discount    Money  `json:"discount" swaggertype:"number"`
+	Total       Money  `json:"total" swaggertype:"number"`
+}
+
+type Cart struct {
+	Currency   string             `json:"currency"`
+	Lines      []CartLine         `json:"lines"`
+	Subtotal   Money              `json:"subtotal" swaggertype:"number"`
+	Discount   Money              `json:"discount" swaggertype:"number"`
+	Total      Money              `json:"total" swaggertype:"number"`
+	Promotions []AppliedPromotion `json:"promotions"`
+	Rejected   []RejectedCoupon   `json:"rejected_coupons"`
+}
+
+func (c *Cart) WeightGrams() int {
+	weight := 0
+	for _, line := range c.Lines {
+		weight += line.WeightGrams * line.Quantity
+	}
+	return weight
+}
+
+// Recalculate refreshes the line and cart totals from subtotals and discounts.
+func (c *Cart) Recalculate() {
+	c.Subtotal, c.Discount, c.Total = 0, 0, 0
+	for i := range c.Lines {
+		line := &c.Lines[i]
+		line.Subtotal = line.UnitPrice * Money(line.Quantity)
+		line.Total = line.Subtotal - line.Discount
+
+		c.Subtotal += line.Subtotal
+		c.Discount += line.Discount
+		c.Total += line.Total
+	}
+}
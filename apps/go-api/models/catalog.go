@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+const (
+	CatalogProducts   = "products"
+	CatalogCategories = "categories"
+
+	CatalogCSV    = "csv"
+	CatalogNDJSON = "ndjson"
+)
+
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob tracks a catalog import processed in the background. Products
+// are matched on SKU and categories on name, matching rows are updated and
+// the others created. A dry run only validates the rows and reports what
+// would happen, Created and Updated then count the rows that would be.
+type ImportJob struct {
+	Model
+	Entity        string           `json:"entity"`
+	Format        string           `json:"format"`
+	FileName      string           `json:"file_name"`
+	DryRun        bool             `json:"dry_run"`
+	Status        string           `json:"status" gorm:"index"`
+	TotalRows     int              `json:"total_rows"`
+	ProcessedRows int              `json:"processed_rows"`
+	Created       int              `json:"created"`
+	Updated       int              `json:"updated"`
+	Skipped       int              `json:"skipped"`
+	Failed        int              `json:"failed"`
+	Error         string           `json:"error,omitempty"`
+	StartedAt     *time.Time       `json:"started_at"`
+	FinishedAt    *time.Time       `json:"finished_at"`
+	RowErrors     []ImportRowError `json:"row_errors,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ImportRowError explains why a row of an import was rejected. Row is the
+// line number in the uploaded file.
+type ImportRowError struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	ImportJobID uint   `json:"import_job_id" gorm:"index"`
+	Row         int    `json:"row"`
+	Field       string `json:"field,omitempty"`
+	Message     string `json:"message"`
+}
+
+// ImportOptions describe an uploaded file. Mapping renames source columns
+// (or NDJSON keys) to catalog fields, columns that are neither mapped nor
+// named after a field are ignored.
+type ImportOptions struct {
+	Entity   string
+	Format   string
+	FileName string
+	DryRun   bool
+	Mapping  map[string]string
+}
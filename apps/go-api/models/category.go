@@ -1,8 +1,31 @@
 package models
 
-import "gorm.io/gorm"
-
 type Category struct {
-	gorm.Model `json:"-" swaggerignore:"true"`
-	Name       string `json:"name"`
+	Model
+	Name string `json:"name"`
+}
+
+const (
+	CategoryDeleteRestrict   = "restrict"
+	CategoryDeleteReassign   = "reassign"
+	CategoryDeleteDeactivate = "deactivate"
+)
+
+type CategoryDeleteOptions struct {
+	Mode           string
+	TargetCategory uint
+	DryRun         bool
+}
+
+type CategoryDeleteResult struct {
+	CategoryID       uint   `json:"category_id"`
+	Mode             string `json:"mode"`
+	DryRun           bool   `json:"dry_run"`
+	TargetCategoryID uint   `json:"target_category_id,omitempty"`
+	AffectedProducts []uint `json:"affected_products"`
+}
+
+type CategoryWithCount struct {
+	Category
+	ProductCount int64 `json:"product_count"`
 }
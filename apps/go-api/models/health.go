@@ -0,0 +1,20 @@
+package models
+
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// HealthComponent is the state of one dependency checked for readiness.
+type HealthComponent struct {
+	Status    string                 `json:"status" example:"up"`
+	LatencyMs float64                `json:"latency_ms" example:"1.25"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// HealthReport is up only when every component is.
+type HealthReport struct {
+	Status     string                     `json:"status" example:"up"`
+	Components map[string]HealthComponent `json:"components,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// ProductImage is an uploaded picture of a product. Images are shown in
+// ascending Position, the first one is mirrored into Product.Image.
+type ProductImage struct {
+	Model
+	ProductID    uint   `json:"product_id" gorm:"index"`
+	Position     int    `json:"position"`
+	AltText      string `json:"alt_text"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Size         int    `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+type ProductImageUpdateInput struct {
+	AltText  *string `json:"alt_text,omitempty"`
+	Position *int    `json:"position,omitempty"`
+}
+
+type ProductImageOrderInput struct {
+	ImageIDs []uint `json:"image_ids"`
+}
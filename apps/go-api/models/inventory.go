@@ -0,0 +1,17 @@
+package models
+
+const (
+	InventoryEventLowStock  = "inventory.low_stock"
+	InventoryEventRestocked = "inventory.restocked"
+)
+
+// InventoryEvent is published when a product's stock crosses its reorder
+// threshold.
+type InventoryEvent struct {
+	Type             string `json:"type"`
+	ProductID        uint   `json:"product_id"`
+	ProductName      string `json:"product_name"`
+	SKU              string `json:"sku"`
+	Stock            int    `json:"stock"`
+	ReorderThreshold int    `json:"reorder_threshold"`
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is a fixed-point amount stored in minor units (1/100 of the currency
+// unit). It is persisted as numeric(12,2) and serialized as a JSON number.
+type Money int64
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// ParseMoney parses a decimal string such as "12", "12.5" or "-0.99" without
+// going through float64. More than two fractional digits are rejected.
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if (whole == "" && fraction == "") || len(fraction) > 2 {
+		return 0, ErrInvalidMoney
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	units, err := strconv.ParseUint(whole, 10, 63)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	cents, err := strconv.ParseUint(fraction, 10, 8)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	if units > math.MaxInt64/100-1 {
+		return 0, ErrInvalidMoney
+	}
+
+	amount := Money(units*100 + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// MoneyFromFloat rounds f to the nearest minor unit. Only use it at
+// boundaries where a float is unavoidable, such as legacy data or seeders.
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * 100))
+}
+
+func (m Money) String() string {
+	sign := ""
+	value := int64(m)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case float64:
+		*m = MoneyFromFloat(v)
+		return nil
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+func (m *Money) scanString(value string) error {
+	// numeric columns may carry more scale than two digits, those are
+	// rounded half away from zero on the decimal digits, not through a
+	// float where 12.345 would round down.
+	if whole, fraction, ok := strings.Cut(value, "."); ok && len(fraction) > 2 {
+		if strings.Trim(fraction, "0123456789") != "" {
+			return ErrInvalidMoney
+		}
+		parsed, err := ParseMoney(whole + "." + fraction[:2])
+		if err != nil {
+			return err
+		}
+		if fraction[2] >= '5' {
+			if strings.HasPrefix(strings.TrimSpace(whole), "-") {
+				parsed--
+			} else {
+				parsed++
+			}
+		}
+		*m = parsed
+		return nil
+	}
+
+	parsed, err := ParseMoney(value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (Money) GormDataType() string {
+	return "numeric(12,2)"
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+		err   error
+	}{
+		{input: "12", want: 1200},
+		{input: "12.5", want: 1250},
+		{input: "12.05", want: 1205},
+		{input: "0.99", want: 99},
+		{input: "-0.99", want: -99},
+		{input: "+3.10", want: 310},
+		{input: ".5", want: 50},
+		{input: " 7.25 ", want: 725},
+		{input: "", err: ErrInvalidMoney},
+		{input: ".", err: ErrInvalidMoney},
+		{input: "1.234", err: ErrInvalidMoney},
+		{input: "abc", err: ErrInvalidMoney},
+		{input: "1.a", err: ErrInvalidMoney},
+		{input: "92233720368547758.07", err: ErrInvalidMoney},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseMoney(%q) error = %v, want %v", tt.input, err, tt.err)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyStringRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, 9, 10, 99, 100, 1205, -1, -99, -1250, 999999999} {
+		parsed, err := ParseMoney(amount.String())
+		if err != nil {
+			t.Fatalf("ParseMoney(%q) error = %v", amount.String(), err)
+		}
+		if parsed != amount {
+			t.Errorf("ParseMoney(%q) = %d, want %d", amount.String(), parsed, amount)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250, "12.50"},
+		{-5, "-0.05"},
+		{-1205, "-12.05"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	type line struct {
+		Price    Money  `json:"price"`
+		Discount *Money `json:"discount"`
+	}
+
+	encoded, err := json.Marshal(line{Price: 1999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"price":19.99,"discount":null}` {
+		t.Errorf("json.Marshal = %s", encoded)
+	}
+
+	tests := []struct {
+		input string
+		want  Money
+		err   bool
+	}{
+		{input: `{"price":19.99}`, want: 1999},
+		{input: `{"price":"19.99"}`, want: 1999},
+		{input: `{"price":20}`, want: 2000},
+		{input: `{"price":null}`, want: 0},
+		{input: `{"price":19.999}`, err: true},
+	}
+	for _, tt := range tests {
+		var decoded line
+		err := json.Unmarshal([]byte(tt.input), &decoded)
+		if (err != nil) != tt.err {
+			t.Fatalf("json.Unmarshal(%s) error = %v", tt.input, err)
+		}
+		if err == nil && decoded.Price != tt.want {
+			t.Errorf("json.Unmarshal(%s) = %d, want %d", tt.input, decoded.Price, tt.want)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  Money
+		err   bool
+	}{
+		{name: "nil", input: nil, want: 0},
+		{name: "string", input: "12.34", want: 1234},
+		{name: "bytes", input: []byte("12.30"), want: 1230},
+		{name: "integer", input: int64(12), want: 1200},
+		{name: "float", input: 12.34, want: 1234},
+		{name: "extra scale rounds down", input: "12.344", want: 1234},
+		{name: "extra scale rounds half up", input: "12.345", want: 1235},
+		{name: "extra scale carries", input: "0.999", want: 100},
+		{name: "negative rounds away from zero", input: "-12.345", want: -1235},
+		{name: "extra scale of zeros", input: "5.0000", want: 500},
+		{name: "garbage", input: "1.2x4", err: true},
+		{name: "unsupported type", input: true, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := got.Scan(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("Scan(%v) error = %v", tt.input, err)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Scan(%v) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyValueRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, -1, 1999, -250000} {
+		value, err := amount.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scanned Money
+		if err := scanned.Scan(value); err != nil {
+			t.Fatal(err)
+		}
+		if scanned != amount {
+			t.Errorf("Scan(Value(%d)) = %d", amount, scanned)
+		}
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		input float64
+		want  Money
+	}{
+		{0.1 + 0.2, 30},
+		{19.99, 1999},
+		{1.005, 100},
+		{-2.5, -250},
+		{0.004, 0},
+		{0.006, 1},
+	}
+
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.input); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+const (
+	NotificationWishlistDiscount    = "wishlist.discount"
+	NotificationWishlistBackInStock = "wishlist.back_in_stock"
+	NotificationBackInStock         = "product.back_in_stock"
+	NotificationPriceDrop           = "product.price_drop"
+)
+
+// NotificationJob is published to the notification queue with its Type as
+// the event pattern. The consumer resolves the user's e-mail address.
+type NotificationJob struct {
+	Type           string `json:"type"`
+	UserID         string `json:"user_id"`
+	ProductID      uint   `json:"product_id"`
+	ProductName    string `json:"product_name"`
+	Currency       string `json:"currency"`
+	Price          Money  `json:"price"`
+	DiscountPrice  *Money `json:"discount_price,omitempty"`
+	Stock          int    `json:"stock"`
+	TargetPrice    *Money `json:"target_price,omitempty"`
+	TargetCurrency string `json:"target_currency,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+type Order struct {
+	Model
+	UserID           string            `json:"user_id" gorm:"index"`
+	Status           string            `json:"status" gorm:"index"`
+	Currency         string            `json:"currency" gorm:"size:3"`
+	Subtotal         Money             `json:"subtotal" swaggertype:"number"`
+	Discount         Money             `json:"discount" swaggertype:"number"`
+	ShippingCost     Money             `json:"shipping_cost" swaggertype:"number"`
+	Tax              Money             `json:"tax" swaggertype:"number"`
+	Total            Money             `json:"total" swaggertype:"number"`
+	ShippingMethodID uint              `json:"shipping_method_id"`
+	ShippingAddress  Address           `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
+	Items            []OrderItem       `json:"items,omitempty"`
+	Shipments        []Shipment        `json:"shipments,omitempty"`
+	Payments         []Payment         `json:"payments,omitempty"`
+	Allocations      []OrderAllocation `json:"allocations,omitempty"`
+}
+
+type OrderItem struct {
+	Model
+	OrderID   uint   `json:"order_id" gorm:"index"`
+	ProductID uint   `json:"product_id" gorm:"index"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unit_price" swaggertype:"number"`
+	Discount  Money  `json:"discount" swaggertype:"number"`
+	Tax       Money  `json:"tax" swaggertype:"number"`
+	Total     Money  `json:"total" swaggertype:"number"`
+}
+
+type OrderInput struct {
+	CheckoutInput
+	ShippingMethodID uint `json:"shipping_method_id"`
+}
+
+type OrderStatusInput struct {
+	Status string `json:"status"`
+}
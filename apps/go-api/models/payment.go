@@ -0,0 +1,64 @@
+package models
+
+const (
+	PaymentPending           = "pending"
+	PaymentAuthorized        = "authorized"
+	PaymentCaptured          = "captured"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+	PaymentVoided            = "voided"
+	PaymentFailed            = "failed"
+)
+
+// Payment is a payment intent for an order at a payment provider.
+// ProviderRef is the provider's identifier of the intent. A payment is
+// pending while the provider authorizes it, PendingRefund is the amount of a
+// refund the provider is processing.
+type Payment struct {
+	Model
+	OrderID        uint   `json:"order_id" gorm:"index"`
+	Provider       string `json:"provider"`
+	ProviderRef    string `json:"provider_ref" gorm:"index"`
+	Status         string `json:"status"`
+	Currency       string `json:"currency" gorm:"size:3"`
+	Amount         Money  `json:"amount" swaggertype:"number"`
+	CapturedAmount Money  `json:"captured_amount" swaggertype:"number"`
+	RefundedAmount Money  `json:"refunded_amount" swaggertype:"number"`
+	PendingRefund  Money  `json:"pending_refund" swaggertype:"number"`
+	FailureReason  string `json:"failure_reason,omitempty"`
+}
+
+type PaymentInput struct {
+	PaymentMethod string `json:"payment_method"`
+}
+
+type RefundInput struct {
+	Amount *Money `json:"amount" swaggertype:"number"`
+}
+
+const (
+	PaymentEventCaptured = "payment.captured"
+	PaymentEventFailed   = "payment.failed"
+	PaymentEventVoided   = "payment.voided"
+	PaymentEventRefunded = "payment.refunded"
+)
+
+// PaymentEvent is a verified webhook notification. Amount is the captured
+// amount for captures and the total refunded so far for refunds, so replaying
+// an event never counts it twice.
+type PaymentEvent struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	ProviderRef string `json:"provider_ref"`
+	Amount      Money  `json:"amount" swaggertype:"number"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// PaymentWebhookEvent records processed webhook events so deliveries retried
+// by the provider are only applied once.
+type PaymentWebhookEvent struct {
+	Model
+	Provider string `json:"provider" gorm:"uniqueIndex:idx_payment_webhook_events_provider_event"`
+	EventID  string `json:"event_id" gorm:"uniqueIndex:idx_payment_webhook_events_provider_event"`
+	Type     string `json:"type"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// ProductPrice is an explicit price for a product in another currency. It
+// takes precedence over converting the product's own price.
+type ProductPrice struct {
+	Model
+	ProductID     uint   `json:"product_id" gorm:"uniqueIndex:idx_product_prices_product_currency"`
+	Currency      string `json:"currency" gorm:"size:3;uniqueIndex:idx_product_prices_product_currency"`
+	Price         Money  `json:"price" swaggertype:"number"`
+	DiscountPrice *Money `json:"discount_price" swaggertype:"number"`
+}
+
+type ProductPriceInput struct {
+	Price         Money  `json:"price" swaggertype:"number"`
+	DiscountPrice *Money `json:"discount_price" swaggertype:"number"`
+}
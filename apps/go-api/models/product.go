@@ -1,54 +1,118 @@
 package models
 
-import "gorm.io/gorm"
+import "time"
 
 type Product struct {
-	gorm.Model    `json:"-" swaggerignore:"true"`
-	ID            uint     `json:"id" gorm:"primaryKey"`
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	Price         float64  `json:"price"`
-	Quantity      int      `json:"quantity"`
-	Image         string   `json:"image"`
-	CategoryID    uint     `json:"category_id"`
-	Category      Category `json:"category" gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"` // Foreign Key
-	DiscountPrice *float64 `json:"discount_price"`
-	IsActive      bool     `json:"is_active"`
-	Stock         int      `json:"stock"`
-	SKU           string   `json:"sku"`
+	Model
+	Name             string                  `json:"name"`
+	Description      string                  `json:"description"`
+	Price            Money                   `json:"price" swaggertype:"number"`
+	Quantity         int                     `json:"quantity"`
+	Image            string                  `json:"image"`
+	CategoryID       uint                    `json:"category_id"`
+	Category         Category                `json:"category" gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"` // Foreign Key
+	DiscountPrice    *Money                  `json:"discount_price" swaggertype:"number"`
+	Currency         string                  `json:"currency" gorm:"size:3;not null"`
+	TaxClass         string                  `json:"tax_class" gorm:"size:32;default:standard"`
+	WeightGrams      int                     `json:"weight_grams"`
+	LengthMm         int                     `json:"length_mm"`
+	WidthMm          int                     `json:"width_mm"`
+	HeightMm         int                     `json:"height_mm"`
+	IsActive         bool                    `json:"is_active"`
+	Stock            int                     `json:"stock"`
+	SKU              string                  `json:"sku" gorm:"uniqueIndex:idx_products_sku,where:sku <> ''"` // Unique across all products, trashed ones included
+	SoldCount        int                     `json:"sold_count" gorm:"default:0"`
+	RatingAverage    float64                 `json:"rating_average" gorm:"default:0"`
+	RatingCount      int                     `json:"rating_count" gorm:"default:0"`
+	ReorderThreshold int                     `json:"reorder_threshold" gorm:"default:0"`
+	LowStock         bool                    `json:"low_stock" gorm:"default:false"`
+	Images           []ProductImage          `json:"images,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	Attributes       []ProductAttributeValue `json:"attributes,omitempty" gorm:"constraint:OnDelete:CASCADE"`
 }
 
 type ProductCreateInput struct {
-	gorm.Model    `json:"-" swaggerignore:"true"`
-	ID            uint     `json:"id" gorm:"primaryKey"`
-	Name          string   `json:"name"`
-	Description   string   `json:"description"`
-	Price         float64  `json:"price"`
-	Quantity      int      `json:"quantity"`
-	Image         string   `json:"image"`
-	CategoryID    uint     `json:"category_id"`
-	DiscountPrice *float64 `json:"discount_price"`
-	IsActive      bool     `json:"is_active"`
-	Stock         int      `json:"stock"`
-	SKU           string   `json:"sku"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	Price            Money  `json:"price" swaggertype:"number"`
+	Quantity         int    `json:"quantity"`
+	Image            string `json:"image"`
+	CategoryID       uint   `json:"category_id"`
+	DiscountPrice    *Money `json:"discount_price" swaggertype:"number"`
+	Currency         string `json:"currency"`
+	TaxClass         string `json:"tax_class"`
+	WeightGrams      int    `json:"weight_grams"`
+	LengthMm         int    `json:"length_mm"`
+	WidthMm          int    `json:"width_mm"`
+	HeightMm         int    `json:"height_mm"`
+	IsActive         bool   `json:"is_active"`
+	Stock            int    `json:"stock"`
+	SKU              string `json:"sku"`
+	ReorderThreshold int    `json:"reorder_threshold"`
 }
 
 type ProductUpdateInput struct {
-	gorm.Model    `json:"-" swaggerignore:"true"`
-	ID            uint     `json:"id" gorm:"primaryKey"`
-	Name          string   `json:"name,omitempty"`
-	Description   string   `json:"description,omitempty"`
-	Price         float64  `json:"price,omitempty"`
-	Quantity      int      `json:"quantity,omitempty"`
-	Image         string   `json:"image,omitempty"`
-	CategoryID    uint     `json:"category_id,omitempty"`
-	DiscountPrice *float64 `json:"discount_price,omitempty"`
-	IsActive      bool     `json:"is_active,omitempty"`
-	Stock         int      `json:"stock,omitempty"`
-	SKU           string   `json:"sku,omitempty"`
+	Name             string `json:"name,omitempty"`
+	Description      string `json:"description,omitempty"`
+	Price            Money  `json:"price,omitempty" swaggertype:"number"`
+	Quantity         int    `json:"quantity,omitempty"`
+	Image            string `json:"image,omitempty"`
+	CategoryID       uint   `json:"category_id,omitempty"`
+	DiscountPrice    *Money `json:"discount_price,omitempty" swaggertype:"number"`
+	Currency         string `json:"currency,omitempty"`
+	TaxClass         string `json:"tax_class,omitempty"`
+	WeightGrams      int    `json:"weight_grams,omitempty"`
+	LengthMm         int    `json:"length_mm,omitempty"`
+	WidthMm          int    `json:"width_mm,omitempty"`
+	HeightMm         int    `json:"height_mm,omitempty"`
+	IsActive         bool   `json:"is_active,omitempty"`
+	Stock            int    `json:"stock,omitempty"`
+	SKU              string `json:"sku,omitempty"`
+	ReorderThreshold *int   `json:"reorder_threshold,omitempty"`
 }
 
 type ProductPriceUpdateInput struct {
-	ID    string  `json:"id"`
-	Price float64 `json:"price"`
+	ID    string `json:"id"`
+	Price Money  `json:"price" swaggertype:"number"`
+}
+
+// ProductVisibility leaves out the products public callers must not see.
+type ProductVisibility struct {
+	HideInactive   bool
+	HideOutOfStock bool
+}
+
+// Hides tells whether the product is left out.
+func (v ProductVisibility) Hides(product Product) bool {
+	return v.HideInactive && !product.IsActive || v.HideOutOfStock && product.Stock <= 0
+}
+
+// ProductFilter holds the optional listing filters; nil fields are ignored.
+type ProductFilter struct {
+	ProductVisibility
+	Search     string
+	CategoryID *uint
+	InStock    *bool
+	Active     *bool
+	OnDiscount *bool
+	MinPrice   *Money
+	MaxPrice   *Money
+	// Currency is the one MinPrice and MaxPrice are given in, the base
+	// currency when empty.
+	Currency     string
+	CreatedAfter *time.Time
+	Attributes   []AttributeFilter
+}
+
+type ProductListQuery struct {
+	ProductFilter
+	Sort  []string
+	Page  int
+	Limit int
+}
+
+type ProductPage struct {
+	Data  []Product `json:"data"`
+	Page  int       `json:"page"`
+	Limit int       `json:"limit"`
+	Total int64     `json:"total"`
 }
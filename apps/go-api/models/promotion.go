@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+const (
+	PromotionPercentage = "percentage"
+	PromotionFixed      = "fixed"
+	PromotionBuyXGetY   = "buy_x_get_y"
+)
+
+// Promotion is a discount rule. Promotions without a code apply automatically,
+// the others only when their coupon code is submitted with the cart. An empty
+// product and category scope means the whole cart is eligible. Amounts are in
+// the base currency.
+type Promotion struct {
+	Model
+	Name              string     `json:"name"`
+	Code              *string    `json:"code" gorm:"uniqueIndex;size:64"`
+	Type              string     `json:"type"`
+	PercentOff        int        `json:"percent_off"`
+	AmountOff         Money      `json:"amount_off" swaggertype:"number"`
+	BuyQuantity       int        `json:"buy_quantity"`
+	GetQuantity       int        `json:"get_quantity"`
+	MinSubtotal       Money      `json:"min_subtotal" swaggertype:"number"`
+	Products          []Product  `json:"products,omitempty" gorm:"many2many:promotion_products"`
+	Categories        []Category `json:"categories,omitempty" gorm:"many2many:promotion_categories"`
+	UsageLimit        *int       `json:"usage_limit"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user"`
+	UsedCount         int        `json:"used_count"`
+	StartsAt          *time.Time `json:"starts_at"`
+	EndsAt            *time.Time `json:"ends_at"`
+	Stackable         bool       `json:"stackable"`
+	Priority          int        `json:"priority"`
+	IsActive          bool       `json:"is_active"`
+}
+
+type PromotionInput struct {
+	Name              string     `json:"name"`
+	Code              *string    `json:"code"`
+	Type              string     `json:"type"`
+	PercentOff        int        `json:"percent_off"`
+	AmountOff         Money      `json:"amount_off" swaggertype:"number"`
+	BuyQuantity       int        `json:"buy_quantity"`
+	GetQuantity       int        `json:"get_quantity"`
+	MinSubtotal       Money      `json:"min_subtotal" swaggertype:"number"`
+	ProductIDs        []uint     `json:"product_ids"`
+	CategoryIDs       []uint     `json:"category_ids"`
+	UsageLimit        *int       `json:"usage_limit"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user"`
+	StartsAt          *time.Time `json:"starts_at"`
+	EndsAt            *time.Time `json:"ends_at"`
+	Stackable         bool       `json:"stackable"`
+	Priority          int        `json:"priority"`
+	IsActive          bool       `json:"is_active"`
+}
+
+// PromotionRedemption records one use of a promotion by a user, made by the
+// order it was placed with.
+type PromotionRedemption struct {
+	Model
+	PromotionID uint   `json:"promotion_id" gorm:"index"`
+	OrderID     uint   `json:"order_id" gorm:"index"`
+	UserID      string `json:"user_id" gorm:"index"`
+	Discount    Money  `json:"discount" swaggertype:"number"`
+	Currency    string `json:"currency" gorm:"size:3"`
+}
+
+type AppliedPromotion struct {
+	PromotionID uint   `json:"promotion_id"`
+	Name        string `json:"name"`
+	Code        string `json:"code,omitempty"`
+	Discount    Money  `json:"discount" swaggertype:"number"`
+}
+
+type RejectedCoupon struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
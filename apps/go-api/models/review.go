@@ -0,0 +1,37 @@
+package models
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewHidden   = "hidden"
+)
+
+// Review is a rating from 1 to 5 left by a customer who bought the product.
+// Reviews stay pending until a moderator approves them, only approved
+// reviews count towards the product rating.
+type Review struct {
+	Model
+	ProductID uint   `json:"product_id" gorm:"uniqueIndex:idx_reviews_product_user"`
+	UserID    string `json:"user_id" gorm:"uniqueIndex:idx_reviews_product_user"`
+	Rating    int    `json:"rating"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	Status    string `json:"status" gorm:"index"`
+}
+
+type ReviewInput struct {
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type ReviewStatusInput struct {
+	Status string `json:"status"`
+}
+
+type ReviewPage struct {
+	Data  []Review `json:"data"`
+	Page  int      `json:"page"`
+	Limit int      `json:"limit"`
+	Total int64    `json:"total"`
+}
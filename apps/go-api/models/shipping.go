@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+const (
+	ShippingFlat     = "flat"
+	ShippingWeight   = "weight"
+	ShippingFreeOver = "free_over"
+)
+
+// ShippingZone groups destination countries. Countries is a comma separated
+// list of ISO codes, "*" matches every country not covered by another zone.
+type ShippingZone struct {
+	Model
+	Name      string           `json:"name"`
+	Countries string           `json:"countries"`
+	Methods   []ShippingMethod `json:"methods,omitempty" gorm:"foreignKey:ZoneID"`
+}
+
+type ShippingZoneInput struct {
+	Name      string `json:"name"`
+	Countries string `json:"countries"`
+}
+
+// ShippingMethod prices delivery within a zone. Flat methods charge BaseRate,
+// weight methods add PerKgRate for every started kilogram and free_over
+// methods are free once the cart total reaches FreeThreshold. Amounts are in
+// the base currency.
+type ShippingMethod struct {
+	Model
+	ZoneID         uint   `json:"zone_id" gorm:"index"`
+	Name           string `json:"name"`
+	Carrier        string `json:"carrier"`
+	Type           string `json:"type"`
+	BaseRate       Money  `json:"base_rate" swaggertype:"number"`
+	PerKgRate      Money  `json:"per_kg_rate" swaggertype:"number"`
+	FreeThreshold  Money  `json:"free_threshold" swaggertype:"number"`
+	MaxWeightGrams int    `json:"max_weight_grams"`
+	IsActive       bool   `json:"is_active"`
+}
+
+type ShippingMethodInput struct {
+	Name           string `json:"name"`
+	Carrier        string `json:"carrier"`
+	Type           string `json:"type"`
+	BaseRate       Money  `json:"base_rate" swaggertype:"number"`
+	PerKgRate      Money  `json:"per_kg_rate" swaggertype:"number"`
+	FreeThreshold  Money  `json:"free_threshold" swaggertype:"number"`
+	MaxWeightGrams int    `json:"max_weight_grams"`
+	IsActive       bool   `json:"is_active"`
+}
+
+type ShippingQuote struct {
+	MethodID    uint   `json:"method_id"`
+	Name        string `json:"name"`
+	Carrier     string `json:"carrier"`
+	WeightGrams int    `json:"weight_grams"`
+	Rate        Money  `json:"rate" swaggertype:"number"`
+	Currency    string `json:"currency"`
+}
+
+const (
+	ShipmentLabelCreated   = "label_created"
+	ShipmentInTransit      = "in_transit"
+	ShipmentOutForDelivery = "out_for_delivery"
+	ShipmentDelivered      = "delivered"
+	ShipmentException      = "exception"
+	ShipmentReturned       = "returned"
+)
+
+type Shipment struct {
+	Model
+	OrderID        uint            `json:"order_id" gorm:"index"`
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"tracking_number" gorm:"index"`
+	Status         string          `json:"status"`
+	Events         []ShipmentEvent `json:"events,omitempty"`
+}
+
+type ShipmentInput struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+type ShipmentEvent struct {
+	Model
+	ShipmentID  uint      `json:"shipment_id" gorm:"index"`
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+type ShipmentEventInput struct {
+	Status      string     `json:"status"`
+	Description string     `json:"description"`
+	Location    string     `json:"location"`
+	OccurredAt  *time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	SubscriptionBackInStock = "back_in_stock"
+	SubscriptionPriceDrop   = "price_drop"
+)
+
+// ProductSubscription asks for a single notification when a product comes
+// back in stock or its price falls to TargetPrice or below. NotifiedAt is set
+// once the notification is sent, subscribing again re-arms it.
+type ProductSubscription struct {
+	Model
+	UserID      string `json:"user_id" gorm:"uniqueIndex:idx_product_subscriptions_user_product_type"`
+	ProductID   uint   `json:"product_id" gorm:"uniqueIndex:idx_product_subscriptions_user_product_type;index"`
+	Type        string `json:"type" gorm:"uniqueIndex:idx_product_subscriptions_user_product_type"`
+	TargetPrice *Money `json:"target_price,omitempty" swaggertype:"number"`
+	// TargetCurrency is the currency of TargetPrice, the product's currency
+	// for subscriptions made before it was stored.
+	TargetCurrency string     `json:"target_currency,omitempty" gorm:"size:3"`
+	NotifiedAt     *time.Time `json:"notified_at"`
+}
+
+type ProductSubscriptionInput struct {
+	Type        string `json:"type"`
+	TargetPrice *Money `json:"target_price" swaggertype:"number"`
+	// TargetCurrency defaults to the product's currency.
+	TargetCurrency string `json:"target_currency"`
+}
@@ -0,0 +1,50 @@
+package models
+
+const TaxClassStandard = "standard"
+
+// TaxRule sets the rate for a tax class in a country, optionally narrowed to
+// a region. Rates are in basis points, 1800 meaning 18%. Inclusive rules
+// treat the catalog price as already containing the tax.
+type TaxRule struct {
+	Model
+	Country   string `json:"country" gorm:"size:2;index"`
+	Region    string `json:"region" gorm:"size:64"`
+	TaxClass  string `json:"tax_class" gorm:"size:32"`
+	Name      string `json:"name"`
+	RateBps   int    `json:"rate_bps"`
+	Inclusive bool   `json:"inclusive"`
+}
+
+type TaxRuleInput struct {
+	Country   string `json:"country"`
+	Region    string `json:"region"`
+	TaxClass  string `json:"tax_class"`
+	Name      string `json:"name"`
+	RateBps   int    `json:"rate_bps"`
+	Inclusive bool   `json:"inclusive"`
+}
+
+type TaxLine struct {
+	ProductID uint   `json:"product_id"`
+	TaxClass  string `json:"tax_class"`
+	Name      string `json:"name"`
+	RateBps   int    `json:"rate_bps"`
+	Inclusive bool   `json:"inclusive"`
+	Net       Money  `json:"net" swaggertype:"number"`
+	Tax       Money  `json:"tax" swaggertype:"number"`
+	Gross     Money  `json:"gross" swaggertype:"number"`
+}
+
+type TaxBreakdown struct {
+	Provider string    `json:"provider"`
+	Currency string    `json:"currency"`
+	Lines    []TaxLine `json:"lines"`
+	Net      Money     `json:"net" swaggertype:"number"`
+	Tax      Money     `json:"tax" swaggertype:"number"`
+	Gross    Money     `json:"gross" swaggertype:"number"`
+}
+
+type TaxQuote struct {
+	Cart Cart         `json:"cart"`
+	Tax  TaxBreakdown `json:"tax"`
+}
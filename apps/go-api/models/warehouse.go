@@ -0,0 +1,67 @@
+package models
+
+// Warehouse holds stock. When several warehouses can fulfil an order line,
+// those in the destination country are preferred, then the lowest Priority.
+type Warehouse struct {
+	Model
+	Code     string `json:"code" gorm:"uniqueIndex;size:32"`
+	Name     string `json:"name"`
+	Country  string `json:"country" gorm:"size:2"`
+	Priority int    `json:"priority"`
+	IsActive bool   `json:"is_active"`
+}
+
+type WarehouseInput struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Country  string `json:"country"`
+	Priority int    `json:"priority"`
+	IsActive bool   `json:"is_active"`
+}
+
+// WarehouseStock is the stock of a product in a warehouse. Product.Stock is
+// kept as the sum over all warehouses for products stocked this way.
+type WarehouseStock struct {
+	Model
+	WarehouseID uint      `json:"warehouse_id" gorm:"uniqueIndex:idx_warehouse_stocks_warehouse_product"`
+	ProductID   uint      `json:"product_id" gorm:"uniqueIndex:idx_warehouse_stocks_warehouse_product;index"`
+	Quantity    int       `json:"quantity"`
+	Warehouse   Warehouse `json:"warehouse,omitempty"`
+}
+
+type WarehouseStockInput struct {
+	Quantity int `json:"quantity"`
+}
+
+type StockTransfer struct {
+	Model
+	ProductID       uint   `json:"product_id" gorm:"index"`
+	FromWarehouseID uint   `json:"from_warehouse_id"`
+	ToWarehouseID   uint   `json:"to_warehouse_id"`
+	Quantity        int    `json:"quantity"`
+	Note            string `json:"note"`
+}
+
+type StockTransferInput struct {
+	ProductID       uint   `json:"product_id"`
+	FromWarehouseID uint   `json:"from_warehouse_id"`
+	ToWarehouseID   uint   `json:"to_warehouse_id"`
+	Quantity        int    `json:"quantity"`
+	Note            string `json:"note"`
+}
+
+// OrderAllocation records how much of an order item is shipped from which
+// warehouse, so cancelled orders return stock where it came from.
+type OrderAllocation struct {
+	Model
+	OrderID     uint `json:"order_id" gorm:"index"`
+	ProductID   uint `json:"product_id"`
+	WarehouseID uint `json:"warehouse_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+type ProductStock struct {
+	ProductID  uint             `json:"product_id"`
+	Total      int              `json:"total"`
+	Warehouses []WarehouseStock `json:"warehouses"`
+}
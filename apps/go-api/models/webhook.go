@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// Webhook event types.
+const (
+	WebhookProductCreated     = "product.created"
+	WebhookProductUpdated     = "product.updated"
+	WebhookProductDeleted     = "product.deleted"
+	WebhookStockChanged       = "stock.changed"
+	WebhookOrderCreated       = "order.created"
+	WebhookOrderStatusChanged = "order.status_changed"
+)
+
+// WebhookAllEvents subscribes to every event type.
+const WebhookAllEvents = "*"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription sends the events listed in Events, a comma separated
+// list, to a partner URL. Deliveries are signed with Secret, which is only
+// returned when the subscription is created or the secret is rotated.
+type WebhookSubscription struct {
+	Model
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Events   string `json:"events" example:"product.updated,stock.changed"`
+	Secret   string `json:"-"`
+	IsActive bool   `json:"is_active"`
+}
+
+// WebhookSubscriptionInput creates or replaces a subscription. A random
+// secret is generated when Secret is empty on creation, on update an empty
+// Secret keeps the current one.
+type WebhookSubscriptionInput struct {
+	Name     string `json:"name"`
+	URL      string `json:"url" example:"https://partner.example.com/hooks/store"`
+	Events   string `json:"events" example:"product.updated,stock.changed"`
+	Secret   string `json:"secret"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// NewWebhookSubscription carries the signing secret, returned only when it
+// was set.
+type NewWebhookSubscription struct {
+	WebhookSubscription
+	Secret string `json:"secret,omitempty" example:"whsec_Zm9vYmFyYmF6cXV4cXV1eGNvcmdlZ3JhdWx0"`
+}
+
+// WebhookDelivery is an event queued for one subscription and the log of
+// its attempts. Order and stock deliveries are written in the transaction
+// of the change they report, so they are sent if and only if the change
+// committed. Product deliveries are queued right after the product change
+// commits and are lost if the process stops in between. Replaying a
+// delivery queues a copy with the same event ID.
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"index"`
+	EventID        string     `json:"event_id" gorm:"size:36;index"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `json:"response_body,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	ReplayOf       *uint      `json:"replay_of,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// WebhookEvent is the JSON body of a delivery.
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// StockLevel is the data of a stock.changed event.
+type StockLevel struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Stock     int    `json:"stock"`
+}
+
+// OrderStatusChange is the data of an order.status_changed event.
+type OrderStatusChange struct {
+	OrderID uint   `json:"order_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// Wishlist is a named list of products saved by a user. Lists are private
+// until shared, sharing gives the list a token that lets anyone view it.
+type Wishlist struct {
+	Model
+	UserID     string         `json:"user_id" gorm:"index"`
+	Name       string         `json:"name"`
+	ShareToken *string        `json:"share_token,omitempty" gorm:"uniqueIndex;size:64"`
+	Items      []WishlistItem `json:"items,omitempty"`
+}
+
+type WishlistItem struct {
+	Model
+	WishlistID uint    `json:"wishlist_id" gorm:"uniqueIndex:idx_wishlist_items_list_product"`
+	ProductID  uint    `json:"product_id" gorm:"uniqueIndex:idx_wishlist_items_list_product"`
+	Product    Product `json:"product" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type WishlistInput struct {
+	Name string `json:"name"`
+}
+
+type WishlistItemInput struct {
+	ProductID uint `json:"product_id"`
+}
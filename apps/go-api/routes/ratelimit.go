@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"go-api/config"
+	"go-api/core/ratelimit"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Rate limit policies. Reads get the most room, searches and writes less and
+// bulk operations, which touch many rows per request, the least. Payment
+// provider webhooks arrive anonymously in bursts and are signed, they get a
+// policy of their own so a busy provider is not throttled like a client.
+const (
+	ReadPolicy    = "read"
+	SearchPolicy  = "search"
+	WritePolicy   = "write"
+	BulkPolicy    = "bulk"
+	WebhookPolicy = "webhook"
+)
+
+// RateLimitPolicies returns the limits per minute of every policy. Each can
+// be overridden with RATE_LIMIT_<POLICY>_<TIER>, for example
+// RATE_LIMIT_SEARCH_USER=200.
+func RateLimitPolicies() map[string]ratelimit.Policy {
+	defaults := map[string]map[ratelimit.Tier]int{
+		ReadPolicy:    {ratelimit.Anonymous: 120, ratelimit.User: 600, ratelimit.Admin: 3000, ratelimit.Integration: 1200},
+		SearchPolicy:  {ratelimit.Anonymous: 30, ratelimit.User: 120, ratelimit.Admin: 600, ratelimit.Integration: 300},
+		WritePolicy:   {ratelimit.Anonymous: 20, ratelimit.User: 120, ratelimit.Admin: 1200, ratelimit.Integration: 600},
+		BulkPolicy:    {ratelimit.Anonymous: 5, ratelimit.User: 10, ratelimit.Admin: 60, ratelimit.Integration: 30},
+		WebhookPolicy: {ratelimit.Anonymous: 1200, ratelimit.User: 1200, ratelimit.Admin: 1200, ratelimit.Integration: 1200},
+	}
+
+	policies := make(map[string]ratelimit.Policy, len(defaults))
+	for name, limits := range defaults {
+		policy := ratelimit.Policy{Window: time.Minute, Limits: make(map[ratelimit.Tier]int, len(limits))}
+		for tier, limit := range limits {
+			key := "RATE_LIMIT_" + strings.ToUpper(name) + "_" + strings.ToUpper(string(tier))
+			policy.Limits[tier] = config.GetInt(key, limit)
+		}
+		policies[name] = policy
+	}
+	return policies
+}
+
+// RateLimitPolicy classifies a request before it is routed. Probes, metrics
+// and the API docs are not limited.
+func RateLimitPolicy(c *fiber.Ctx) string {
+	path := strings.TrimSuffix(c.Path(), "/")
+	switch {
+	case path == "/livez", path == "/readyz", path == "/metrics",
+		strings.HasPrefix(path, "/swagger"):
+		return ""
+	case path == "/api/v1/products/bulk-update",
+		path == "/api/v1/admin/catalog/imports" && c.Method() == fiber.MethodPost,
+		path == "/api/v1/admin/catalog/export":
+		return BulkPolicy
+	case path == "/api/v1/payments/webhook" && c.Method() == fiber.MethodPost:
+		return WebhookPolicy
+	}
+
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead:
+		if path == "/api/v1/products/search" || path == "/api/v1/products/price" ||
+			(path == "/api/v1/products" && c.Query("q") != "") {
+			return SearchPolicy
+		}
+		return ReadPolicy
+	case fiber.MethodOptions:
+		return ""
+	}
+	return WritePolicy
+}
@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"go-api/core/ratelimit"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRateLimitPolicy(t *testing.T) {
+	tests := []struct {
+		method string
+		uri    string
+		want   string
+	}{
+		{fiber.MethodGet, "/livez", ""},
+		{fiber.MethodGet, "/metrics", ""},
+		{fiber.MethodGet, "/swagger/index.html", ""},
+		{fiber.MethodOptions, "/api/v1/products", ""},
+		{fiber.MethodGet, "/api/v1/products", ReadPolicy},
+		{fiber.MethodGet, "/api/v1/products/", ReadPolicy},
+		{fiber.MethodGet, "/api/v1/products?q=shoe", SearchPolicy},
+		{fiber.MethodGet, "/api/v1/products/search", SearchPolicy},
+		{fiber.MethodPost, "/api/v1/orders", WritePolicy},
+		{fiber.MethodPost, "/api/v1/products/bulk-update", BulkPolicy},
+		{fiber.MethodPost, "/api/v1/admin/catalog/imports", BulkPolicy},
+		{fiber.MethodGet, "/api/v1/admin/catalog/imports", ReadPolicy},
+		{fiber.MethodPost, "/api/v1/payments/webhook", WebhookPolicy},
+		{fiber.MethodGet, "/api/v1/payments/webhook", ReadPolicy},
+	}
+
+	app := fiber.New()
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.uri, func(t *testing.T) {
+			var request fasthttp.RequestCtx
+			request.Request.Header.SetMethod(tt.method)
+			request.Request.SetRequestURI(tt.uri)
+			ctx := app.AcquireCtx(&request)
+			defer app.ReleaseCtx(ctx)
+
+			if got := RateLimitPolicy(ctx); got != tt.want {
+				t.Errorf("RateLimitPolicy(%s %s) = %q, want %q", tt.method, tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitPoliciesOverride(t *testing.T) {
+	t.Setenv("RATE_LIMIT_WEBHOOK_ANONYMOUS", "5000")
+
+	policies := RateLimitPolicies()
+	for _, name := range []string{ReadPolicy, SearchPolicy, WritePolicy, BulkPolicy, WebhookPolicy} {
+		if _, ok := policies[name]; !ok {
+			t.Errorf("RateLimitPolicies() has no %s policy", name)
+		}
+	}
+	if got := policies[WebhookPolicy].Limits[ratelimit.Anonymous]; got != 5000 {
+		t.Errorf("webhook anonymous limit = %d, want 5000", got)
+	}
+	if got := policies[WritePolicy].Limits[ratelimit.Anonymous]; got != 20 {
+		t.Errorf("write anonymous limit = %d, want 20", got)
+	}
+}
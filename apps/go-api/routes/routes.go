@@ -1,24 +1,200 @@
 package routes
 
 import (
+	"context"
+	"go-api/config"
+	adminController "go-api/controller/admin"
+	apiKeyController "go-api/controller/apikey"
+	attributeController "go-api/controller/attribute"
+	catalogController "go-api/controller/catalog"
 	categoryController "go-api/controller/category"
+	healthController "go-api/controller/health"
+	imageController "go-api/controller/image"
+	inventoryController "go-api/controller/inventory"
+	orderController "go-api/controller/order"
+	paymentController "go-api/controller/payment"
 	productController "go-api/controller/product"
+	promotionController "go-api/controller/promotion"
+	reviewController "go-api/controller/review"
+	shippingController "go-api/controller/shipping"
+	subscriptionController "go-api/controller/subscription"
+	taxController "go-api/controller/tax"
+	warehouseController "go-api/controller/warehouse"
+	webhookController "go-api/controller/webhook"
+	wishlistController "go-api/controller/wishlist"
+	"go-api/core/cache"
+	"go-api/core/logging"
+	"go-api/core/storage"
 	"go-api/database"
+	"go-api/middleware"
+	"go-api/models"
+	apiKeyService "go-api/services/apikey"
+	attributeService "go-api/services/attribute"
+	cartService "go-api/services/cart"
+	catalogService "go-api/services/catalog"
 	categoryService "go-api/services/category"
+	currencyService "go-api/services/currency"
+	healthService "go-api/services/health"
+	imageService "go-api/services/image"
+	inventoryService "go-api/services/inventory"
+	notificationService "go-api/services/notification"
+	orderService "go-api/services/order"
+	paymentService "go-api/services/payment"
+	pricingService "go-api/services/pricing"
 	productService "go-api/services/product"
+	promotionService "go-api/services/promotion"
+	reviewService "go-api/services/review"
+	shippingService "go-api/services/shipping"
+	subscriptionService "go-api/services/subscription"
+	taxService "go-api/services/tax"
+	warehouseService "go-api/services/warehouse"
+	webhookService "go-api/services/webhook"
+	wishlistService "go-api/services/wishlist"
+	"log/slog"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRoutes(app *fiber.App) {
+// Services are the services built by SetupRoutes that main's background
+// jobs share, so their writes drop the same cached reads.
+type Services struct {
+	Products   productService.ProductService
+	Categories *categoryService.CategoryService
+	Orders     orderService.OrderService
+	// Notifications is closed on shutdown to send the queued notifications.
+	Notifications *notificationService.NotificationService
+}
+
+func SetupRoutes(app *fiber.App) Services {
 
 	db := database.DB
 
-	prodService := productService.NewProductService(db)
-	catService := categoryService.NewCategoryService(db)
-	prodController := productController.NewProductController(prodService, *catService, db)
+	curService, err := currencyService.NewCurrencyService(config.Get("BASE_CURRENCY"), config.Get("CURRENCY_RATES"))
+	if err != nil {
+		logging.Fatal("Invalid currency configuration", "error", err)
+	}
+
+	store, err := storage.New(storage.Config{
+		Driver:    config.Get("STORAGE_DRIVER"),
+		LocalDir:  config.GetString("STORAGE_LOCAL_DIR", "./uploads"),
+		PublicURL: config.GetString("STORAGE_PUBLIC_URL", "/uploads"),
+		S3: storage.S3Config{
+			Endpoint:  config.Get("S3_ENDPOINT"),
+			Region:    config.Get("S3_REGION"),
+			Bucket:    config.Get("S3_BUCKET"),
+			AccessKey: config.Get("S3_ACCESS_KEY"),
+			SecretKey: config.Get("S3_SECRET_KEY"),
+			PublicURL: config.Get("S3_PUBLIC_URL"),
+		},
+	})
+	if err != nil {
+		logging.Fatal("Invalid storage configuration", "error", err)
+	}
+	if local, ok := store.(*storage.LocalStorage); ok {
+		app.Static(local.BaseURL, local.Dir)
+	}
+
+	publisher := notificationService.NewRabbitPublisher(config.GetString("NOTIFICATION_QUEUE", "notification_queue"))
+	notifService := notificationService.NewNotificationService(db, publisher, curService, config.GetInt("NOTIFICATION_QUEUE_SIZE", 1000))
+	invService := inventoryService.NewInventoryService(db, publisher)
+	// Deliveries are sent by the worker started in main, this instance only
+	// queues and replays them.
+	hookService := webhookService.NewWebhookService(db, webhookService.Options{})
+	prodService := productService.NewProductService(db, curService, store, notifService, invService, hookService)
+	catService := categoryService.NewCategoryService(db, notifService, invService, hookService)
+
+	// Caching is off unless CACHE_DRIVER is memory or redis.
+	var readCache cache.Cache
+	if driver := config.Get("CACHE_DRIVER"); driver != "" && driver != "none" {
+		readCache, err = cache.New(cache.Config{
+			Driver:     driver,
+			RedisURL:   config.Get("REDIS_URL"),
+			MaxEntries: config.GetInt("CACHE_MAX_ENTRIES", 10000),
+		})
+		if err != nil {
+			logging.Fatal("Invalid cache configuration", "error", err)
+		}
+		ttl := time.Duration(config.GetInt("CACHE_TTL_SECONDS", 60)) * time.Second
+		prodService = productService.NewCachedProductService(prodService, readCache, ttl)
+		catService.UseCache(readCache, ttl)
+	}
+
+	// Writers that bypass the product service drop its cached reads
+	// themselves. Warehouses queue their stock webhooks within their own
+	// transaction, they leave the webhook service out.
+	var invalidators []productService.ProductInvalidator
+	stockListeners := []productService.ProductListener{notifService, invService}
+	catalogListeners := []productService.ProductListener{notifService, invService, hookService}
+	if readCache != nil {
+		invalidator := productService.CacheInvalidator{Cache: readCache}
+		invalidators = append(invalidators, invalidator)
+		stockListeners = append(stockListeners, invalidator)
+		catalogListeners = append(catalogListeners, invalidator)
+	}
+
+	priceService := pricingService.NewPricingService(db, curService, invalidators...)
+	prodController := productController.NewProductController(prodService, priceService)
+
+	catController := categoryController.NewCategoryController(catService, prodService, priceService)
+
+	crtService := cartService.NewCartService(db, priceService, curService)
+	promoService := promotionService.NewPromotionService(db, curService)
+	promoController := promotionController.NewPromotionController(promoService, crtService)
+
+	taxProvider, err := taxService.NewTaxProvider(config.Get("TAX_PROVIDER"), db, config.GetInt("TAX_STUB_RATE_BPS", 2000))
+	if err != nil {
+		logging.Fatal("Invalid tax configuration", "error", err)
+	}
+	txService := taxService.NewTaxService(db, taxProvider)
+	txController := taxController.NewTaxController(txService, crtService, promoService)
+	shipService := shippingService.NewShippingService(db, curService)
+	shipController := shippingController.NewShippingController(shipService, crtService, promoService)
+	whService := warehouseService.NewWarehouseService(db, hookService, stockListeners...)
+	whController := warehouseController.NewWarehouseController(whService)
+	ordService := orderService.NewOrderService(db, crtService, promoService, shipService, txService, whService, hookService)
+	ordController := orderController.NewOrderController(ordService)
+
+	payProvider, err := paymentService.NewPaymentProvider(config.Get("PAYMENT_PROVIDER"), config.Get("PAYMENT_WEBHOOK_SECRET"))
+	if err != nil {
+		logging.Fatal("Invalid payment configuration", "error", err)
+	}
+	payService := paymentService.NewPaymentService(db, payProvider, ordService, config.Get("PAYMENT_CAPTURE") != "manual")
+	payController := paymentController.NewPaymentController(payService)
+	revService := reviewService.NewReviewService(db, invalidators...)
+	revController := reviewController.NewReviewController(revService)
+	wishService := wishlistService.NewWishlistService(db)
+	wishController := wishlistController.NewWishlistController(wishService)
+	subService := subscriptionService.NewSubscriptionService(db, curService)
+	subController := subscriptionController.NewSubscriptionController(subService)
+	invController := inventoryController.NewInventoryController(invService)
+
+	imgService := imageService.NewImageService(db, store, imageService.Options{
+		MaxBytes:      config.GetInt("IMAGE_MAX_BYTES", 5<<20),
+		MaxDimension:  config.GetInt("IMAGE_MAX_DIMENSION", 2000),
+		ThumbnailSize: config.GetInt("IMAGE_THUMBNAIL_SIZE", 300),
+	}, invalidators...)
+	imgController := imageController.NewImageController(imgService)
+	ctlgService := catalogService.NewCatalogService(db, curService, catalogListeners...)
+	if err := ctlgService.FailInterruptedImports(context.Background()); err != nil {
+		slog.Error("Error failing interrupted imports", "error", err)
+	}
+	ctlgController := catalogController.NewCatalogController(ctlgService)
+	attrService := attributeService.NewAttributeService(db, invalidators...)
+	attrController := attributeController.NewAttributeController(attrService)
+	admController := adminController.NewAdminController(prodService, catService)
+	hlthService := healthService.NewHealthService(db, healthService.Options{
+		Models:     database.Models,
+		MigratedAt: database.MigratedAt,
+		Timeout:    time.Duration(config.GetInt("HEALTH_CHECK_TIMEOUT_MS", 2000)) * time.Millisecond,
+	})
+	hlthController := healthController.NewHealthController(hlthService)
+	keyService := apiKeyService.NewAPIKeyService(db)
+	keyController := apiKeyController.NewAPIKeyController(keyService)
+	hookController := webhookController.NewWebhookController(hookService)
 
-	catController := categoryController.NewCategoryController(catService)
+	app.Get("/livez", hlthController.GetLiveness)
+	app.Get("/readyz", hlthController.GetReadiness)
 
 	api := app.Group("/api/v1")
 
@@ -26,6 +202,12 @@ func SetupRoutes(app *fiber.App) {
 	productRoutes.Get("/price", prodController.GetProductsByPriceRange)
 	productRoutes.Patch("/bulk-update", prodController.BulkUpdatePrices)
 	productRoutes.Patch("/:id/stock", prodController.UpdateProductStock)
+	productRoutes.Get("/:id/prices", prodController.GetProductPrices)
+	productRoutes.Get("/:id/images", imgController.GetProductImages)
+	productRoutes.Get("/:id/attributes", attrController.GetProductAttributes)
+	productRoutes.Get("/:id/reviews", revController.GetProductReviews)
+	productRoutes.Post("/:id/reviews", middleware.Protected(), revController.CreateReview)
+	productRoutes.Post("/:id/subscriptions", middleware.Protected(), subController.Subscribe)
 	productRoutes.Get("/", prodController.GetAllProducts)
 	productRoutes.Post("/", prodController.CreateProduct)
 	productRoutes.Get("/:id", prodController.GetProductByID)
@@ -33,9 +215,125 @@ func SetupRoutes(app *fiber.App) {
 	productRoutes.Delete("/:id", prodController.DeleteProduct)
 
 	categoryRoutes := api.Group("/categories")
-	categoryRoutes.Get("/:categoryId", catController.GetProductsByCategory)
 	categoryRoutes.Get("/", catController.GetAllCategories)
 	categoryRoutes.Post("/", catController.CreateCategory)
+	categoryRoutes.Get("/:id/products", catController.GetProductsByCategory)
+	categoryRoutes.Get("/:id/attributes", attrController.GetCategoryAttributes)
 	categoryRoutes.Get("/:id", catController.GetCategoryByID)
 	categoryRoutes.Delete("/:id", catController.DeleteCategory)
+
+	// Integration routes take an admin token or an API key with the scope
+	// of the route. They are registered before the admin group so its
+	// token-only check does not run for them.
+	keyAuth := middleware.Authenticated(keyService)
+	integrationRoutes := api.Group("/admin")
+	integrationRoutes.Get("/catalog/export", keyAuth, middleware.RequireScope(models.ScopeCatalogRead, "ADMIN"), ctlgController.ExportCatalog)
+	integrationRoutes.Get("/inventory/low-stock", keyAuth, middleware.RequireScope(models.ScopeInventoryRead, "ADMIN"), invController.GetLowStockReport)
+	integrationRoutes.Get("/warehouses", keyAuth, middleware.RequireScope(models.ScopeInventoryRead, "ADMIN"), whController.GetWarehouses)
+	integrationRoutes.Get("/warehouses/:id/stock", keyAuth, middleware.RequireScope(models.ScopeInventoryRead, "ADMIN"), whController.GetWarehouseStock)
+	integrationRoutes.Put("/warehouses/:id/stock/:productId", keyAuth, middleware.RequireScope(models.ScopeInventoryWrite, "ADMIN"), whController.SetWarehouseStock)
+	integrationRoutes.Get("/products/:id/stock", keyAuth, middleware.RequireScope(models.ScopeInventoryRead, "ADMIN"), whController.GetProductStock)
+	integrationRoutes.Get("/stock-transfers", keyAuth, middleware.RequireScope(models.ScopeInventoryRead, "ADMIN"), whController.GetStockTransfers)
+	integrationRoutes.Post("/stock-transfers", keyAuth, middleware.RequireScope(models.ScopeInventoryWrite, "ADMIN"), whController.CreateStockTransfer)
+	integrationRoutes.Get("/orders", keyAuth, middleware.RequireScope(models.ScopeOrdersRead, "ADMIN"), ordController.GetAllOrders)
+	integrationRoutes.Get("/orders/:id/payments", keyAuth, middleware.RequireScope(models.ScopeOrdersRead, "ADMIN"), payController.GetOrderPayments)
+
+	adminRoutes := api.Group("/admin", middleware.Protected(), middleware.RequireRole("ADMIN"))
+	adminRoutes.Get("/api-keys", keyController.GetAPIKeys)
+	adminRoutes.Post("/api-keys", keyController.CreateAPIKey)
+	adminRoutes.Delete("/api-keys/:id", keyController.RevokeAPIKey)
+	adminRoutes.Get("/webhooks", hookController.GetSubscriptions)
+	adminRoutes.Post("/webhooks", hookController.CreateSubscription)
+	adminRoutes.Get("/webhooks/:id", hookController.GetSubscription)
+	adminRoutes.Put("/webhooks/:id", hookController.UpdateSubscription)
+	adminRoutes.Delete("/webhooks/:id", hookController.DeleteSubscription)
+	adminRoutes.Get("/webhooks/:id/deliveries", hookController.GetDeliveries)
+	adminRoutes.Post("/webhook-deliveries/:id/replay", hookController.ReplayDelivery)
+	adminRoutes.Get("/trash/products", admController.GetDeletedProducts)
+	adminRoutes.Post("/trash/products/:id/restore", admController.RestoreProduct)
+	adminRoutes.Delete("/trash/products/:id", admController.PurgeProduct)
+	adminRoutes.Get("/trash/categories", admController.GetDeletedCategories)
+	adminRoutes.Post("/trash/categories/:id/restore", admController.RestoreCategory)
+	adminRoutes.Delete("/trash/categories/:id", admController.PurgeCategory)
+	adminRoutes.Put("/products/:id/prices/:currency", prodController.SetProductPrice)
+	adminRoutes.Delete("/products/:id/prices/:currency", prodController.DeleteProductPrice)
+	adminRoutes.Post("/products/:id/images", imgController.UploadProductImage)
+	adminRoutes.Put("/products/:id/images/order", imgController.ReorderProductImages)
+	adminRoutes.Patch("/products/:id/images/:imageId", imgController.UpdateProductImage)
+	adminRoutes.Delete("/products/:id/images/:imageId", imgController.DeleteProductImage)
+	adminRoutes.Put("/products/:id/attributes", attrController.SetProductAttributes)
+	adminRoutes.Post("/categories/:id/attributes", attrController.CreateCategoryAttribute)
+	adminRoutes.Put("/attributes/:id", attrController.UpdateCategoryAttribute)
+	adminRoutes.Delete("/attributes/:id", attrController.DeleteCategoryAttribute)
+	adminRoutes.Post("/catalog/imports", ctlgController.StartImport)
+	adminRoutes.Get("/catalog/imports", ctlgController.GetImportJobs)
+	adminRoutes.Get("/catalog/imports/:id", ctlgController.GetImportJob)
+	adminRoutes.Post("/warehouses", whController.CreateWarehouse)
+	adminRoutes.Put("/warehouses/:id", whController.UpdateWarehouse)
+	adminRoutes.Delete("/warehouses/:id", whController.DeleteWarehouse)
+	adminRoutes.Get("/promotions", promoController.GetPromotions)
+	adminRoutes.Post("/promotions", promoController.CreatePromotion)
+	adminRoutes.Get("/promotions/:id", promoController.GetPromotionByID)
+	adminRoutes.Put("/promotions/:id", promoController.UpdatePromotion)
+	adminRoutes.Delete("/promotions/:id", promoController.DeletePromotion)
+	adminRoutes.Get("/tax-rules", txController.GetTaxRules)
+	adminRoutes.Post("/tax-rules", txController.CreateTaxRule)
+	adminRoutes.Put("/tax-rules/:id", txController.UpdateTaxRule)
+	adminRoutes.Delete("/tax-rules/:id", txController.DeleteTaxRule)
+	adminRoutes.Get("/shipping/zones", shipController.GetShippingZones)
+	adminRoutes.Post("/shipping/zones", shipController.CreateShippingZone)
+	adminRoutes.Put("/shipping/zones/:id", shipController.UpdateShippingZone)
+	adminRoutes.Delete("/shipping/zones/:id", shipController.DeleteShippingZone)
+	adminRoutes.Post("/shipping/zones/:id/methods", shipController.CreateShippingMethod)
+	adminRoutes.Put("/shipping/methods/:id", shipController.UpdateShippingMethod)
+	adminRoutes.Delete("/shipping/methods/:id", shipController.DeleteShippingMethod)
+	adminRoutes.Patch("/orders/:id/status", ordController.UpdateOrderStatus)
+	adminRoutes.Post("/orders/:id/shipments", ordController.CreateShipment)
+	adminRoutes.Post("/shipments/:id/events", ordController.AddShipmentEvent)
+	adminRoutes.Post("/payments/:id/capture", payController.CapturePayment)
+	adminRoutes.Post("/payments/:id/refund", payController.RefundPayment)
+	adminRoutes.Post("/payments/:id/void", payController.VoidPayment)
+
+	moderationRoutes := api.Group("/moderation", middleware.Protected(), middleware.RequireRole("ADMIN", "MODERATOR"))
+	moderationRoutes.Get("/reviews", revController.GetReviewsForModeration)
+	moderationRoutes.Patch("/reviews/:id/status", revController.ModerateReview)
+
+	promotionRoutes := api.Group("/promotions", middleware.Protected())
+	promotionRoutes.Post("/validate", promoController.ValidateCart)
+
+	taxRoutes := api.Group("/tax")
+	taxRoutes.Post("/calculate", txController.CalculateTax)
+
+	shippingRoutes := api.Group("/shipping")
+	shippingRoutes.Post("/quote", shipController.QuoteShipping)
+
+	orderRoutes := api.Group("/orders", middleware.Protected())
+	orderRoutes.Post("/", ordController.PlaceOrder)
+	orderRoutes.Get("/", ordController.GetOrders)
+	orderRoutes.Get("/:id", ordController.GetOrderByID)
+	orderRoutes.Post("/:id/cancel", ordController.CancelOrder)
+	orderRoutes.Post("/:id/pay", payController.PayOrder)
+
+	api.Get("/shipments/:tracking_number", ordController.TrackShipment)
+
+	wishlistRoutes := api.Group("/wishlists", middleware.Protected())
+	wishlistRoutes.Get("/", wishController.GetWishlists)
+	wishlistRoutes.Post("/", wishController.CreateWishlist)
+	wishlistRoutes.Get("/:id", wishController.GetWishlistByID)
+	wishlistRoutes.Put("/:id", wishController.RenameWishlist)
+	wishlistRoutes.Delete("/:id", wishController.DeleteWishlist)
+	wishlistRoutes.Post("/:id/items", wishController.AddWishlistItem)
+	wishlistRoutes.Delete("/:id/items/:productId", wishController.RemoveWishlistItem)
+	wishlistRoutes.Post("/:id/share", wishController.ShareWishlist)
+	wishlistRoutes.Delete("/:id/share", wishController.UnshareWishlist)
+	api.Get("/shared/wishlists/:token", wishController.GetSharedWishlist)
+
+	subscriptionRoutes := api.Group("/subscriptions", middleware.Protected())
+	subscriptionRoutes.Get("/", subController.GetSubscriptions)
+	subscriptionRoutes.Delete("/:id", subController.Unsubscribe)
+
+	paymentRoutes := api.Group("/payments")
+	paymentRoutes.Post("/webhook", payController.HandleWebhook)
+
+	return Services{Products: prodService, Categories: catService, Orders: ordService, Notifications: notifService}
 }
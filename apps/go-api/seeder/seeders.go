@@ -1,9 +1,10 @@
 package seeders
 
 import (
-	"fmt"
+	"go-api/core/logging"
 	"go-api/models"
-	"log"
+	"log/slog"
+	"math"
 
 	"github.com/bxcodec/faker/v3"
 	"gorm.io/gorm"
@@ -16,37 +17,39 @@ func SeedCategories(db *gorm.DB, count int) {
 		}
 		result := db.Create(&category)
 		if result.Error != nil {
-			log.Fatalf("Could not seed category: %v", result.Error)
+			logging.Fatal("Could not seed category", "error", result.Error)
 		}
 	}
-	fmt.Printf("%d categories seeded successfully\n", count)
+	slog.Info("Categories seeded", "count", count)
 }
 
-func SeedProducts(db *gorm.DB, count int) {
+// SeedProducts creates count products priced in currency.
+func SeedProducts(db *gorm.DB, count int, currency string) {
 	var categories []models.Category
 	db.Find(&categories)
 
 	if len(categories) == 0 {
-		log.Fatal("No categories found. Seed categories first.")
+		logging.Fatal("No categories found. Seed categories first.")
 	}
 
 	for i := 0; i < count; i++ {
 		product := models.Product{
 			Name:          faker.Word(),
 			Description:   faker.Sentence(),
-			Price:         faker.Latitude(),
+			Price:         models.MoneyFromFloat(math.Abs(faker.Latitude())),
 			Quantity:      0,
 			Image:         faker.URL(),
 			CategoryID:    categories[i%len(categories)].ID,
 			DiscountPrice: nil,
+			Currency:      currency,
 			IsActive:      true,
 			Stock:         0,
 			SKU:           faker.UUIDDigit(),
 		}
 		result := db.Create(&product)
 		if result.Error != nil {
-			log.Fatalf("Could not seed product: %v", result.Error)
+			logging.Fatal("Could not seed product", "error", result.Error)
 		}
 	}
-	fmt.Printf("%d products seeded successfully\n", count)
+	slog.Info("Products seeded", "count", count)
 }
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-api/models"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+	ErrAPIKeyUnauthorized = errors.New("api key rejected")
+)
+
+// Scopes are the scopes a key can be granted.
+var Scopes = []string{
+	models.ScopeCatalogRead,
+	models.ScopeInventoryRead,
+	models.ScopeInventoryWrite,
+	models.ScopeOrdersRead,
+}
+
+// keyPrefix starts every key so leaked keys are easy to scan for.
+const keyPrefix = "gk_"
+
+// lastUsedResolution limits how often using a key writes its last use.
+const lastUsedResolution = time.Minute
+
+type APIKeyService interface {
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	CreateAPIKey(ctx context.Context, input models.APIKeyInput, createdBy string) (models.NewAPIKey, error)
+	RevokeAPIKey(ctx context.Context, id uint) (models.APIKey, error)
+	Authenticate(ctx context.Context, key string) (models.APIKey, error)
+}
+
+type apiKeyService struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) APIKeyService {
+	return &apiKeyService{DB: db}
+}
+
+func (s *apiKeyService) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := s.DB.WithContext(ctx).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// CreateAPIKey generates a key of the form gk_<id>_<secret>. The id is
+// stored as the key prefix, the whole key only as a SHA-256 hash: keys are
+// random, so a slow password hash would add nothing.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, input models.APIKeyInput, createdBy string) (models.NewAPIKey, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return models.NewAPIKey{}, fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	scopes, err := parseScopes(input.Scopes)
+	if err != nil {
+		return models.NewAPIKey{}, err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return models.NewAPIKey{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return models.NewAPIKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return models.NewAPIKey{}, err
+	}
+	prefix := keyPrefix + hex.EncodeToString(id)
+	key := prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := models.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hashKey(key),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := s.DB.WithContext(ctx).Create(&apiKey).Error; err != nil {
+		return models.NewAPIKey{}, err
+	}
+	return models.NewAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// RevokeAPIKey disables a key for good. The record is kept so its use can
+// still be traced.
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id uint) (models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.DB.WithContext(ctx).First(&apiKey, id).Error; err != nil {
+		return models.APIKey{}, err
+	}
+	if apiKey.RevokedAt != nil {
+		return apiKey, nil
+	}
+
+	now := time.Now()
+	if err := s.DB.WithContext(ctx).Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+		return models.APIKey{}, err
+	}
+	apiKey.RevokedAt = &now
+	return apiKey, nil
+}
+
+// Authenticate returns the key matching key when it is neither revoked nor
+// expired, and records its use.
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (models.APIKey, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0]+"_" != keyPrefix {
+		return models.APIKey{}, fmt.Errorf("%w: malformed key", ErrAPIKeyUnauthorized)
+	}
+
+	var apiKey models.APIKey
+	err := s.DB.WithContext(ctx).Where("prefix = ?", keyPrefix+parts[1]).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.APIKey{}, fmt.Errorf("%w: unknown key", ErrAPIKeyUnauthorized)
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(apiKey.Hash)) != 1 {
+		return models.APIKey{}, fmt.Errorf("%w: unknown key", ErrAPIKeyUnauthorized)
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil {
+		return models.APIKey{}, fmt.Errorf("%w: key was revoked", ErrAPIKeyUnauthorized)
+	}
+	if apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt) {
+		return models.APIKey{}, fmt.Errorf("%w: key expired", ErrAPIKeyUnauthorized)
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+		// UpdateColumn leaves updated_at alone, it tracks edits of the key.
+		if err := s.DB.WithContext(ctx).Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+			slog.WarnContext(ctx, "Error recording api key use", "api_key_id", apiKey.ID, "error", err)
+		}
+		apiKey.LastUsedAt = &now
+	}
+	return apiKey, nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseScopes validates a comma separated list of scopes and returns it
+// sorted without duplicates.
+func parseScopes(list string) (string, error) {
+	known := make(map[string]bool, len(Scopes))
+	for _, scope := range Scopes {
+		known[scope] = true
+	}
+
+	seen := map[string]bool{}
+	var scopes []string
+	for _, scope := range strings.Split(list, ",") {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !known[scope] {
+			return "", fmt.Errorf("%w: unknown scope %q, expected one of %s", ErrInvalidAPIKey, scope, strings.Join(Scopes, ", "))
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	sort.Strings(scopes)
+	return strings.Join(scopes, ","), nil
+}
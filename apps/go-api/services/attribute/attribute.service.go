@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	productService "go-api/services/product"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAttribute      = errors.New("invalid attribute")
+	ErrInvalidAttributeValue = errors.New("invalid attribute values")
+	ErrAttributeInUse        = errors.New("attribute has product values")
+)
+
+var attributeCode = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type AttributeService interface {
+	GetCategoryAttributes(ctx context.Context, categoryID uint) ([]models.CategoryAttribute, error)
+	CreateAttribute(ctx context.Context, categoryID uint, input models.CategoryAttributeInput) (models.CategoryAttribute, error)
+	UpdateAttribute(ctx context.Context, id uint, input models.CategoryAttributeInput) (models.CategoryAttribute, error)
+	DeleteAttribute(ctx context.Context, id uint) error
+	GetProductAttributes(ctx context.Context, productID uint) ([]models.ProductAttributeValue, error)
+	SetProductAttributes(ctx context.Context, productID uint, values map[string]interface{}) ([]models.ProductAttributeValue, error)
+}
+
+type attributeService struct {
+	DB           *gorm.DB
+	Invalidators []productService.ProductInvalidator
+}
+
+// NewAttributeService creates the attribute service. The invalidators are
+// told about the products whose attributes changed.
+func NewAttributeService(db *gorm.DB, invalidators ...productService.ProductInvalidator) AttributeService {
+	return &attributeService{DB: db, Invalidators: invalidators}
+}
+
+func (s *attributeService) GetCategoryAttributes(ctx context.Context, categoryID uint) ([]models.CategoryAttribute, error) {
+	if err := s.DB.WithContext(ctx).First(&models.Category{}, categoryID).Error; err != nil {
+		return nil, err
+	}
+	return s.attributes(ctx, categoryID)
+}
+
+func (s *attributeService) CreateAttribute(ctx context.Context, categoryID uint, input models.CategoryAttributeInput) (models.CategoryAttribute, error) {
+	if err := s.DB.WithContext(ctx).First(&models.Category{}, categoryID).Error; err != nil {
+		return models.CategoryAttribute{}, err
+	}
+
+	attribute := models.CategoryAttribute{CategoryID: categoryID}
+	if err := applyAttributeInput(&attribute, input); err != nil {
+		return models.CategoryAttribute{}, err
+	}
+	if err := s.checkCode(ctx, attribute); err != nil {
+		return models.CategoryAttribute{}, err
+	}
+	if err := s.DB.WithContext(ctx).Create(&attribute).Error; err != nil {
+		return models.CategoryAttribute{}, err
+	}
+	return attribute, nil
+}
+
+// UpdateAttribute replaces an attribute definition. Its type cannot change
+// while products have a value for it.
+func (s *attributeService) UpdateAttribute(ctx context.Context, id uint, input models.CategoryAttributeInput) (models.CategoryAttribute, error) {
+	var attribute models.CategoryAttribute
+	if err := s.DB.WithContext(ctx).First(&attribute, id).Error; err != nil {
+		return models.CategoryAttribute{}, err
+	}
+
+	previousType := attribute.Type
+	if err := applyAttributeInput(&attribute, input); err != nil {
+		return models.CategoryAttribute{}, err
+	}
+	if attribute.Type != previousType {
+		var count int64
+		if err := s.DB.WithContext(ctx).Model(&models.ProductAttributeValue{}).Where("attribute_id = ?", id).Count(&count).Error; err != nil {
+			return models.CategoryAttribute{}, err
+		}
+		if count > 0 {
+			return models.CategoryAttribute{}, fmt.Errorf("%w: its type cannot change", ErrAttributeInUse)
+		}
+	}
+	if err := s.checkCode(ctx, attribute); err != nil {
+		return models.CategoryAttribute{}, err
+	}
+	if err := s.DB.WithContext(ctx).Save(&attribute).Error; err != nil {
+		return models.CategoryAttribute{}, err
+	}
+	// Products carry the definitions of their attributes.
+	s.invalidate()
+	return attribute, nil
+}
+
+// DeleteAttribute removes an attribute together with the product values
+// set for it.
+func (s *attributeService) DeleteAttribute(ctx context.Context, id uint) error {
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attribute_id = ?", id).Delete(&models.ProductAttributeValue{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&models.CategoryAttribute{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *attributeService) GetProductAttributes(ctx context.Context, productID uint) ([]models.ProductAttributeValue, error) {
+	if err := s.DB.WithContext(ctx).Select("id").First(&models.Product{}, productID).Error; err != nil {
+		return nil, err
+	}
+	return s.values(s.DB.WithContext(ctx), productID)
+}
+
+// SetProductAttributes replaces the attribute values of a product. Every
+// code has to be an attribute of the product category, values must match
+// the attribute type and required attributes cannot be left out.
+func (s *attributeService) SetProductAttributes(ctx context.Context, productID uint, values map[string]interface{}) ([]models.ProductAttributeValue, error) {
+	var product models.Product
+	if err := s.DB.WithContext(ctx).Select("id", "category_id").First(&product, productID).Error; err != nil {
+		return nil, err
+	}
+	attributes, err := s.attributes(ctx, product.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]models.CategoryAttribute, len(attributes))
+	for _, attribute := range attributes {
+		byCode[attribute.Code] = attribute
+	}
+
+	var problems []string
+	for code := range values {
+		if _, ok := byCode[code]; !ok {
+			problems = append(problems, code+": not an attribute of the product category")
+		}
+	}
+	sort.Strings(problems)
+
+	rows := []models.ProductAttributeValue{}
+	for _, attribute := range attributes {
+		raw := values[attribute.Code]
+		if raw == nil {
+			if attribute.Required {
+				problems = append(problems, attribute.Code+": is required")
+			}
+			continue
+		}
+
+		row := models.ProductAttributeValue{ProductID: productID, AttributeID: attribute.ID}
+		if err := typedValue(&row, attribute, raw); err != nil {
+			problems = append(problems, attribute.Code+": "+err.Error())
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAttributeValue, strings.Join(problems, "; "))
+	}
+
+	var saved []models.ProductAttributeValue
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ProductAttributeValue{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		saved, err = s.values(tx, productID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(productID)
+	return saved, nil
+}
+
+// invalidate tells the invalidators about changed products, all of them when
+// no id is given.
+func (s *attributeService) invalidate(productIDs ...uint) {
+	for _, invalidator := range s.Invalidators {
+		invalidator.InvalidateProducts(productIDs...)
+	}
+}
+
+func (s *attributeService) attributes(ctx context.Context, categoryID uint) ([]models.CategoryAttribute, error) {
+	attributes := []models.CategoryAttribute{}
+	err := s.DB.WithContext(ctx).Where("category_id = ?", categoryID).Order("position").Order("id").Find(&attributes).Error
+	return attributes, err
+}
+
+func (s *attributeService) values(tx *gorm.DB, productID uint) ([]models.ProductAttributeValue, error) {
+	values := []models.ProductAttributeValue{}
+	err := tx.Joins("Attribute").
+		Where("product_attribute_values.product_id = ?", productID).
+		Order("Attribute.position").Order("Attribute.id").
+		Find(&values).Error
+	return values, err
+}
+
+// checkCode makes sure no other attribute of the category uses the code.
+func (s *attributeService) checkCode(ctx context.Context, attribute models.CategoryAttribute) error {
+	var count int64
+	err := s.DB.WithContext(ctx).Model(&models.CategoryAttribute{}).
+		Where("category_id = ? AND code = ? AND id <> ?", attribute.CategoryID, attribute.Code, attribute.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: code %q is already used in this category", ErrInvalidAttribute, attribute.Code)
+	}
+	return nil
+}
+
+func applyAttributeInput(attribute *models.CategoryAttribute, input models.CategoryAttributeInput) error {
+	code := strings.ToLower(strings.TrimSpace(input.Code))
+	if !attributeCode.MatchString(code) {
+		return fmt.Errorf("%w: code must start with a letter and contain only a-z, 0-9 and _", ErrInvalidAttribute)
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidAttribute)
+	}
+
+	allowed := []string{}
+	for _, value := range strings.Split(input.AllowedValues, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			allowed = append(allowed, value)
+		}
+	}
+	switch input.Type {
+	case models.AttributeEnum:
+		if len(allowed) == 0 {
+			return fmt.Errorf("%w: an enum needs allowed_values", ErrInvalidAttribute)
+		}
+	case models.AttributeText, models.AttributeNumber, models.AttributeBoolean:
+		if len(allowed) > 0 {
+			return fmt.Errorf("%w: allowed_values only apply to enums", ErrInvalidAttribute)
+		}
+	default:
+		return fmt.Errorf("%w: type must be text, number, boolean or enum", ErrInvalidAttribute)
+	}
+
+	attribute.Code = code
+	attribute.Name = strings.TrimSpace(input.Name)
+	attribute.Type = input.Type
+	attribute.Unit = strings.TrimSpace(input.Unit)
+	attribute.AllowedValues = strings.Join(allowed, ",")
+	attribute.Required = input.Required
+	attribute.Position = input.Position
+	return nil
+}
+
+// typedValue checks raw, as decoded from JSON, against the attribute and
+// stores it in the matching column of row. Numbers and booleans may also be
+// given as strings.
+func typedValue(row *models.ProductAttributeValue, attribute models.CategoryAttribute, raw interface{}) error {
+	switch attribute.Type {
+	case models.AttributeNumber:
+		switch v := raw.(type) {
+		case float64:
+			row.NumberValue = &v
+			return nil
+		case string:
+			if number, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				row.NumberValue = &number
+				return nil
+			}
+		}
+		return errors.New("must be a number")
+	case models.AttributeBoolean:
+		switch v := raw.(type) {
+		case bool:
+			row.BoolValue = &v
+			return nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				row.BoolValue = &b
+				return nil
+			}
+		}
+		return errors.New("must be true or false")
+	}
+
+	text, ok := raw.(string)
+	text = strings.TrimSpace(text)
+	if !ok || text == "" {
+		return errors.New("must be a non-empty string")
+	}
+	if attribute.Type == models.AttributeEnum {
+		for _, allowed := range strings.Split(attribute.AllowedValues, ",") {
+			if allowed == text {
+				row.TextValue = &text
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", attribute.AllowedValues)
+	}
+	row.TextValue = &text
+	return nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	pricingService "go-api/services/pricing"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmptyCart          = errors.New("cart is empty")
+	ErrInvalidQuantity    = errors.New("quantity must be positive")
+	ErrProductUnavailable = errors.New("product is not available")
+	ErrInsufficientStock  = errors.New("insufficient stock")
+)
+
+// CartItemError ties a cart validation error to the offending product.
+type CartItemError struct {
+	ProductID uint
+	Err       error
+}
+
+func (e *CartItemError) Error() string {
+	return fmt.Sprintf("product %d: %v", e.ProductID, e.Err)
+}
+
+func (e *CartItemError) Unwrap() error {
+	return e.Err
+}
+
+type CartService interface {
+	Price(ctx context.Context, input models.CartInput) (models.Cart, error)
+}
+
+type cartService struct {
+	DB              *gorm.DB
+	PricingService  pricingService.PricingService
+	CurrencyService currencyService.CurrencyService
+}
+
+func NewCartService(db *gorm.DB, pricingService pricingService.PricingService, currencyService currencyService.CurrencyService) CartService {
+	return &cartService{DB: db, PricingService: pricingService, CurrencyService: currencyService}
+}
+
+// Price validates the cart against current product data and returns its
+// lines priced in the requested currency, before any promotion.
+func (s *cartService) Price(ctx context.Context, input models.CartInput) (models.Cart, error) {
+	if len(input.Items) == 0 {
+		return models.Cart{}, ErrEmptyCart
+	}
+
+	currency := strings.ToUpper(input.Currency)
+	if currency == "" {
+		currency = s.CurrencyService.BaseCurrency()
+	}
+
+	quantities := map[uint]int{}
+	ids := []uint{}
+	for _, item := range input.Items {
+		if item.Quantity <= 0 {
+			return models.Cart{}, &CartItemError{ProductID: item.ProductID, Err: ErrInvalidQuantity}
+		}
+		if _, ok := quantities[item.ProductID]; !ok {
+			ids = append(ids, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	var products []models.Product
+	if err := s.DB.WithContext(ctx).Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return models.Cart{}, err
+	}
+
+	byID := make(map[uint]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	ordered := make([]models.Product, 0, len(ids))
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok || !product.IsActive {
+			return models.Cart{}, &CartItemError{ProductID: id, Err: ErrProductUnavailable}
+		}
+		if product.Stock < quantities[id] {
+			return models.Cart{}, &CartItemError{ProductID: id, Err: ErrInsufficientStock}
+		}
+		ordered = append(ordered, product)
+	}
+
+	if err := s.PricingService.Localize(ctx, ordered, currency); err != nil {
+		return models.Cart{}, err
+	}
+
+	cart := models.Cart{
+		Currency:   currency,
+		Lines:      make([]models.CartLine, 0, len(ordered)),
+		Promotions: []models.AppliedPromotion{},
+		Rejected:   []models.RejectedCoupon{},
+	}
+
+	for _, product := range ordered {
+		unitPrice := product.Price
+		if product.DiscountPrice != nil && *product.DiscountPrice < unitPrice {
+			unitPrice = *product.DiscountPrice
+		}
+
+		cart.Lines = append(cart.Lines, models.CartLine{
+			ProductID:   product.ID,
+			CategoryID:  product.CategoryID,
+			Name:        product.Name,
+			TaxClass:    product.TaxClass,
+			WeightGrams: product.WeightGrams,
+			Quantity:    quantities[product.ID],
+			UnitPrice:   unitPrice,
+		})
+	}
+
+	cart.Recalculate()
+	return cart, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"go-api/models"
+	"io"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// exportBatch is how many rows are loaded at a time while streaming.
+const exportBatch = 500
+
+// exportedProduct is a product written with the same field names an import
+// reads, so an export can be imported back.
+type exportedProduct struct {
+	SKU              string        `json:"sku"`
+	Name             string        `json:"name"`
+	Description      string        `json:"description"`
+	Price            models.Money  `json:"price"`
+	DiscountPrice    *models.Money `json:"discount_price"`
+	Currency         string        `json:"currency"`
+	Category         string        `json:"category"`
+	CategoryID       uint          `json:"category_id"`
+	Stock            int           `json:"stock"`
+	IsActive         bool          `json:"is_active"`
+	TaxClass         string        `json:"tax_class"`
+	WeightGrams      int           `json:"weight_grams"`
+	LengthMm         int           `json:"length_mm"`
+	WidthMm          int           `json:"width_mm"`
+	HeightMm         int           `json:"height_mm"`
+	ReorderThreshold int           `json:"reorder_threshold"`
+	Image            string        `json:"image"`
+}
+
+func (p exportedProduct) csvRecord() []string {
+	discount := ""
+	if p.DiscountPrice != nil {
+		discount = p.DiscountPrice.String()
+	}
+	return []string{
+		p.SKU, p.Name, p.Description, p.Price.String(), discount, p.Currency,
+		p.Category, strconv.FormatUint(uint64(p.CategoryID), 10), strconv.Itoa(p.Stock),
+		strconv.FormatBool(p.IsActive), p.TaxClass, strconv.Itoa(p.WeightGrams),
+		strconv.Itoa(p.LengthMm), strconv.Itoa(p.WidthMm), strconv.Itoa(p.HeightMm),
+		strconv.Itoa(p.ReorderThreshold), p.Image,
+	}
+}
+
+// ValidateExport reports whether an export of entity in format is possible,
+// callers check it before they start streaming.
+func ValidateExport(entity, format string) error {
+	_, err := checkFormat(entity, format)
+	return err
+}
+
+// Export writes the whole catalog of an entity to w, reading it from the
+// database in batches.
+func (s *catalogService) Export(ctx context.Context, w io.Writer, entity, format string) error {
+	fields, err := checkFormat(entity, format)
+	if err != nil {
+		return err
+	}
+
+	var csvWriter *csv.Writer
+	var encoder *json.Encoder
+	if format == models.CatalogCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(fields); err != nil {
+			return err
+		}
+	} else {
+		encoder = json.NewEncoder(w)
+	}
+	write := func(row interface{}, record []string) error {
+		if csvWriter != nil {
+			return csvWriter.Write(record)
+		}
+		return encoder.Encode(row)
+	}
+
+	if entity == models.CatalogCategories {
+		var categories []models.Category
+		err = s.DB.WithContext(ctx).Order("id").FindInBatches(&categories, exportBatch, func(tx *gorm.DB, batch int) error {
+			for _, category := range categories {
+				row := map[string]string{"name": category.Name}
+				if err := write(row, []string{category.Name}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	} else {
+		var products []models.Product
+		err = s.DB.WithContext(ctx).Preload("Category").Order("id").FindInBatches(&products, exportBatch, func(tx *gorm.DB, batch int) error {
+			for _, product := range products {
+				row := exportedProduct{
+					SKU:              product.SKU,
+					Name:             product.Name,
+					Description:      product.Description,
+					Price:            product.Price,
+					DiscountPrice:    product.DiscountPrice,
+					Currency:         product.Currency,
+					Category:         product.Category.Name,
+					CategoryID:       product.CategoryID,
+					Stock:            product.Stock,
+					IsActive:         product.IsActive,
+					TaxClass:         product.TaxClass,
+					WeightGrams:      product.WeightGrams,
+					LengthMm:         product.LengthMm,
+					WidthMm:          product.WidthMm,
+					HeightMm:         product.HeightMm,
+					ReorderThreshold: product.ReorderThreshold,
+					Image:            product.Image,
+				}
+				if err := write(row, row.csvRecord()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// record is one row of an import with its values keyed by catalog field.
+// Empty values are left out, they never overwrite existing data.
+type record struct {
+	Row    int
+	Values map[string]string
+	Err    error
+}
+
+// columnMapper resolves source column names to catalog fields.
+type columnMapper struct {
+	fields  map[string]bool
+	mapping map[string]string
+}
+
+func (m columnMapper) field(column string) string {
+	if field, ok := m.mapping[column]; ok {
+		return field
+	}
+	field := strings.ToLower(strings.TrimSpace(column))
+	if m.fields[field] {
+		return field
+	}
+	return ""
+}
+
+func readRecords(data []byte, format string, mapper columnMapper) ([]record, error) {
+	if format == "csv" {
+		return readCSV(data, mapper)
+	}
+	return readNDJSON(data, mapper)
+}
+
+func readCSV(data []byte, mapper columnMapper) ([]record, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(header))
+	for i, column := range header {
+		fields[i] = mapper.field(column)
+	}
+
+	var records []record
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			records = append(records, record{Row: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		rec := record{Row: line, Values: map[string]string{}}
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			if i < len(fields) && fields[i] != "" && value != "" {
+				rec.Values[fields[i]] = value
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+func readNDJSON(data []byte, mapper columnMapper) ([]record, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var records []record
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var object map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			records = append(records, record{Row: line, Err: errors.New("not a JSON object")})
+			continue
+		}
+
+		rec := record{Row: line, Values: map[string]string{}}
+		for key, raw := range object {
+			field := mapper.field(key)
+			if field == "" {
+				continue
+			}
+			value, err := scalar(raw)
+			if err != nil {
+				rec.Err = fmt.Errorf("%s: %v", key, err)
+				break
+			}
+			if value != "" {
+				rec.Values[field] = value
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+func scalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("must be a string, number or boolean")
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	productService "go-api/services/product"
+	"io"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidImport = errors.New("invalid import")
+
+// ProductFields and CategoryFields are the columns understood by imports
+// and written by exports.
+var (
+	ProductFields = []string{
+		"sku", "name", "description", "price", "discount_price", "currency",
+		"category", "category_id", "stock", "is_active", "tax_class", "weight_grams",
+		"length_mm", "width_mm", "height_mm", "reorder_threshold", "image",
+	}
+	CategoryFields = []string{"name"}
+)
+
+// maxRowErrors caps the errors kept for a job, a broken file would
+// otherwise store one per line.
+const maxRowErrors = 500
+
+// progressEvery is how many rows are processed between progress updates.
+const progressEvery = 50
+
+type CatalogService interface {
+	StartImport(ctx context.Context, data []byte, options models.ImportOptions) (models.ImportJob, error)
+	GetImportJobs(ctx context.Context) ([]models.ImportJob, error)
+	GetImportJob(ctx context.Context, id uint) (models.ImportJob, error)
+	FailInterruptedImports(ctx context.Context) error
+	Export(ctx context.Context, w io.Writer, entity, format string) error
+}
+
+type catalogService struct {
+	DB              *gorm.DB
+	CurrencyService currencyService.CurrencyService
+	Listeners       []productService.ProductListener
+
+	// mu runs imports one at a time.
+	mu sync.Mutex
+}
+
+func NewCatalogService(db *gorm.DB, currencyService currencyService.CurrencyService, listeners ...productService.ProductListener) CatalogService {
+	return &catalogService{DB: db, CurrencyService: currencyService, Listeners: listeners}
+}
+
+// StartImport checks the options, records a pending job and processes the
+// file in the background.
+func (s *catalogService) StartImport(ctx context.Context, data []byte, options models.ImportOptions) (models.ImportJob, error) {
+	if options.Entity == "" {
+		options.Entity = models.CatalogProducts
+	}
+	if options.Format == "" {
+		options.Format = formatFromName(options.FileName)
+	}
+	fields, err := checkFormat(options.Entity, options.Format)
+	if err != nil {
+		return models.ImportJob{}, err
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field] = true
+	}
+	for column, field := range options.Mapping {
+		if field != "" && !known[field] {
+			return models.ImportJob{}, fmt.Errorf("%w: column %q is mapped to unknown field %q", ErrInvalidImport, column, field)
+		}
+	}
+
+	job := models.ImportJob{
+		Entity:   options.Entity,
+		Format:   options.Format,
+		FileName: options.FileName,
+		DryRun:   options.DryRun,
+		Status:   models.ImportPending,
+	}
+	if err := s.DB.WithContext(ctx).Create(&job).Error; err != nil {
+		return models.ImportJob{}, err
+	}
+
+	// The import outlives the request, it keeps its request ID and trace
+	// but not its cancellation.
+	go s.run(context.WithoutCancel(ctx), job, data, columnMapper{fields: known, mapping: options.Mapping})
+	return job, nil
+}
+
+func (s *catalogService) GetImportJobs(ctx context.Context) ([]models.ImportJob, error) {
+	jobs := []models.ImportJob{}
+	err := s.DB.WithContext(ctx).Order("id desc").Find(&jobs).Error
+	return jobs, err
+}
+
+func (s *catalogService) GetImportJob(ctx context.Context, id uint) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := s.DB.WithContext(ctx).Preload("RowErrors", func(db *gorm.DB) *gorm.DB {
+		return db.Order("row").Order("id")
+	}).First(&job, id).Error
+	return job, err
+}
+
+// FailInterruptedImports marks the jobs left unfinished by a previous run
+// of the application as failed, their files are gone.
+func (s *catalogService) FailInterruptedImports(ctx context.Context) error {
+	return s.DB.WithContext(ctx).Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportPending, models.ImportRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportFailed,
+			"error":       "interrupted by a restart",
+			"finished_at": time.Now(),
+		}).Error
+}
+
+func (s *catalogService) run(ctx context.Context, job models.ImportJob, data []byte, mapper columnMapper) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A bad row must not take the whole application down with it.
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "Import panicked", "import_id", job.ID, "panic", fmt.Sprint(r))
+			s.finish(ctx, &job, fmt.Errorf("internal error at row %d", job.ProcessedRows+1))
+		}
+	}()
+
+	started := time.Now()
+	job.Status = models.ImportRunning
+	job.StartedAt = &started
+	s.saveJob(ctx, &job)
+
+	records, err := readRecords(data, job.Format, mapper)
+	if err != nil {
+		s.finish(ctx, &job, err)
+		return
+	}
+	job.TotalRows = len(records)
+	s.saveJob(ctx, &job)
+
+	stored := 0
+	for i, rec := range records {
+		var action string
+		var rowErrors []models.ImportRowError
+		if rec.Err != nil {
+			rowErrors = []models.ImportRowError{{Message: rec.Err.Error()}}
+		} else if job.Entity == models.CatalogCategories {
+			action, rowErrors, err = s.importCategory(ctx, rec, job.DryRun)
+		} else {
+			action, rowErrors, err = s.importProduct(ctx, rec, job.DryRun)
+		}
+		if err != nil {
+			rowErrors = []models.ImportRowError{{Message: "could not be saved"}}
+			slog.WarnContext(ctx, "Error importing row", "import_id", job.ID, "row", rec.Row, "error", err)
+		}
+
+		switch {
+		case len(rowErrors) > 0:
+			job.Failed++
+			for _, rowError := range rowErrors {
+				if stored == maxRowErrors {
+					break
+				}
+				rowError.ImportJobID = job.ID
+				rowError.Row = rec.Row
+				if err := s.DB.WithContext(ctx).Create(&rowError).Error; err != nil {
+					slog.ErrorContext(ctx, "Error saving import row error", "error", err)
+				}
+				stored++
+			}
+		case action == "create":
+			job.Created++
+		case action == "update":
+			job.Updated++
+		default:
+			job.Skipped++
+		}
+
+		job.ProcessedRows = i + 1
+		if job.ProcessedRows%progressEvery == 0 {
+			s.saveJob(ctx, &job)
+		}
+	}
+	s.finish(ctx, &job, nil)
+}
+
+func (s *catalogService) finish(ctx context.Context, job *models.ImportJob, err error) {
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Status = models.ImportCompleted
+	if err != nil {
+		job.Status = models.ImportFailed
+		job.Error = err.Error()
+	}
+	s.saveJob(ctx, job)
+}
+
+func (s *catalogService) saveJob(ctx context.Context, job *models.ImportJob) {
+	if err := s.DB.WithContext(ctx).Omit("RowErrors").Save(job).Error; err != nil {
+		slog.ErrorContext(ctx, "Error saving import job", "error", err)
+	}
+}
+
+func (s *catalogService) importCategory(ctx context.Context, rec record, dryRun bool) (string, []models.ImportRowError, error) {
+	name := rec.Values["name"]
+	if name == "" {
+		return "", []models.ImportRowError{{Field: "name", Message: "is required"}}, nil
+	}
+
+	var count int64
+	if err := s.DB.WithContext(ctx).Model(&models.Category{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return "", nil, err
+	}
+	if count > 0 {
+		return "skip", nil, nil
+	}
+	if !dryRun {
+		if err := s.DB.WithContext(ctx).Create(&models.Category{Name: name}).Error; err != nil {
+			return "", nil, err
+		}
+	}
+	return "create", nil, nil
+}
+
+// importProduct upserts a product by SKU. A new product needs a name, a
+// price and a category, an existing one only gets the fields present in
+// the row. The SKU of a trashed product cannot be imported until the
+// product is restored or purged.
+func (s *catalogService) importProduct(ctx context.Context, rec record, dryRun bool) (string, []models.ImportRowError, error) {
+	values := rec.Values
+	var rowErrors []models.ImportRowError
+	fail := func(field, message string) {
+		rowErrors = append(rowErrors, models.ImportRowError{Field: field, Message: message})
+	}
+
+	sku := values["sku"]
+	if sku == "" {
+		fail("sku", "is required")
+		return "", rowErrors, nil
+	}
+
+	var product models.Product
+	err := s.DB.WithContext(ctx).Where("sku = ?", sku).First(&product).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	before := product
+
+	if !exists {
+		var trashed int64
+		if err := s.DB.WithContext(ctx).Unscoped().Model(&models.Product{}).Where("sku = ?", sku).Count(&trashed).Error; err != nil {
+			return "", nil, err
+		}
+		if trashed > 0 {
+			fail("sku", "belongs to a product in the trash")
+			return "", rowErrors, nil
+		}
+
+		product.SKU = sku
+		product.Currency = s.CurrencyService.BaseCurrency()
+		product.TaxClass = models.TaxClassStandard
+		product.IsActive = true
+		for _, field := range []string{"name", "price"} {
+			if values[field] == "" {
+				fail(field, "is required for a new product")
+			}
+		}
+		if values["category"] == "" && values["category_id"] == "" {
+			fail("category", "is required for a new product")
+		}
+	}
+
+	if name, ok := values["name"]; ok {
+		product.Name = name
+	}
+	if description, ok := values["description"]; ok {
+		product.Description = description
+	}
+	if image, ok := values["image"]; ok {
+		product.Image = image
+	}
+	if taxClass, ok := values["tax_class"]; ok {
+		product.TaxClass = taxClass
+	}
+	if value, ok := values["price"]; ok {
+		if price, err := models.ParseMoney(value); err != nil || price < 0 {
+			fail("price", "must be a non-negative amount with at most two decimals")
+		} else {
+			product.Price = price
+		}
+	}
+	if value, ok := values["discount_price"]; ok {
+		if price, err := models.ParseMoney(value); err != nil || price < 0 {
+			fail("discount_price", "must be a non-negative amount with at most two decimals")
+		} else {
+			product.DiscountPrice = &price
+		}
+	}
+	if value, ok := values["currency"]; ok {
+		if currency := strings.ToUpper(value); s.CurrencyService.Supports(currency) {
+			product.Currency = currency
+		} else {
+			fail("currency", "is not supported")
+		}
+	}
+	if value, ok := values["is_active"]; ok {
+		if active, err := strconv.ParseBool(value); err != nil {
+			fail("is_active", "must be true or false")
+		} else {
+			product.IsActive = active
+		}
+	}
+
+	counts := []struct {
+		field  string
+		target *int
+	}{
+		{"stock", &product.Stock},
+		{"weight_grams", &product.WeightGrams},
+		{"length_mm", &product.LengthMm},
+		{"width_mm", &product.WidthMm},
+		{"height_mm", &product.HeightMm},
+		{"reorder_threshold", &product.ReorderThreshold},
+	}
+	for _, count := range counts {
+		value, ok := values[count.field]
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			fail(count.field, "must be a non-negative integer")
+		} else {
+			*count.target = n
+		}
+	}
+
+	// Exports carry the stock of every product, re-importing one unchanged
+	// is fine.
+	if _, ok := values["stock"]; ok && exists && product.Stock != before.Stock {
+		var levels int64
+		if err := s.DB.WithContext(ctx).Model(&models.WarehouseStock{}).Where("product_id = ?", product.ID).Count(&levels).Error; err != nil {
+			return "", nil, err
+		}
+		if levels > 0 {
+			fail("stock", productService.ErrStockManagedByWarehouses.Error())
+		}
+	}
+
+	if value, ok := values["category_id"]; ok {
+		var category models.Category
+		if id, err := strconv.ParseUint(value, 10, 32); err != nil {
+			fail("category_id", "must be a category ID")
+		} else if err := s.DB.WithContext(ctx).First(&category, id).Error; err != nil {
+			fail("category_id", "does not exist")
+		} else {
+			product.CategoryID = category.ID
+		}
+	} else if name, ok := values["category"]; ok {
+		var category models.Category
+		if err := s.DB.WithContext(ctx).Where("name = ?", name).First(&category).Error; err != nil {
+			fail("category", "does not exist")
+		} else {
+			product.CategoryID = category.ID
+		}
+	}
+
+	action := "create"
+	if exists {
+		action = "update"
+	}
+	if len(rowErrors) > 0 || dryRun {
+		return action, rowErrors, nil
+	}
+
+	if err := s.DB.WithContext(ctx).Save(&product).Error; err != nil {
+		return "", nil, err
+	}
+	for _, listener := range s.Listeners {
+		listener.ProductChanged(ctx, before, product)
+	}
+	return action, nil, nil
+}
+
+// checkFormat validates an entity and format pair and returns the fields of
+// the entity.
+func checkFormat(entity, format string) ([]string, error) {
+	if format != models.CatalogCSV && format != models.CatalogNDJSON {
+		return nil, fmt.Errorf("%w: format must be csv or ndjson", ErrInvalidImport)
+	}
+	switch entity {
+	case models.CatalogProducts:
+		return ProductFields, nil
+	case models.CatalogCategories:
+		return CategoryFields, nil
+	}
+	return nil, fmt.Errorf("%w: entity must be products or categories", ErrInvalidImport)
+}
+
+func formatFromName(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return models.CatalogCSV
+	case ".ndjson", ".jsonl":
+		return models.CatalogNDJSON
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	productService "go-api/services/product"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Warehouse{}, &models.WarehouseStock{})
+}
+
+func TestImportProduct(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     map[string]string
+		wantAction string
+		wantField  string
+		wantBefore int
+		wantAfter  int
+	}{
+		{
+			name:       "create",
+			values:     map[string]string{"sku": "CHAIR-1", "name": "Chair", "price": "49.90", "category": "Furniture", "stock": "3"},
+			wantAction: "create",
+			wantAfter:  3,
+		},
+		{
+			name:       "update stock",
+			values:     map[string]string{"sku": "DESK-1", "stock": "8"},
+			wantAction: "update",
+			wantBefore: 5,
+			wantAfter:  8,
+		},
+		{
+			name:       "unchanged warehouse stock",
+			values:     map[string]string{"sku": "LAMP-1", "name": "Desk lamp", "stock": "10"},
+			wantAction: "update",
+			wantBefore: 10,
+			wantAfter:  10,
+		},
+		{
+			name:      "changed warehouse stock",
+			values:    map[string]string{"sku": "LAMP-1", "stock": "12"},
+			wantField: "stock",
+		},
+		{
+			name:      "sku of a trashed product",
+			values:    map[string]string{"sku": "OLD-1", "name": "Old", "price": "1", "category": "Furniture"},
+			wantField: "sku",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+			category := models.Category{Name: "Furniture"}
+			warehouse := models.Warehouse{Code: "MAIN", Name: "Main", Country: "DE", IsActive: true}
+			dbtest.Create(t, db, &category, &warehouse)
+			lamp := models.Product{Name: "Lamp", SKU: "LAMP-1", Stock: 10, CategoryID: category.ID}
+			desk := models.Product{Name: "Desk", SKU: "DESK-1", Stock: 5, CategoryID: category.ID}
+			old := models.Product{Name: "Old", SKU: "OLD-1", CategoryID: category.ID}
+			dbtest.Create(t, db, &lamp, &desk, &old)
+			dbtest.Create(t, db, &models.WarehouseStock{WarehouseID: warehouse.ID, ProductID: lamp.ID, Quantity: 10})
+			if err := db.Delete(&old).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			currencies, err := currencyService.NewCurrencyService("USD", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			listener := &productService.RecordingListener{}
+			s := NewCatalogService(db, currencies, listener).(*catalogService)
+
+			action, rowErrors, err := s.importProduct(ctx, record{Row: 1, Values: tt.values}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantField != "" {
+				if len(rowErrors) != 1 || rowErrors[0].Field != tt.wantField {
+					t.Fatalf("row errors = %+v, want one on %s", rowErrors, tt.wantField)
+				}
+				if len(listener.Changes) > 0 {
+					t.Errorf("listeners saw %+v for a failed row", listener.Changes)
+				}
+				return
+			}
+			if len(rowErrors) > 0 {
+				t.Fatalf("row errors = %+v", rowErrors)
+			}
+			if action != tt.wantAction {
+				t.Errorf("action = %q, want %q", action, tt.wantAction)
+			}
+
+			if len(listener.Changes) != 1 {
+				t.Fatalf("listeners saw %d changes, want 1", len(listener.Changes))
+			}
+			change := listener.Changes[0]
+			if (change.Before.ID == 0) != (tt.wantAction == "create") {
+				t.Errorf("before = %+v for a %s", change.Before, tt.wantAction)
+			}
+			if change.After.SKU != tt.values["sku"] || change.Before.Stock != tt.wantBefore || change.After.Stock != tt.wantAfter {
+				t.Errorf("change = %+v", change)
+			}
+		})
+	}
+}
+
+func TestProductSKUIsUnique(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		sku      string
+		trashed  bool
+		wantErr  bool
+	}{
+		{name: "same sku", existing: "LAMP-1", sku: "LAMP-1", wantErr: true},
+		{name: "same sku in the trash", existing: "LAMP-1", sku: "LAMP-1", trashed: true, wantErr: true},
+		{name: "other sku", existing: "LAMP-1", sku: "LAMP-2"},
+		{name: "no sku", existing: "", sku: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			existing := dbtest.Product(t, db, models.Product{Name: "Lamp", SKU: tt.existing})
+			if tt.trashed {
+				if err := db.Delete(&existing).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := db.Create(&models.Product{Name: "Other", SKU: tt.sku, CategoryID: existing.CategoryID}).Error
+			if (err != nil) != tt.wantErr {
+				t.Errorf("creating a second product with sku %q: error = %v, want error %v", tt.sku, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,51 +1,278 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"go-api/core/cache"
 	"go-api/models"
+	productService "go-api/services/product"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrCategoryHasProducts   = errors.New("category still has products")
+	ErrInvalidDeleteMode     = errors.New("invalid delete mode")
+	ErrInvalidTargetCategory = errors.New("invalid target category")
+)
+
 type CategoryService struct {
 	DB *gorm.DB
+	// Cache, when set, serves the category reads for up to CacheTTL.
+	Cache    cache.Cache
+	CacheTTL time.Duration
+	// Listeners are told about the products moved or deactivated when a
+	// category is deleted.
+	Listeners []productService.ProductListener
 }
 
-func NewCategoryService(db *gorm.DB) *CategoryService {
+func NewCategoryService(db *gorm.DB, listeners ...productService.ProductListener) *CategoryService {
 	return &CategoryService{
-		DB: db,
+		DB:        db,
+		Listeners: listeners,
 	}
 }
 
-func (s *CategoryService) GetAllCategories() ([]models.Category, error) {
-	var categories []models.Category
-	err := s.DB.Find(&categories).Error
+// UseCache serves the reads from store. Writes made through the service drop
+// the cached categories and products, since both depend on each other.
+func (s *CategoryService) UseCache(store cache.Cache, ttl time.Duration) {
+	s.Cache = store
+	s.CacheTTL = ttl
+}
+
+// cached fills target from the cache, or with load when it is missing.
+func (s *CategoryService) cached(key string, target interface{}, load func() error) error {
+	if s.Cache != nil && cache.GetJSON(s.Cache, cache.CategoriesNamespace+key, target) {
+		return nil
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	if s.Cache != nil {
+		cache.SetJSON(s.Cache, cache.CategoriesNamespace+key, target, s.CacheTTL)
+	}
+	return nil
+}
+
+func (s *CategoryService) invalidate() {
+	if s.Cache == nil {
+		return
+	}
+	s.Cache.DeletePrefix(cache.CategoriesNamespace)
+	s.Cache.DeletePrefix(cache.ProductsNamespace)
+}
+
+// withProductCount selects categories together with the number of
+// non-deleted products assigned to them.
+func (s *CategoryService) withProductCount(ctx context.Context) *gorm.DB {
+	return s.DB.WithContext(ctx).Model(&models.Category{}).
+		Select("categories.*, COUNT(products.id) AS product_count").
+		Joins("LEFT JOIN products ON products.category_id = categories.id AND products.deleted_at IS NULL").
+		Group("categories.id")
+}
+
+func (s *CategoryService) GetAllCategories(ctx context.Context) ([]models.CategoryWithCount, error) {
+	var categories []models.CategoryWithCount
+	err := s.cached("all", &categories, func() error {
+		return s.withProductCount(ctx).Order("categories.id").Scan(&categories).Error
+	})
 	return categories, err
 }
 
-func (s *CategoryService) CreateCategory(category models.Category) (models.Category, error) {
-	err := s.DB.Create(&category).Error
+func (s *CategoryService) CreateCategory(ctx context.Context, category models.Category) (models.Category, error) {
+	err := s.DB.WithContext(ctx).Create(&category).Error
+	s.invalidate()
 	return category, err
 }
 
-func (s *CategoryService) GetCategoryByID(id uint) (*models.Category, error) {
+func (s *CategoryService) GetCategoryByID(ctx context.Context, id uint) (*models.Category, error) {
 	var category models.Category
-	err := s.DB.First(&category, id).Error
+	err := s.cached("id:"+strconv.FormatUint(uint64(id), 10), &category, func() error {
+		return s.DB.WithContext(ctx).First(&category, id).Error
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &category, nil
 }
 
-func (s *CategoryService) DeleteCategory(id uint) error {
-	return s.DB.Delete(&models.Category{}, id).Error
+func (s *CategoryService) GetCategoryWithCount(ctx context.Context, id uint) (*models.CategoryWithCount, error) {
+	var category models.CategoryWithCount
+	err := s.cached("count:"+strconv.FormatUint(uint64(id), 10), &category, func() error {
+		result := s.withProductCount(ctx).Where("categories.id = ?", id).Scan(&category)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
 }
 
-func (s *CategoryService) GetProductsByCategory(categoryId string) ([]models.Product, error) {
-	var products []models.Product
+// DeleteCategory soft deletes a category after dealing with its products
+// according to opts.Mode. With opts.DryRun nothing is written and the result
+// describes what would have changed. Deactivated products stay in the
+// trashed category, hidden from the public until it is restored or they
+// are moved to another one.
+func (s *CategoryService) DeleteCategory(ctx context.Context, id uint, opts models.CategoryDeleteOptions) (models.CategoryDeleteResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = models.CategoryDeleteRestrict
+	}
+
+	result := models.CategoryDeleteResult{
+		CategoryID:       id,
+		Mode:             opts.Mode,
+		DryRun:           opts.DryRun,
+		AffectedProducts: []uint{},
+	}
+
+	var changes []productService.ProductChange
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Category{}, id).Error; err != nil {
+			return err
+		}
 
-	if err := s.DB.Where("category_id = ?", categoryId).Find(&products).Error; err != nil {
+		// Trashed products count too, they are kept from being restored
+		// into the deleted category.
+		var affected []models.Product
+		if err := tx.Unscoped().Where("category_id = ?", id).Order("id").Find(&affected).Error; err != nil {
+			return err
+		}
+		for _, product := range affected {
+			result.AffectedProducts = append(result.AffectedProducts, product.ID)
+		}
+
+		products := tx.Unscoped().Model(&models.Product{}).Where("category_id = ?", id)
+
+		switch opts.Mode {
+		case models.CategoryDeleteRestrict:
+			if len(result.AffectedProducts) > 0 {
+				return ErrCategoryHasProducts
+			}
+		case models.CategoryDeleteReassign:
+			if opts.TargetCategory == 0 || opts.TargetCategory == id {
+				return ErrInvalidTargetCategory
+			}
+			if err := tx.First(&models.Category{}, opts.TargetCategory).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrInvalidTargetCategory
+				}
+				return err
+			}
+			result.TargetCategoryID = opts.TargetCategory
+
+			if !opts.DryRun {
+				if err := products.Update("category_id", opts.TargetCategory).Error; err != nil {
+					return err
+				}
+				changes = productChanges(affected, func(product *models.Product) { product.CategoryID = opts.TargetCategory })
+			}
+		case models.CategoryDeleteDeactivate:
+			if !opts.DryRun {
+				if err := products.Update("is_active", false).Error; err != nil {
+					return err
+				}
+				changes = productChanges(affected, func(product *models.Product) { product.IsActive = false })
+			}
+		default:
+			return ErrInvalidDeleteMode
+		}
+
+		if opts.DryRun {
+			return nil
+		}
+		return tx.Delete(&models.Category{}, id).Error
+	})
+	if err != nil {
+		return models.CategoryDeleteResult{}, err
+	}
+	if !opts.DryRun {
+		s.invalidate()
+	}
+	for _, change := range changes {
+		for _, listener := range s.Listeners {
+			listener.ProductChanged(ctx, change.Before, change.After)
+		}
+	}
+
+	return result, nil
+}
+
+// productChanges applies change to the products that are not trashed, the
+// public never saw the others.
+func productChanges(products []models.Product, change func(product *models.Product)) []productService.ProductChange {
+	var changes []productService.ProductChange
+	for _, before := range products {
+		if before.DeletedAt.Valid {
+			continue
+		}
+		after := before
+		change(&after)
+		changes = append(changes, productService.ProductChange{Before: before, After: after})
+	}
+	return changes
+}
+
+func (s *CategoryService) GetDeletedCategories(ctx context.Context) ([]models.Category, error) {
+	var categories []models.Category
+	err := s.DB.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&categories).Error
+	return categories, err
+}
+
+func (s *CategoryService) RestoreCategory(ctx context.Context, id uint) (*models.Category, error) {
+	var category models.Category
+	if err := s.DB.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&category, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.WithContext(ctx).Unscoped().Model(&category).Update("deleted_at", nil).Error; err != nil {
 		return nil, err
 	}
 
-	return products, nil
+	category.DeletedAt = gorm.DeletedAt{}
+	s.invalidate()
+	return &category, nil
+}
+
+// PurgeCategory permanently removes a soft-deleted category. Categories that
+// are still referenced by products, including trashed ones, are kept.
+func (s *CategoryService) PurgeCategory(ctx context.Context, id uint) error {
+	var category models.Category
+	if err := s.DB.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&category, id).Error; err != nil {
+		return err
+	}
+
+	var productCount int64
+	if err := s.DB.WithContext(ctx).Unscoped().Model(&models.Product{}).Where("category_id = ?", id).Count(&productCount).Error; err != nil {
+		return err
+	}
+	if productCount > 0 {
+		return ErrCategoryHasProducts
+	}
+
+	if err := s.DB.WithContext(ctx).Unscoped().Delete(&category).Error; err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *CategoryService) PurgeDeletedCategories(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	referenced := s.DB.WithContext(ctx).Unscoped().Model(&models.Product{}).Select("category_id").Where("category_id IS NOT NULL")
+
+	result := s.DB.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Where("id NOT IN (?)", referenced).
+		Delete(&models.Category{})
+	if result.RowsAffected > 0 {
+		s.invalidate()
+	}
+	return result.RowsAffected, result.Error
 }
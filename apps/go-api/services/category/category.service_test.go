@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	productService "go-api/services/product"
+	"testing"
+)
+
+func TestDeleteCategoryNotifiesListeners(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         models.CategoryDeleteOptions
+		wantErr      error
+		wantCategory string
+		wantActive   bool
+		wantChanges  int
+	}{
+		{
+			name:    "restrict",
+			opts:    models.CategoryDeleteOptions{Mode: models.CategoryDeleteRestrict},
+			wantErr: ErrCategoryHasProducts,
+		},
+		{
+			name:         "reassign",
+			opts:         models.CategoryDeleteOptions{Mode: models.CategoryDeleteReassign},
+			wantCategory: "target",
+			wantActive:   true,
+			wantChanges:  1,
+		},
+		{
+			name:         "reassign dry run",
+			opts:         models.CategoryDeleteOptions{Mode: models.CategoryDeleteReassign, DryRun: true},
+			wantCategory: "deleted",
+			wantActive:   true,
+		},
+		{
+			name:         "deactivate",
+			opts:         models.CategoryDeleteOptions{Mode: models.CategoryDeleteDeactivate},
+			wantCategory: "deleted",
+			wantChanges:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t, &models.Category{}, &models.Product{})
+			ctx := context.Background()
+			categories := map[string]*models.Category{"deleted": {Name: "Deleted"}, "target": {Name: "Target"}}
+			dbtest.Create(t, db, categories["deleted"], categories["target"])
+			lamp := models.Product{Name: "Lamp", SKU: "LAMP-1", IsActive: true, CategoryID: categories["deleted"].ID}
+			trashed := models.Product{Name: "Old lamp", SKU: "LAMP-0", IsActive: true, CategoryID: categories["deleted"].ID}
+			dbtest.Create(t, db, &lamp, &trashed)
+			if err := db.Delete(&trashed).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			listener := &productService.RecordingListener{}
+			s := NewCategoryService(db, listener)
+			opts := tt.opts
+			if opts.Mode == models.CategoryDeleteReassign {
+				opts.TargetCategory = categories["target"].ID
+			}
+
+			result, err := s.DeleteCategory(ctx, categories["deleted"].ID, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteCategory error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && len(result.AffectedProducts) != 2 {
+				t.Errorf("affected products = %v, want both", result.AffectedProducts)
+			}
+
+			var stored models.Product
+			if err := db.First(&stored, lamp.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr == nil && (stored.CategoryID != categories[tt.wantCategory].ID || stored.IsActive != tt.wantActive) {
+				t.Errorf("product = category %d, active %v", stored.CategoryID, stored.IsActive)
+			}
+
+			if len(listener.Changes) != tt.wantChanges {
+				t.Fatalf("listeners saw %d changes, want %d", len(listener.Changes), tt.wantChanges)
+			}
+			for _, change := range listener.Changes {
+				if change.Before.ID != lamp.ID || change.After.CategoryID != stored.CategoryID || change.After.IsActive != stored.IsActive {
+					t.Errorf("change = %+v, stored %+v", change, stored)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-api/models"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+type CurrencyService interface {
+	BaseCurrency() string
+	Currencies() []string
+	Supports(currency string) bool
+	Rate(currency string) (*big.Rat, error)
+	Convert(amount models.Money, from, to string) (models.Money, error)
+}
+
+type currencyService struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// NewCurrencyService builds the rate table from a list such as
+// "EUR=0.92,TRY=34.25", where each rate is the amount of that currency
+// worth one unit of the base currency.
+func NewCurrencyService(base string, rates string) (CurrencyService, error) {
+	base = strings.ToUpper(strings.TrimSpace(base))
+	if base == "" {
+		base = "USD"
+	}
+
+	s := &currencyService{
+		base:  base,
+		rates: map[string]*big.Rat{base: big.NewRat(1, 1)},
+	}
+
+	for _, entry := range strings.Split(rates, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		code, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid currency rate %q", entry)
+		}
+
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate for currency %q", code)
+		}
+
+		s.rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+
+	return s, nil
+}
+
+func (s *currencyService) BaseCurrency() string {
+	return s.base
+}
+
+func (s *currencyService) Currencies() []string {
+	currencies := make([]string, 0, len(s.rates))
+	for code := range s.rates {
+		currencies = append(currencies, code)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+func (s *currencyService) Supports(currency string) bool {
+	_, ok := s.rates[strings.ToUpper(currency)]
+	return ok
+}
+
+// Rate returns the amount of currency worth one unit of the base currency.
+func (s *currencyService) Rate(currency string) (*big.Rat, error) {
+	rate, ok := s.rates[strings.ToUpper(currency)]
+	if !ok {
+		return nil, ErrUnsupportedCurrency
+	}
+	return new(big.Rat).Set(rate), nil
+}
+
+// Convert converts through the base currency using exact rational arithmetic
+// and rounds the result half away from zero to the nearest minor unit.
+func (s *currencyService) Convert(amount models.Money, from, to string) (models.Money, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := s.rates[from]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	toRate, ok := s.rates[to]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+
+	result := new(big.Rat).SetInt64(int64(amount))
+	result.Quo(result, fromRate)
+	result.Mul(result, toRate)
+
+	return models.Money(roundRat(result)), nil
+}
+
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	quo, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+
+	if rem.Mul(rem, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo.Int64()
+}
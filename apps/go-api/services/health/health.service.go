@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/core/rabbitmq"
+	"go-api/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type HealthService interface {
+	Liveness() models.HealthReport
+	Readiness(ctx context.Context) models.HealthReport
+}
+
+type Options struct {
+	// Models are the tables the migrations create.
+	Models []interface{}
+	// MigratedAt is when the migrations completed, zero if they have not.
+	MigratedAt time.Time
+	// Timeout bounds each check.
+	Timeout time.Duration
+}
+
+type check func(ctx context.Context) (map[string]interface{}, error)
+
+type healthService struct {
+	DB      *gorm.DB
+	Options Options
+}
+
+func NewHealthService(db *gorm.DB, options Options) HealthService {
+	if options.Timeout <= 0 {
+		options.Timeout = 2 * time.Second
+	}
+	return &healthService{DB: db, Options: options}
+}
+
+// Liveness only tells the process is serving requests, a dependency being
+// down must not get it restarted.
+func (s *healthService) Liveness() models.HealthReport {
+	return models.HealthReport{Status: models.HealthUp}
+}
+
+// Readiness runs every check concurrently and reports up only when all of
+// them pass.
+func (s *healthService) Readiness(ctx context.Context) models.HealthReport {
+	checks := map[string]check{
+		"database":   s.checkDatabase,
+		"migrations": s.checkMigrations,
+		"rabbitmq":   s.checkRabbitMQ,
+	}
+
+	report := models.HealthReport{
+		Status:     models.HealthUp,
+		Components: make(map[string]models.HealthComponent, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, run := range checks {
+		wg.Add(1)
+		go func(name string, run check) {
+			defer wg.Done()
+			component := s.run(ctx, run)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = component
+			if component.Status != models.HealthUp {
+				report.Status = models.HealthDown
+			}
+		}(name, run)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (s *healthService) run(ctx context.Context, run check) models.HealthComponent {
+	ctx, cancel := context.WithTimeout(ctx, s.Options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := run(ctx)
+	component := models.HealthComponent{
+		Status:    models.HealthUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		component.Status = models.HealthDown
+		component.Error = err.Error()
+	}
+	return component
+}
+
+// checkDatabase pings Postgres through the pool and reports its usage. A
+// pool with every connection busy and callers waiting is still up, the
+// wait counts show it.
+func (s *healthService) checkDatabase(ctx context.Context) (map[string]interface{}, error) {
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := sqlDB.Stats()
+	details := map[string]interface{}{
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"max_open_connections": stats.MaxOpenConnections,
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+	}
+	return details, sqlDB.PingContext(ctx)
+}
+
+// checkMigrations verifies that the migrations ran and that every table they
+// create is present.
+func (s *healthService) checkMigrations(ctx context.Context) (map[string]interface{}, error) {
+	if s.Options.MigratedAt.IsZero() {
+		return nil, errors.New("migrations have not run")
+	}
+	details := map[string]interface{}{
+		"migrated_at": s.Options.MigratedAt.UTC().Format(time.RFC3339),
+		"tables":      len(s.Options.Models),
+	}
+
+	tables, err := s.DB.WithContext(ctx).Migrator().GetTables()
+	if err != nil {
+		return details, err
+	}
+	present := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		present[table] = true
+	}
+
+	var missing []string
+	for _, model := range s.Options.Models {
+		stmt := &gorm.Statement{DB: s.DB}
+		if err := stmt.Parse(model); err != nil {
+			return details, err
+		}
+		if !present[stmt.Schema.Table] {
+			missing = append(missing, stmt.Schema.Table)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		details["missing_tables"] = missing
+		return details, fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return details, nil
+}
+
+// checkRabbitMQ reports the broker connection and the token consumer.
+func (s *healthService) checkRabbitMQ(context.Context) (map[string]interface{}, error) {
+	connected, consuming := rabbitmq.Connected(), rabbitmq.Consuming()
+	details := map[string]interface{}{
+		"connected": connected,
+		"consuming": consuming,
+	}
+
+	switch {
+	case !connected:
+		return details, errors.New("not connected to the broker")
+	case !consuming:
+		return details, errors.New("consumer is not running")
+	}
+	return details, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// fit returns the size of a w x h image scaled down to fit in a limit x
+// limit box, the size itself when it already fits.
+func fit(w, h, limit int) (int, int) {
+	if w <= limit && h <= limit {
+		return w, h
+	}
+	if w >= h {
+		return limit, max(1, h*limit/w)
+	}
+	return max(1, w*limit/h), limit
+}
+
+// resize shrinks src to w x h by averaging the source pixels covered by each
+// destination pixel, which keeps thumbnails free of aliasing.
+func resize(src image.Image, w, h int) *image.NRGBA {
+	bounds := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(in, in.Bounds(), src, bounds.Min, draw.Src)
+
+	sw, sh := in.Bounds().Dx(), in.Bounds().Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := max((x+1)*sw/w, x0+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					r += int(px[0])
+					g += int(px[1])
+					b += int(px[2])
+					a += int(px[3])
+					n++
+				}
+			}
+
+			i := out.PixOffset(x, y)
+			out.Pix[i] = uint8(r / n)
+			out.Pix[i+1] = uint8(g / n)
+			out.Pix[i+2] = uint8(b / n)
+			out.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return out
+}
+
+// encode writes img as JPEG, or as PNG for formats that may carry
+// transparency, and returns the bytes with their content type.
+func encode(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
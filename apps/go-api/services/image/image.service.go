@@ -0,0 +1,352 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-api/core/storage"
+	"go-api/models"
+	productService "go-api/services/product"
+	"image"
+	"log/slog"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidImage         = errors.New("invalid image")
+	ErrImageTooLarge        = errors.New("image is too large")
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+)
+
+// allowedTypes maps the accepted content types, sniffed from the file
+// itself, to the extension they are stored with.
+var allowedTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// maxPixels bounds the decoded size of an upload, a small file can declare
+// dimensions that would not fit in memory.
+const maxPixels = 50_000_000
+
+type Options struct {
+	MaxBytes      int
+	MaxDimension  int
+	ThumbnailSize int
+}
+
+type ImageService interface {
+	GetImages(ctx context.Context, productID uint) ([]models.ProductImage, error)
+	UploadImage(ctx context.Context, productID uint, data []byte, altText string) (models.ProductImage, error)
+	UpdateImage(ctx context.Context, productID, imageID uint, input models.ProductImageUpdateInput) (models.ProductImage, error)
+	ReorderImages(ctx context.Context, productID uint, imageIDs []uint) ([]models.ProductImage, error)
+	DeleteImage(ctx context.Context, productID, imageID uint) error
+}
+
+type imageService struct {
+	DB           *gorm.DB
+	Storage      storage.Storage
+	Options      Options
+	Invalidators []productService.ProductInvalidator
+}
+
+// NewImageService creates the image service. The invalidators are told about
+// the products whose images changed.
+func NewImageService(db *gorm.DB, store storage.Storage, options Options, invalidators ...productService.ProductInvalidator) ImageService {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = 5 << 20
+	}
+	if options.MaxDimension <= 0 {
+		options.MaxDimension = 2000
+	}
+	if options.ThumbnailSize <= 0 {
+		options.ThumbnailSize = 300
+	}
+	return &imageService{DB: db, Storage: store, Options: options, Invalidators: invalidators}
+}
+
+func (s *imageService) GetImages(ctx context.Context, productID uint) ([]models.ProductImage, error) {
+	if err := s.DB.WithContext(ctx).Select("id").First(&models.Product{}, productID).Error; err != nil {
+		return nil, err
+	}
+	return s.images(s.DB.WithContext(ctx), productID)
+}
+
+// UploadImage validates the file, stores it with a thumbnail, shrinking it
+// first when it is larger than the configured dimension, and appends it to
+// the images of the product.
+func (s *imageService) UploadImage(ctx context.Context, productID uint, data []byte, altText string) (models.ProductImage, error) {
+	if len(data) == 0 {
+		return models.ProductImage{}, fmt.Errorf("%w: empty file", ErrInvalidImage)
+	}
+	if len(data) > s.Options.MaxBytes {
+		return models.ProductImage{}, fmt.Errorf("%w: the limit is %d bytes", ErrImageTooLarge, s.Options.MaxBytes)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedTypes[contentType]; !ok {
+		return models.ProductImage{}, fmt.Errorf("%w: %s", ErrUnsupportedImageType, contentType)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return models.ProductImage{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return models.ProductImage{}, fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, config.Width, config.Height)
+	}
+
+	if err := s.DB.WithContext(ctx).Select("id").First(&models.Product{}, productID).Error; err != nil {
+		return models.ProductImage{}, err
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return models.ProductImage{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	width, height := config.Width, config.Height
+	if w, h := fit(width, height, s.Options.MaxDimension); w != width || h != height {
+		data, contentType, err = encode(resize(decoded, w, h), format)
+		if err != nil {
+			return models.ProductImage{}, err
+		}
+		width, height = w, h
+	}
+
+	w, h := fit(config.Width, config.Height, s.Options.ThumbnailSize)
+	thumbnail, thumbnailType, err := encode(resize(decoded, w, h), format)
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	base := fmt.Sprintf("products/%d/%s", productID, name)
+	img := models.ProductImage{
+		ProductID:    productID,
+		AltText:      altText,
+		ContentType:  contentType,
+		Size:         len(data),
+		Width:        width,
+		Height:       height,
+		StorageKey:   base + "." + allowedTypes[contentType],
+		ThumbnailKey: base + "_thumb." + allowedTypes[thumbnailType],
+	}
+	img.URL = s.Storage.URL(img.StorageKey)
+	img.ThumbnailURL = s.Storage.URL(img.ThumbnailKey)
+
+	if err := s.Storage.Put(img.StorageKey, data, contentType); err != nil {
+		return models.ProductImage{}, err
+	}
+	if err := s.Storage.Put(img.ThumbnailKey, thumbnail, thumbnailType); err != nil {
+		s.removeFiles(ctx, img.StorageKey)
+		return models.ProductImage{}, err
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+			return err
+		}
+		img.Position = int(count)
+		if err := tx.Create(&img).Error; err != nil {
+			return err
+		}
+		return syncCover(tx, productID, "")
+	})
+	if err != nil {
+		s.removeFiles(ctx, img.StorageKey, img.ThumbnailKey)
+		return models.ProductImage{}, err
+	}
+	s.invalidate(productID)
+	return img, nil
+}
+
+// UpdateImage changes the alt text of an image and, when a position is
+// given, moves it there shifting the images in between.
+func (s *imageService) UpdateImage(ctx context.Context, productID, imageID uint, input models.ProductImageUpdateInput) (models.ProductImage, error) {
+	var img models.ProductImage
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).First(&img, imageID).Error; err != nil {
+			return err
+		}
+		if input.AltText != nil {
+			img.AltText = *input.AltText
+			if err := tx.Model(&img).Update("alt_text", img.AltText).Error; err != nil {
+				return err
+			}
+		}
+		if input.Position == nil {
+			return nil
+		}
+
+		images, err := s.images(tx, productID)
+		if err != nil {
+			return err
+		}
+		if *input.Position < 0 || *input.Position >= len(images) {
+			return fmt.Errorf("%w: position must be between 0 and %d", ErrInvalidImage, len(images)-1)
+		}
+
+		ordered := make([]models.ProductImage, 0, len(images))
+		for _, other := range images {
+			if other.ID != img.ID {
+				ordered = append(ordered, other)
+			}
+		}
+		ordered = append(ordered[:*input.Position], append([]models.ProductImage{img}, ordered[*input.Position:]...)...)
+		if err := renumber(tx, ordered); err != nil {
+			return err
+		}
+		img.Position = *input.Position
+		return syncCover(tx, productID, "")
+	})
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	s.invalidate(productID)
+	return img, nil
+}
+
+// ReorderImages sets the order of the images of a product, imageIDs has to
+// list each of them exactly once.
+func (s *imageService) ReorderImages(ctx context.Context, productID uint, imageIDs []uint) ([]models.ProductImage, error) {
+	var images []models.ProductImage
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := s.images(tx, productID)
+		if err != nil {
+			return err
+		}
+		if len(current) != len(imageIDs) {
+			return fmt.Errorf("%w: image_ids must list every image of the product once", ErrInvalidImage)
+		}
+
+		byID := make(map[uint]models.ProductImage, len(current))
+		for _, img := range current {
+			byID[img.ID] = img
+		}
+		images = make([]models.ProductImage, 0, len(imageIDs))
+		for _, id := range imageIDs {
+			img, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("%w: image_ids must list every image of the product once", ErrInvalidImage)
+			}
+			delete(byID, id)
+			images = append(images, img)
+		}
+
+		if err := renumber(tx, images); err != nil {
+			return err
+		}
+		return syncCover(tx, productID, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(productID)
+	return images, nil
+}
+
+// DeleteImage removes an image and its files, closing the gap it leaves in
+// the order.
+func (s *imageService) DeleteImage(ctx context.Context, productID, imageID uint) error {
+	var img models.ProductImage
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).First(&img, imageID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&img).Error; err != nil {
+			return err
+		}
+
+		images, err := s.images(tx, productID)
+		if err != nil {
+			return err
+		}
+		if err := renumber(tx, images); err != nil {
+			return err
+		}
+		return syncCover(tx, productID, img.URL)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(productID)
+	s.removeFiles(ctx, img.StorageKey, img.ThumbnailKey)
+	return nil
+}
+
+func (s *imageService) images(tx *gorm.DB, productID uint) ([]models.ProductImage, error) {
+	images := []models.ProductImage{}
+	err := tx.Where("product_id = ?", productID).Order("position").Order("id").Find(&images).Error
+	return images, err
+}
+
+func (s *imageService) invalidate(productID uint) {
+	for _, invalidator := range s.Invalidators {
+		invalidator.InvalidateProducts(productID)
+	}
+}
+
+// removeFiles deletes stored files on a best effort basis, a leftover file
+// is only wasted space.
+func (s *imageService) removeFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := s.Storage.Delete(key); err != nil {
+			slog.WarnContext(ctx, "Error deleting stored file", "key", key, "error", err)
+		}
+	}
+}
+
+// renumber saves the slice order of images as their positions.
+func renumber(tx *gorm.DB, images []models.ProductImage) error {
+	for i := range images {
+		if images[i].Position == i {
+			continue
+		}
+		if err := tx.Model(&images[i]).Update("position", i).Error; err != nil {
+			return err
+		}
+		images[i].Position = i
+	}
+	return nil
+}
+
+// syncCover mirrors the first image into Product.Image. When the last image
+// was removed the field is cleared, unless it was set to another URL by hand.
+func syncCover(tx *gorm.DB, productID uint, removedURL string) error {
+	var first models.ProductImage
+	err := tx.Where("product_id = ?", productID).Order("position").Order("id").First(&first).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if removedURL == "" {
+			return nil
+		}
+		return tx.Model(&models.Product{}).Where("id = ? AND image = ?", productID, removedURL).Update("image", "").Error
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("image", first.URL).Error
+}
+
+func randomName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
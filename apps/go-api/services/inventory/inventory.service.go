@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/core/metrics"
+	"go-api/models"
+	notificationService "go-api/services/notification"
+	"log/slog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InventoryService interface {
+	GetLowStock(ctx context.Context) ([]models.Product, error)
+	CheckThresholds(ctx context.Context, productIDs ...uint) error
+	ProductChanged(ctx context.Context, before, after models.Product)
+}
+
+type inventoryService struct {
+	DB        *gorm.DB
+	Publisher notificationService.Publisher
+}
+
+func NewInventoryService(db *gorm.DB, publisher notificationService.Publisher) InventoryService {
+	return &inventoryService{DB: db, Publisher: publisher}
+}
+
+// GetLowStock returns the products at or below their reorder threshold,
+// emptiest first. Out-of-stock products are always included.
+func (s *inventoryService) GetLowStock(ctx context.Context) ([]models.Product, error) {
+	products := []models.Product{}
+	err := s.DB.WithContext(ctx).Where("stock <= reorder_threshold OR stock <= 0").
+		Order("stock ASC").Order("id ASC").
+		Find(&products).Error
+	return products, err
+}
+
+// CheckThresholds publishes an event for every product whose stock crossed
+// its reorder threshold since the last check and records the new state in
+// Product.LowStock. Without ids all products are checked. The flag is flipped
+// under the product row lock before the event is published, so concurrent
+// checks report each crossing once, and flipped back when the publish fails
+// so the next check retries it.
+func (s *inventoryService) CheckThresholds(ctx context.Context, productIDs ...uint) error {
+	scope := func(db *gorm.DB) *gorm.DB {
+		if len(productIDs) > 0 {
+			return db.Where("id IN ?", productIDs)
+		}
+		return db
+	}
+
+	var crossed []models.Product
+	err := s.DB.WithContext(ctx).Scopes(scope).
+		Where("(stock <= reorder_threshold OR stock <= 0) <> low_stock").
+		Find(&crossed).Error
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, product := range crossed {
+		if err := s.report(ctx, product.ID); err != nil {
+			slog.ErrorContext(ctx, "Error publishing stock event", "product_id", product.ID, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// report flips the LowStock flag of a product that still crossed its
+// threshold and publishes its stock event once the flip is committed.
+func (s *inventoryService) report(ctx context.Context, productID uint) error {
+	var product models.Product
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND (stock <= reorder_threshold OR stock <= 0) <> low_stock", productID).
+			Limit(1).Find(&product)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", product.ID).Update("low_stock", !product.LowStock).Error
+	})
+	if err != nil || product.ID == 0 {
+		return err
+	}
+
+	event := models.InventoryEvent{
+		Type:             models.InventoryEventLowStock,
+		ProductID:        product.ID,
+		ProductName:      product.Name,
+		SKU:              product.SKU,
+		Stock:            product.Stock,
+		ReorderThreshold: product.ReorderThreshold,
+	}
+	if product.LowStock {
+		event.Type = models.InventoryEventRestocked
+	}
+	if err := s.Publisher.Publish(ctx, event.Type, event); err != nil {
+		revert := s.DB.WithContext(context.WithoutCancel(ctx)).Model(&models.Product{}).
+			Where("id = ? AND low_stock = ?", product.ID, !product.LowStock).Update("low_stock", product.LowStock).Error
+		return errors.Join(err, revert)
+	}
+	return nil
+}
+
+// ProductChanged checks the threshold right away when the stock or the
+// threshold of a product changes, by an edit, a warehouse or an order.
+func (s *inventoryService) ProductChanged(ctx context.Context, before, after models.Product) {
+	if before.Stock > 0 && after.Stock <= 0 {
+		metrics.StockOuts.Inc()
+	}
+	if before.Stock == after.Stock && before.ReorderThreshold == after.ReorderThreshold {
+		return
+	}
+	if err := s.CheckThresholds(ctx, after.ID); err != nil {
+		slog.ErrorContext(ctx, "Error checking stock threshold", "error", err)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type publishedEvent struct {
+	pattern string
+	event   models.InventoryEvent
+}
+
+// publisherStub records the events, publishing runs before each publish.
+type publisherStub struct {
+	err        error
+	events     []publishedEvent
+	publishing func()
+}
+
+func (p *publisherStub) Publish(ctx context.Context, pattern string, data interface{}) error {
+	if p.publishing != nil {
+		p.publishing()
+	}
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, publishedEvent{pattern: pattern, event: data.(models.InventoryEvent)})
+	return nil
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.Category{}, &models.Product{})
+}
+
+func TestCheckThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		product    models.Product
+		wantEvent  string
+		wantLow    bool
+		publishErr error
+	}{
+		{
+			name:      "drops below threshold",
+			product:   models.Product{Stock: 2, ReorderThreshold: 5},
+			wantEvent: models.InventoryEventLowStock,
+			wantLow:   true,
+		},
+		{
+			name:      "runs out without threshold",
+			product:   models.Product{Stock: 0},
+			wantEvent: models.InventoryEventLowStock,
+			wantLow:   true,
+		},
+		{
+			name:      "restocked",
+			product:   models.Product{Stock: 20, ReorderThreshold: 5, LowStock: true},
+			wantEvent: models.InventoryEventRestocked,
+		},
+		{
+			name:    "still low",
+			product: models.Product{Stock: 1, ReorderThreshold: 5, LowStock: true},
+			wantLow: true,
+		},
+		{
+			name:    "still stocked",
+			product: models.Product{Stock: 20, ReorderThreshold: 5},
+		},
+		{
+			name:       "publish fails",
+			product:    models.Product{Stock: 2, ReorderThreshold: 5},
+			publishErr: errors.New("broker down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			product := tt.product
+			product.Name = "Lamp"
+			product.SKU = "LAMP-1"
+			product = dbtest.Product(t, db, product)
+			// Create skips zero values in favour of the column defaults.
+			if err := db.Model(&product).Update("low_stock", tt.product.LowStock).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			publisher := &publisherStub{err: tt.publishErr}
+			err := NewInventoryService(db, publisher).CheckThresholds(context.Background())
+			if (err != nil) != (tt.publishErr != nil) {
+				t.Fatalf("CheckThresholds(context.Background()) error = %v", err)
+			}
+
+			if tt.wantEvent == "" && len(publisher.events) > 0 {
+				t.Errorf("published %+v, want nothing", publisher.events)
+			}
+			if tt.wantEvent != "" {
+				if len(publisher.events) != 1 {
+					t.Fatalf("published %d events, want 1", len(publisher.events))
+				}
+				got := publisher.events[0]
+				if got.pattern != tt.wantEvent || got.event.ProductID != product.ID || got.event.Stock != product.Stock {
+					t.Errorf("published %+v", got)
+				}
+			}
+
+			var stored models.Product
+			if err := db.First(&stored, product.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.LowStock != tt.wantLow {
+				t.Errorf("low_stock = %v, want %v", stored.LowStock, tt.wantLow)
+			}
+		})
+	}
+}
+
+func TestCheckThresholdsRetriesFailedPublish(t *testing.T) {
+	db := newTestDB(t)
+	product := dbtest.Product(t, db, models.Product{Name: "Lamp", Stock: 1, ReorderThreshold: 5})
+
+	publisher := &publisherStub{err: errors.New("broker down")}
+	service := NewInventoryService(db, publisher)
+	if err := service.CheckThresholds(context.Background(), product.ID); err == nil {
+		t.Fatal("CheckThresholds(context.Background()) succeeded with the broker down")
+	}
+
+	publisher.err = nil
+	for i := 0; i < 2; i++ {
+		if err := service.CheckThresholds(context.Background(), product.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(publisher.events) != 1 || publisher.events[0].pattern != models.InventoryEventLowStock {
+		t.Errorf("published %+v, want one low stock event", publisher.events)
+	}
+}
+
+func TestCheckThresholdsPublishesAfterCommit(t *testing.T) {
+	db := newTestDB(t)
+	product := dbtest.Product(t, db, models.Product{Name: "Lamp", Stock: 1, ReorderThreshold: 5})
+
+	publisher := &publisherStub{}
+	publisher.publishing = func() {
+		// The test database has a single connection, the update times out
+		// while the check still holds it in a transaction.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var lowStock bool
+		if err := db.WithContext(ctx).Model(&models.Product{}).Where("id = ?", product.ID).Pluck("low_stock", &lowStock).Error; err != nil {
+			t.Errorf("event published inside the transaction: %v", err)
+		}
+		if !lowStock {
+			t.Error("event published before low_stock was committed")
+		}
+	}
+	if err := NewInventoryService(db, publisher).CheckThresholds(context.Background(), product.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.events) != 1 {
+		t.Errorf("published %+v, want one event", publisher.events)
+	}
+}
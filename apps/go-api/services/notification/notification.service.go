@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"go-api/core/rabbitmq"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Publisher delivers events to their consumer, pattern names the event.
+type Publisher interface {
+	Publish(ctx context.Context, pattern string, data interface{}) error
+}
+
+type rabbitPublisher struct {
+	Queue string
+}
+
+// NewRabbitPublisher publishes events to a RabbitMQ queue read by the Nest
+// mail module.
+func NewRabbitPublisher(queue string) Publisher {
+	return &rabbitPublisher{Queue: queue}
+}
+
+func (p *rabbitPublisher) Publish(ctx context.Context, pattern string, data interface{}) error {
+	return rabbitmq.PublishContext(ctx, p.Queue, pattern, data)
+}
+
+// NotificationService turns product changes into notification jobs for the
+// users watching the product through a wishlist or a subscription. The
+// changes are queued and notified by a background worker, outside the
+// request that made them.
+type NotificationService struct {
+	DB              *gorm.DB
+	Publisher       Publisher
+	CurrencyService currencyService.CurrencyService
+
+	mu      sync.RWMutex
+	closed  bool
+	changes chan productChange
+	done    chan struct{}
+}
+
+type productChange struct {
+	ctx           context.Context
+	before, after models.Product
+}
+
+// NewNotificationService starts the worker of the service, up to queueSize
+// changes wait for it.
+func NewNotificationService(db *gorm.DB, publisher Publisher, currencies currencyService.CurrencyService, queueSize int) *NotificationService {
+	s := &NotificationService{
+		DB:              db,
+		Publisher:       publisher,
+		CurrencyService: currencies,
+		changes:         make(chan productChange, queueSize),
+		done:            make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *NotificationService) run() {
+	defer close(s.done)
+	for change := range s.changes {
+		s.notify(change.ctx, change.before, change.after)
+	}
+}
+
+// Close stops taking changes and waits until the queued ones are notified.
+func (s *NotificationService) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.changes)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+// ProductChanged queues the change for the worker. Changes made while the
+// queue is full, or once the service is closed, are logged and dropped.
+func (s *NotificationService) ProductChanged(ctx context.Context, before, after models.Product) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		slog.WarnContext(ctx, "Notifications are closed, dropping product change", "product_id", after.ID)
+		return
+	}
+	select {
+	case s.changes <- productChange{ctx: context.WithoutCancel(ctx), before: before, after: after}:
+	default:
+		slog.ErrorContext(ctx, "Notification queue is full, dropping product change", "product_id", after.ID)
+	}
+}
+
+// notify notifies wishlist owners when a product goes on discount or comes
+// back in stock, and subscribers when it comes back in stock or its price
+// drops to their target. Failures are logged.
+func (s *NotificationService) notify(ctx context.Context, before, after models.Product) {
+	if !after.IsActive {
+		return
+	}
+
+	if !onDiscount(before) && onDiscount(after) {
+		s.notifyWishlists(ctx, models.NotificationWishlistDiscount, after)
+	}
+	if before.Stock <= 0 && after.Stock > 0 {
+		s.notifyWishlists(ctx, models.NotificationWishlistBackInStock, after)
+		s.notifySubscribers(ctx, models.NotificationBackInStock, models.SubscriptionBackInStock, after, nil)
+	}
+	if price := effectivePrice(after); price < effectivePrice(before) {
+		s.notifySubscribers(ctx, models.NotificationPriceDrop, models.SubscriptionPriceDrop, after,
+			func(subscription models.ProductSubscription) bool {
+				return s.reachesTarget(ctx, price, after, subscription)
+			})
+	}
+}
+
+func (s *NotificationService) notifyWishlists(ctx context.Context, jobType string, product models.Product) {
+	var userIDs []string
+	err := s.DB.WithContext(ctx).Model(&models.WishlistItem{}).
+		Joins("JOIN wishlists ON wishlists.id = wishlist_items.wishlist_id AND wishlists.deleted_at IS NULL").
+		Where("wishlist_items.product_id = ?", product.ID).
+		Distinct().Pluck("wishlists.user_id", &userIDs).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding wishlists to notify", "error", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		s.publish(ctx, newJob(jobType, userID, product))
+	}
+}
+
+// notifySubscribers publishes a job for every armed subscription of the
+// product of subscriptionType that matches, all when match is nil, and
+// disarms the ones that were published.
+func (s *NotificationService) notifySubscribers(ctx context.Context, jobType, subscriptionType string, product models.Product,
+	match func(models.ProductSubscription) bool) {
+	var subscriptions []models.ProductSubscription
+	err := s.DB.WithContext(ctx).Where("product_id = ? AND type = ? AND notified_at IS NULL", product.ID, subscriptionType).
+		Find(&subscriptions).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding subscriptions to notify", "error", err)
+		return
+	}
+
+	notified := []uint{}
+	for _, subscription := range subscriptions {
+		if match != nil && !match(subscription) {
+			continue
+		}
+		job := newJob(jobType, subscription.UserID, product)
+		job.TargetPrice = subscription.TargetPrice
+		job.TargetCurrency = subscription.TargetCurrency
+		if s.publish(ctx, job) {
+			notified = append(notified, subscription.ID)
+		}
+	}
+	if len(notified) == 0 {
+		return
+	}
+
+	err = s.DB.WithContext(ctx).Model(&models.ProductSubscription{}).Where("id IN ?", notified).Update("notified_at", time.Now()).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Error marking subscriptions notified", "error", err)
+	}
+}
+
+// reachesTarget tells whether price, in the product's currency, is at or
+// below the target of the subscription once converted to its currency.
+func (s *NotificationService) reachesTarget(ctx context.Context, price models.Money, product models.Product, subscription models.ProductSubscription) bool {
+	if subscription.TargetPrice == nil {
+		return false
+	}
+	currency := subscription.TargetCurrency
+	if currency == "" {
+		currency = product.Currency
+	}
+	converted, err := s.CurrencyService.Convert(price, product.Currency, currency)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error converting price to the target currency", "subscription_id", subscription.ID,
+			"currency", currency, "error", err)
+		return false
+	}
+	return converted <= *subscription.TargetPrice
+}
+
+func (s *NotificationService) publish(ctx context.Context, job models.NotificationJob) bool {
+	if err := s.Publisher.Publish(ctx, job.Type, job); err != nil {
+		slog.ErrorContext(ctx, "Error publishing notification", "type", job.Type, "product_id", job.ProductID, "error", err)
+		return false
+	}
+	return true
+}
+
+func newJob(jobType, userID string, product models.Product) models.NotificationJob {
+	return models.NotificationJob{
+		Type:          jobType,
+		UserID:        userID,
+		ProductID:     product.ID,
+		ProductName:   product.Name,
+		Currency:      product.Currency,
+		Price:         product.Price,
+		DiscountPrice: product.DiscountPrice,
+		Stock:         product.Stock,
+	}
+}
+
+func onDiscount(product models.Product) bool {
+	return product.DiscountPrice != nil && *product.DiscountPrice < product.Price
+}
+
+// effectivePrice is the price a customer pays, the discount price when it is
+// lower than the list price.
+func effectivePrice(product models.Product) models.Money {
+	if onDiscount(product) {
+		return *product.DiscountPrice
+	}
+	return product.Price
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// publisherStub records the published jobs, once release is closed when it
+// is set.
+type publisherStub struct {
+	mu      sync.Mutex
+	release chan struct{}
+	jobs    []models.NotificationJob
+}
+
+func (p *publisherStub) Publish(ctx context.Context, pattern string, data interface{}) error {
+	if p.release != nil {
+		<-p.release
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs = append(p.jobs, data.(models.NotificationJob))
+	return nil
+}
+
+func (p *publisherStub) published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var published []string
+	for _, job := range p.jobs {
+		published = append(published, job.Type+" "+job.UserID)
+	}
+	sort.Strings(published)
+	return published
+}
+
+func newCurrencyService(t *testing.T) currencyService.CurrencyService {
+	t.Helper()
+	currencies, err := currencyService.NewCurrencyService("USD", "EUR=0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return currencies
+}
+
+func TestProductChangedInBackground(t *testing.T) {
+	db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Wishlist{}, &models.WishlistItem{}, &models.ProductSubscription{})
+	product := dbtest.Product(t, db, models.Product{Name: "Lamp", Currency: "USD", IsActive: true})
+	dbtest.Create(t, db,
+		&models.Wishlist{UserID: "ann", Items: []models.WishlistItem{{ProductID: product.ID}}},
+		&models.ProductSubscription{UserID: "bob", ProductID: product.ID, Type: models.SubscriptionBackInStock},
+	)
+	publisher := &publisherStub{release: make(chan struct{})}
+	s := NewNotificationService(db, publisher, newCurrencyService(t), 1)
+
+	restocked := product
+	restocked.Stock = 5
+	// The publisher blocks, the change is queued without waiting for it.
+	s.ProductChanged(context.Background(), product, restocked)
+	close(publisher.release)
+	s.Close()
+
+	want := []string{models.NotificationBackInStock + " bob", models.NotificationWishlistBackInStock + " ann"}
+	if got := publisher.published(); !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+	var subscription models.ProductSubscription
+	if err := db.First(&subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	if subscription.NotifiedAt == nil {
+		t.Error("subscription was not marked notified")
+	}
+
+	// Changes after Close are dropped.
+	s.ProductChanged(context.Background(), product, restocked)
+	if got := publisher.published(); len(got) != len(want) {
+		t.Errorf("published %v after Close, want %v", got, want)
+	}
+}
+
+func TestPriceDropTargetCurrency(t *testing.T) {
+	target := func(price models.Money, currency string) models.ProductSubscription {
+		return models.ProductSubscription{Type: models.SubscriptionPriceDrop, TargetPrice: &price, TargetCurrency: currency}
+	}
+	tests := []struct {
+		name         string
+		subscription models.ProductSubscription
+		want         bool
+	}{
+		{name: "reached in the product currency", subscription: target(800, "USD"), want: true},
+		{name: "above the price", subscription: target(700, "USD")},
+		{name: "reached in another currency", subscription: target(720, "EUR"), want: true},
+		{name: "not reached in another currency", subscription: target(719, "EUR")},
+		{name: "without currency", subscription: target(800, ""), want: true},
+		{name: "unsupported currency", subscription: target(100000, "GBP")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Wishlist{}, &models.WishlistItem{}, &models.ProductSubscription{})
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", Price: 1000, Currency: "USD", Stock: 5, IsActive: true})
+			subscription := tt.subscription
+			subscription.UserID = "ann"
+			subscription.ProductID = product.ID
+			dbtest.Create(t, db, &subscription)
+			publisher := &publisherStub{}
+			s := NewNotificationService(db, publisher, newCurrencyService(t), 1)
+
+			cheaper := product
+			cheaper.Price = 800
+			s.ProductChanged(context.Background(), product, cheaper)
+			s.Close()
+
+			if got := len(publisher.published()) == 1; got != tt.want {
+				t.Errorf("notified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/core/metrics"
+	"go-api/models"
+	cartService "go-api/services/cart"
+	promotionService "go-api/services/promotion"
+	shippingService "go-api/services/shipping"
+	taxService "go-api/services/tax"
+	warehouseService "go-api/services/warehouse"
+	webhookService "go-api/services/webhook"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrInvalidShipment   = errors.New("invalid shipment")
+	ErrStatusChanged     = errors.New("order status changed")
+)
+
+// orderTransitions is the order state machine. Cancelling, or refunding an
+// order that has not shipped yet, gives the reserved stock back. Cancelling
+// or refunding any order gives its promotion uses back.
+var orderTransitions = map[string][]string{
+	models.OrderPending:   {models.OrderPaid, models.OrderCancelled},
+	models.OrderPaid:      {models.OrderShipped, models.OrderCancelled, models.OrderRefunded},
+	models.OrderShipped:   {models.OrderDelivered},
+	models.OrderDelivered: {models.OrderRefunded},
+}
+
+var shipmentStatuses = map[string]bool{
+	models.ShipmentLabelCreated:   true,
+	models.ShipmentInTransit:      true,
+	models.ShipmentOutForDelivery: true,
+	models.ShipmentDelivered:      true,
+	models.ShipmentException:      true,
+	models.ShipmentReturned:       true,
+}
+
+// TransitionResult is what TransitionTx changed. Its caller hands it to
+// Committed once the transaction commits, the zero value is no transition.
+type TransitionResult struct {
+	Status string
+	// Restocked holds the products the transition gave stock back to, as
+	// they were before.
+	Restocked []models.Product
+}
+
+func CanTransition(from, to string) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+type OrderService interface {
+	PlaceOrder(ctx context.Context, userID string, input models.OrderInput) (models.Order, error)
+	GetOrders(ctx context.Context, userID string) ([]models.Order, error)
+	GetAllOrders(ctx context.Context, status string) ([]models.Order, error)
+	GetOrder(ctx context.Context, id uint) (models.Order, error)
+	Transition(ctx context.Context, id uint, status string) (models.Order, error)
+	TransitionFrom(ctx context.Context, id uint, from, status string) (models.Order, error)
+	CancelExpired(ctx context.Context, placedBefore time.Time) (int, error)
+	TransitionTx(tx *gorm.DB, order *models.Order, status string) (TransitionResult, error)
+	Committed(ctx context.Context, results ...TransitionResult)
+	CreateShipment(ctx context.Context, orderID uint, input models.ShipmentInput) (models.Shipment, error)
+	AddShipmentEvent(ctx context.Context, shipmentID uint, input models.ShipmentEventInput) (models.Shipment, error)
+	TrackShipment(ctx context.Context, trackingNumber string) (models.Shipment, error)
+}
+
+type orderService struct {
+	DB               *gorm.DB
+	CartService      cartService.CartService
+	PromotionService promotionService.PromotionService
+	ShippingService  shippingService.ShippingService
+	TaxService       taxService.TaxService
+	WarehouseService warehouseService.WarehouseService
+	WebhookService   webhookService.WebhookService
+}
+
+func NewOrderService(db *gorm.DB, cartService cartService.CartService, promotionService promotionService.PromotionService, shippingService shippingService.ShippingService, taxService taxService.TaxService, warehouseService warehouseService.WarehouseService, webhookService webhookService.WebhookService) OrderService {
+	return &orderService{
+		DB:               db,
+		CartService:      cartService,
+		PromotionService: promotionService,
+		ShippingService:  shippingService,
+		TaxService:       taxService,
+		WarehouseService: warehouseService,
+		WebhookService:   webhookService,
+	}
+}
+
+// PlaceOrder prices the cart with promotions, shipping and tax, then reserves
+// the stock, saves the order and redeems the promotions in one transaction.
+func (s *orderService) PlaceOrder(ctx context.Context, userID string, input models.OrderInput) (models.Order, error) {
+	cart, err := s.CartService.Price(ctx, input.CartInput)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if err := s.PromotionService.Evaluate(ctx, &cart, userID, input.Coupons); err != nil {
+		return models.Order{}, err
+	}
+
+	quote, err := s.ShippingService.Rate(ctx, input.ShippingMethodID, cart, input.Address)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	tax, err := s.TaxService.Calculate(ctx, cart, input.Address)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	address := input.Address
+	address.Country = strings.ToUpper(address.Country)
+
+	order := models.Order{
+		UserID:           userID,
+		Status:           models.OrderPending,
+		Currency:         cart.Currency,
+		Subtotal:         cart.Subtotal,
+		Discount:         cart.Discount,
+		ShippingCost:     quote.Rate,
+		Tax:              tax.Tax,
+		Total:            tax.Gross + quote.Rate,
+		ShippingMethodID: quote.MethodID,
+		ShippingAddress:  address,
+	}
+	for i, line := range cart.Lines {
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID: line.ProductID,
+			Name:      line.Name,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Discount:  line.Discount,
+			Tax:       tax.Lines[i].Tax,
+			Total:     tax.Lines[i].Gross,
+		})
+	}
+
+	var allocated []models.Product
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		var err error
+		if allocated, err = s.WarehouseService.AllocateTx(tx, &order); err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+				Update("sold_count", gorm.Expr("sold_count + ?", item.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.PromotionService.RedeemTx(tx, cart, userID, order.ID); err != nil {
+			return err
+		}
+		return s.WebhookService.EnqueueTx(tx, models.WebhookOrderCreated, order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	metrics.OrdersPlaced.WithLabelValues(order.Currency).Inc()
+	s.WarehouseService.StockChanged(ctx, allocated...)
+	return order, nil
+}
+
+func (s *orderService) GetOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	orders := []models.Order{}
+	err := s.DB.WithContext(ctx).Preload("Items").Where("user_id = ?", userID).Order("created_at DESC").Find(&orders).Error
+	return orders, err
+}
+
+func (s *orderService) GetAllOrders(ctx context.Context, status string) ([]models.Order, error) {
+	orders := []models.Order{}
+	query := s.DB.WithContext(ctx).Preload("Items").Order("created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&orders).Error
+	return orders, err
+}
+
+func (s *orderService) GetOrder(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := s.DB.WithContext(ctx).Preload("Items").Preload("Payments").Preload("Allocations").Preload("Shipments.Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at")
+	}).First(&order, id).Error
+	return order, err
+}
+
+func (s *orderService) Transition(ctx context.Context, id uint, status string) (models.Order, error) {
+	return s.TransitionFrom(ctx, id, "", status)
+}
+
+// TransitionFrom moves an order to status if it is still in status from,
+// which is checked under the row lock, and fails with ErrStatusChanged
+// otherwise. An empty from accepts any status.
+func (s *orderService) TransitionFrom(ctx context.Context, id uint, from, status string) (models.Order, error) {
+	var result TransitionResult
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+			return err
+		}
+		if from != "" && order.Status != from {
+			return fmt.Errorf("%w: order is %s, not %s", ErrStatusChanged, order.Status, from)
+		}
+		var err error
+		result, err = s.TransitionTx(tx, &order, status)
+		return err
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	s.Committed(ctx, result)
+	return s.GetOrder(ctx, id)
+}
+
+// CancelExpired cancels the orders still pending that were placed before
+// placedBefore, which gives their reserved stock and promotion uses back.
+// Orders being paid, or authorized and waiting to be captured, are left
+// alone like those paid in the meantime.
+func (s *orderService) CancelExpired(ctx context.Context, placedBefore time.Time) (int, error) {
+	open := s.DB.Model(&models.Payment{}).Select("order_id").
+		Where("status IN ?", []string{models.PaymentPending, models.PaymentAuthorized})
+	var ids []uint
+	err := s.DB.WithContext(ctx).Model(&models.Order{}).
+		Where("status = ? AND created_at < ?", models.OrderPending, placedBefore).
+		Where("id NOT IN (?)", open).
+		Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		_, err := s.TransitionFrom(ctx, id, models.OrderPending, models.OrderCancelled)
+		if errors.Is(err, ErrStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
+
+// TransitionTx moves an order loaded within tx to status, for callers that
+// change the order together with their own records. Callers pass the result
+// to Committed once tx is committed.
+func (s *orderService) TransitionTx(tx *gorm.DB, order *models.Order, status string) (TransitionResult, error) {
+	if !CanTransition(order.Status, status) {
+		return TransitionResult{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, order.Status, status)
+	}
+
+	result := TransitionResult{Status: status}
+	restock := status == models.OrderCancelled ||
+		(status == models.OrderRefunded && order.Status == models.OrderPaid)
+	if restock {
+		var err error
+		if result.Restocked, err = s.WarehouseService.ReleaseTx(tx, order); err != nil {
+			return TransitionResult{}, err
+		}
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return TransitionResult{}, err
+		}
+		for _, item := range items {
+			err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+				Update("sold_count", gorm.Expr("sold_count - ?", item.Quantity)).Error
+			if err != nil {
+				return TransitionResult{}, err
+			}
+		}
+	}
+
+	if status == models.OrderCancelled || status == models.OrderRefunded {
+		if err := s.PromotionService.ReleaseTx(tx, order.ID); err != nil {
+			return TransitionResult{}, err
+		}
+	}
+
+	change := models.OrderStatusChange{OrderID: order.ID, From: order.Status, To: status}
+	order.Status = status
+	if err := tx.Model(order).Update("status", status).Error; err != nil {
+		return TransitionResult{}, err
+	}
+	if err := s.WebhookService.EnqueueTx(tx, models.WebhookOrderStatusChanged, change); err != nil {
+		return TransitionResult{}, err
+	}
+	return result, nil
+}
+
+// Committed records transitions whose transaction committed: it counts them
+// in the order status metric and tells the product listeners about the stock
+// they gave back. TransitionTx cannot do either, its transaction may still
+// roll back.
+func (s *orderService) Committed(ctx context.Context, results ...TransitionResult) {
+	for _, result := range results {
+		if result.Status == "" {
+			continue
+		}
+		metrics.OrderStatusChanges.WithLabelValues(result.Status).Inc()
+		s.WarehouseService.StockChanged(ctx, result.Restocked...)
+	}
+}
+
+func (s *orderService) CreateShipment(ctx context.Context, orderID uint, input models.ShipmentInput) (models.Shipment, error) {
+	input.Carrier = strings.TrimSpace(input.Carrier)
+	input.TrackingNumber = strings.TrimSpace(input.TrackingNumber)
+	if input.Carrier == "" || input.TrackingNumber == "" {
+		return models.Shipment{}, fmt.Errorf("%w: carrier and tracking_number are required", ErrInvalidShipment)
+	}
+
+	var order models.Order
+	if err := s.DB.WithContext(ctx).First(&order, orderID).Error; err != nil {
+		return models.Shipment{}, err
+	}
+	if order.Status != models.OrderPaid && order.Status != models.OrderShipped {
+		return models.Shipment{}, fmt.Errorf("%w: order is %s", ErrInvalidShipment, order.Status)
+	}
+
+	shipment := models.Shipment{
+		OrderID:        orderID,
+		Carrier:        input.Carrier,
+		TrackingNumber: input.TrackingNumber,
+		Status:         models.ShipmentLabelCreated,
+		Events: []models.ShipmentEvent{{
+			Status:     models.ShipmentLabelCreated,
+			OccurredAt: time.Now(),
+		}},
+	}
+	if err := s.DB.WithContext(ctx).Create(&shipment).Error; err != nil {
+		return models.Shipment{}, err
+	}
+	return shipment, nil
+}
+
+// AddShipmentEvent records a tracking event and moves the order along: the
+// first movement of a parcel marks a paid order shipped, and the order is
+// delivered once all its shipments are.
+func (s *orderService) AddShipmentEvent(ctx context.Context, shipmentID uint, input models.ShipmentEventInput) (models.Shipment, error) {
+	if !shipmentStatuses[input.Status] {
+		return models.Shipment{}, fmt.Errorf("%w: unknown status %q", ErrInvalidShipment, input.Status)
+	}
+
+	occurredAt := time.Now()
+	if input.OccurredAt != nil {
+		occurredAt = *input.OccurredAt
+	}
+
+	var moved []TransitionResult
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		moved = nil
+		var shipment models.Shipment
+		if err := tx.First(&shipment, shipmentID).Error; err != nil {
+			return err
+		}
+
+		event := models.ShipmentEvent{
+			ShipmentID:  shipment.ID,
+			Status:      input.Status,
+			Description: input.Description,
+			Location:    input.Location,
+			OccurredAt:  occurredAt,
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&shipment).Update("status", input.Status).Error; err != nil {
+			return err
+		}
+
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, shipment.OrderID).Error; err != nil {
+			return err
+		}
+
+		switch input.Status {
+		case models.ShipmentInTransit, models.ShipmentOutForDelivery, models.ShipmentDelivered:
+			if order.Status == models.OrderPaid {
+				result, err := s.TransitionTx(tx, &order, models.OrderShipped)
+				if err != nil {
+					return err
+				}
+				moved = append(moved, result)
+			}
+		}
+
+		if input.Status == models.ShipmentDelivered && order.Status == models.OrderShipped {
+			var pending int64
+			err := tx.Model(&models.Shipment{}).
+				Where("order_id = ? AND status <> ?", order.ID, models.ShipmentDelivered).
+				Count(&pending).Error
+			if err != nil {
+				return err
+			}
+			if pending == 0 {
+				result, err := s.TransitionTx(tx, &order, models.OrderDelivered)
+				if err != nil {
+					return err
+				}
+				moved = append(moved, result)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Shipment{}, err
+	}
+	s.Committed(ctx, moved...)
+
+	return s.shipment(s.DB.WithContext(ctx).Where("id = ?", shipmentID))
+}
+
+func (s *orderService) TrackShipment(ctx context.Context, trackingNumber string) (models.Shipment, error) {
+	return s.shipment(s.DB.WithContext(ctx).Where("tracking_number = ?", trackingNumber))
+}
+
+func (s *orderService) shipment(query *gorm.DB) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := query.Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at")
+	}).First(&shipment).Error
+	return shipment, err
+}
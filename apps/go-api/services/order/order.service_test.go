@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	promotionService "go-api/services/promotion"
+	warehouseService "go-api/services/warehouse"
+	webhookService "go-api/services/webhook"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) (*gorm.DB, OrderService) {
+	t.Helper()
+	db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Warehouse{}, &models.WarehouseStock{},
+		&models.Order{}, &models.OrderItem{}, &models.OrderAllocation{}, &models.Payment{}, &models.Shipment{},
+		&models.ShipmentEvent{}, &models.Promotion{}, &models.PromotionRedemption{}, &models.WebhookSubscription{})
+	currencies, err := currencyService.NewCurrencyService("USD", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := webhookService.NewWebhookService(db, webhookService.Options{})
+	return db, NewOrderService(db, nil, promotionService.NewPromotionService(db, currencies), nil, nil,
+		warehouseService.NewWarehouseService(db, hooks), hooks)
+}
+
+// pendingOrder places an order for two units of product, taken from its
+// stock, at placedAt.
+func pendingOrder(t *testing.T, db *gorm.DB, product models.Product, placedAt time.Time) models.Order {
+	t.Helper()
+	order := models.Order{
+		UserID:   "user",
+		Status:   models.OrderPending,
+		Currency: "USD",
+		Items:    []models.OrderItem{{ProductID: product.ID, Quantity: 2}},
+	}
+	order.CreatedAt = placedAt
+	dbtest.Create(t, db, &order)
+	if err := db.Model(&product).Update("stock", gorm.Expr("stock - 2")).Error; err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestTransitionFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		from       string
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "expected status", status: models.OrderPending, from: models.OrderPending, wantStatus: models.OrderCancelled},
+		{name: "any status", status: models.OrderPaid, wantStatus: models.OrderCancelled},
+		{name: "paid meanwhile", status: models.OrderPaid, from: models.OrderPending, wantErr: ErrStatusChanged, wantStatus: models.OrderPaid},
+		{name: "not allowed", status: models.OrderShipped, wantErr: ErrInvalidTransition, wantStatus: models.OrderShipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, s := newTestService(t)
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", Stock: 5})
+			order := pendingOrder(t, db, product, time.Now())
+			if err := db.Model(&order).Update("status", tt.status).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := s.TransitionFrom(context.Background(), order.ID, tt.from, models.OrderCancelled)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransitionFrom error = %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.Order
+			if err := db.First(&stored, order.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCancelExpired(t *testing.T) {
+	db, s := newTestService(t)
+	product := dbtest.Product(t, db, models.Product{Name: "Lamp", Stock: 10})
+	orders := map[string]models.Order{
+		"expired": pendingOrder(t, db, product, time.Now().Add(-48*time.Hour)),
+		"recent":  pendingOrder(t, db, product, time.Now()),
+		"paid":    pendingOrder(t, db, product, time.Now().Add(-48*time.Hour)),
+		"paying":  pendingOrder(t, db, product, time.Now().Add(-48*time.Hour)),
+	}
+	dbtest.Create(t, db, &models.Payment{OrderID: orders["paying"].ID, Status: models.PaymentAuthorized})
+	if err := db.Model(&models.Order{}).Where("id = ?", orders["paid"].ID).Update("status", models.OrderPaid).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, err := s.CancelExpired(context.Background(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled != 1 {
+		t.Errorf("cancelled %d orders, want 1", cancelled)
+	}
+
+	want := map[string]string{
+		"expired": models.OrderCancelled,
+		"recent":  models.OrderPending,
+		"paid":    models.OrderPaid,
+		"paying":  models.OrderPending,
+	}
+	for name, order := range orders {
+		var stored models.Order
+		if err := db.First(&stored, order.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != want[name] {
+			t.Errorf("%s order is %s, want %s", name, stored.Status, want[name])
+		}
+	}
+	var stored models.Product
+	if err := db.First(&stored, product.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Stock != 4 {
+		t.Errorf("stock = %d, want the 2 units of the expired order back", stored.Stock)
+	}
+}
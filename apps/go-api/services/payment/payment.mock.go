@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-api/models"
+	"sync"
+)
+
+// MockDeclineMethod is the payment method the mock provider always declines.
+const MockDeclineMethod = "mock_decline"
+
+var errMockIntent = errors.New("mock payment intent")
+
+// MockProvider is an in-process payment provider for development and tests.
+// It keeps intents in memory, accepts every payment method except
+// MockDeclineMethod and signs webhooks with the configured secret.
+type MockProvider struct {
+	Secret string
+
+	mu      sync.Mutex
+	seq     int
+	intents map[string]*mockIntent
+}
+
+type mockIntent struct {
+	status   string
+	amount   models.Money
+	captured models.Money
+	refunded models.Money
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{Secret: secret, intents: map[string]*mockIntent{}}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) Authorize(amount models.Money, currency, paymentMethod, reference string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+	ref := fmt.Sprintf("mock_pi_%d", p.seq)
+	if paymentMethod == MockDeclineMethod {
+		p.intents[ref] = &mockIntent{status: models.PaymentFailed, amount: amount}
+		return ref, fmt.Errorf("%w: card declined", ErrPaymentDeclined)
+	}
+	p.intents[ref] = &mockIntent{status: models.PaymentAuthorized, amount: amount}
+	return ref, nil
+}
+
+func (p *MockProvider) Capture(ref string, amount models.Money) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, err := p.intent(ref, models.PaymentAuthorized)
+	if err != nil {
+		return err
+	}
+	if amount > intent.amount {
+		return fmt.Errorf("%w: capture exceeds authorized amount", errMockIntent)
+	}
+	intent.status = models.PaymentCaptured
+	intent.captured = amount
+	return nil
+}
+
+func (p *MockProvider) Refund(ref string, amount models.Money) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, err := p.intent(ref, models.PaymentCaptured)
+	if err != nil {
+		return err
+	}
+	if intent.refunded+amount > intent.captured {
+		return fmt.Errorf("%w: refund exceeds captured amount", errMockIntent)
+	}
+	intent.refunded += amount
+	return nil
+}
+
+func (p *MockProvider) Void(ref string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, err := p.intent(ref, models.PaymentAuthorized)
+	if err != nil {
+		return err
+	}
+	intent.status = models.PaymentVoided
+	return nil
+}
+
+func (p *MockProvider) ParseWebhook(payload []byte, signature string) (models.PaymentEvent, error) {
+	if err := verifySignature(p.Secret, payload, signature); err != nil {
+		return models.PaymentEvent{}, err
+	}
+	return decodeEvent(payload)
+}
+
+func (p *MockProvider) intent(ref, status string) (*mockIntent, error) {
+	intent, ok := p.intents[ref]
+	if !ok {
+		return nil, fmt.Errorf("%w %s not found", errMockIntent, ref)
+	}
+	if intent.status != status {
+		return nil, fmt.Errorf("%w %s is %s", errMockIntent, ref, intent.status)
+	}
+	return intent, nil
+}
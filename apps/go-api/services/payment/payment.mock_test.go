@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"go-api/models"
+	"testing"
+)
+
+func TestNewPaymentProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "mock", want: "mock"},
+		{name: "", wantErr: true},
+		{name: "stripe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewPaymentProvider(tt.name, "secret")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPaymentProvider(%q) error = %v", tt.name, err)
+			}
+			if err == nil && provider.Name() != tt.want {
+				t.Errorf("NewPaymentProvider(%q).Name() = %q, want %q", tt.name, provider.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMockProviderAuthorize(t *testing.T) {
+	tests := []struct {
+		method   string
+		declined bool
+	}{
+		{method: "card"},
+		{method: ""},
+		{method: MockDeclineMethod, declined: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			p := NewMockProvider("secret")
+			ref, err := p.Authorize(1000, "USD", tt.method, "1")
+			if ref == "" {
+				t.Fatal("Authorize returned no reference")
+			}
+			if errors.Is(err, ErrPaymentDeclined) != tt.declined {
+				t.Fatalf("Authorize(%q) error = %v, declined %v", tt.method, err, tt.declined)
+			}
+			if !tt.declined && err != nil {
+				t.Fatalf("Authorize(%q) error = %v", tt.method, err)
+			}
+			// A declined intent can never be captured.
+			if err := p.Capture(ref, 1000); (err != nil) != tt.declined {
+				t.Errorf("Capture after Authorize(%q) error = %v", tt.method, err)
+			}
+		})
+	}
+}
+
+func TestMockProviderReferencesAreUnique(t *testing.T) {
+	p := NewMockProvider("secret")
+	seen := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		ref, err := p.Authorize(100, "USD", "card", "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[ref] {
+			t.Fatalf("Authorize reused reference %s", ref)
+		}
+		seen[ref] = true
+	}
+}
+
+func TestMockProviderLifecycle(t *testing.T) {
+	type step struct {
+		op      string
+		amount  models.Money
+		wantErr bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "capture full amount",
+			steps: []step{{op: "capture", amount: 1000}},
+		},
+		{
+			name:  "capture less than authorized",
+			steps: []step{{op: "capture", amount: 400}},
+		},
+		{
+			name:  "capture more than authorized",
+			steps: []step{{op: "capture", amount: 1001, wantErr: true}},
+		},
+		{
+			name:  "capture twice",
+			steps: []step{{op: "capture", amount: 1000}, {op: "capture", amount: 1000, wantErr: true}},
+		},
+		{
+			name: "partial refunds up to the captured amount",
+			steps: []step{
+				{op: "capture", amount: 800},
+				{op: "refund", amount: 300},
+				{op: "refund", amount: 500},
+				{op: "refund", amount: 1, wantErr: true},
+			},
+		},
+		{
+			name:  "refund before capture",
+			steps: []step{{op: "refund", amount: 100, wantErr: true}},
+		},
+		{
+			name:  "void an authorization",
+			steps: []step{{op: "void"}, {op: "capture", amount: 1000, wantErr: true}},
+		},
+		{
+			name:  "void after capture",
+			steps: []step{{op: "capture", amount: 1000}, {op: "void", wantErr: true}},
+		},
+		{
+			name:  "unknown reference",
+			steps: []step{{op: "capture-unknown", amount: 1000, wantErr: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewMockProvider("secret")
+			ref, err := p.Authorize(1000, "USD", "card", "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, s := range tt.steps {
+				switch s.op {
+				case "capture":
+					err = p.Capture(ref, s.amount)
+				case "capture-unknown":
+					err = p.Capture("mock_pi_unknown", s.amount)
+				case "refund":
+					err = p.Refund(ref, s.amount)
+				case "void":
+					err = p.Void(ref)
+				}
+				if (err != nil) != s.wantErr {
+					t.Fatalf("step %d %s(%d) error = %v, want error %v", i, s.op, s.amount, err, s.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestMockProviderParseWebhook(t *testing.T) {
+	valid := []byte(`{"id":"evt_1","type":"payment.captured","provider_ref":"mock_pi_1","amount":10}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		payload   []byte
+		signature string
+		wantErr   error
+		wantAny   bool
+	}{
+		{name: "valid", secret: "secret", payload: valid, signature: SignPayload("secret", valid)},
+		{name: "wrong secret", secret: "secret", payload: valid, signature: SignPayload("other", valid), wantErr: ErrInvalidSignature},
+		{name: "missing signature", secret: "secret", payload: valid, wantErr: ErrInvalidSignature},
+		{name: "no secret configured", payload: valid, signature: SignPayload("", valid), wantErr: ErrInvalidSignature},
+		{
+			name:      "tampered body",
+			secret:    "secret",
+			payload:   []byte(`{"id":"evt_1","type":"payment.captured","provider_ref":"mock_pi_1","amount":1000}`),
+			signature: SignPayload("secret", valid),
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "incomplete event",
+			secret:    "secret",
+			payload:   []byte(`{"id":"evt_1"}`),
+			signature: SignPayload("secret", []byte(`{"id":"evt_1"}`)),
+			wantAny:   true,
+		},
+		{
+			name:      "invalid json",
+			secret:    "secret",
+			payload:   []byte(`{`),
+			signature: SignPayload("secret", []byte(`{`)),
+			wantAny:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewMockProvider(tt.secret)
+			event, err := p.ParseWebhook(tt.payload, tt.signature)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseWebhook error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAny:
+				if err == nil {
+					t.Fatal("ParseWebhook accepted an invalid event")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("ParseWebhook error = %v", err)
+				}
+				if event.ID != "evt_1" || event.Type != models.PaymentEventCaptured || event.ProviderRef != "mock_pi_1" || event.Amount != 1000 {
+					t.Errorf("ParseWebhook = %+v", event)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	orderService "go-api/services/order"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidPaymentState = errors.New("payment is not in a state that allows this operation")
+	ErrInvalidRefund       = errors.New("invalid refund amount")
+)
+
+type PaymentService interface {
+	Pay(ctx context.Context, orderID uint, userID string, input models.PaymentInput) (models.Payment, error)
+	GetPayments(ctx context.Context, orderID uint) ([]models.Payment, error)
+	Capture(ctx context.Context, id uint) (models.Payment, error)
+	Refund(ctx context.Context, id uint, input models.RefundInput) (models.Payment, error)
+	Void(ctx context.Context, id uint) (models.Payment, error)
+	// HandleWebhook applies a signed provider event. It reports false when
+	// the event was already processed.
+	HandleWebhook(ctx context.Context, payload []byte, signature string) (bool, error)
+}
+
+type paymentService struct {
+	DB           *gorm.DB
+	Provider     PaymentProvider
+	OrderService orderService.OrderService
+	AutoCapture  bool
+}
+
+// NewPaymentService creates the payment service. With autoCapture payments
+// are captured right after authorization, otherwise an admin captures them,
+// usually when the order ships.
+func NewPaymentService(db *gorm.DB, provider PaymentProvider, orderService orderService.OrderService, autoCapture bool) PaymentService {
+	return &paymentService{DB: db, Provider: provider, OrderService: orderService, AutoCapture: autoCapture}
+}
+
+// Pay authorizes the total of a pending order. The payment is saved as
+// pending under the order lock, so concurrent requests cannot both authorize
+// the order, and the provider is called once that lock is released. A
+// declined payment cancels the order and releases its stock. An order that
+// was cancelled while the provider authorized it gets the authorization
+// voided.
+func (s *paymentService) Pay(ctx context.Context, orderID uint, userID string, input models.PaymentInput) (models.Payment, error) {
+	var payment models.Payment
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).
+			First(&order, orderID).Error
+		if err != nil {
+			return err
+		}
+		if order.Status != models.OrderPending {
+			return fmt.Errorf("%w: order is %s", ErrInvalidPaymentState, order.Status)
+		}
+
+		var open int64
+		err = tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status IN ?", order.ID, []string{models.PaymentPending, models.PaymentAuthorized}).
+			Count(&open).Error
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			return fmt.Errorf("%w: order already has an open payment", ErrInvalidPaymentState)
+		}
+
+		payment = models.Payment{
+			OrderID:  order.ID,
+			Provider: s.Provider.Name(),
+			Status:   models.PaymentPending,
+			Currency: order.Currency,
+			Amount:   order.Total,
+		}
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	ref, authErr := s.Provider.Authorize(payment.Amount, payment.Currency, input.PaymentMethod, strconv.FormatUint(uint64(payment.OrderID), 10))
+
+	var cancelled orderService.TransitionResult
+	orderGone := false
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+
+		payment.ProviderRef = ref
+		switch {
+		case authErr != nil:
+			payment.Status = models.PaymentFailed
+			payment.FailureReason = authErr.Error()
+		case order.Status != models.OrderPending:
+			payment.Status = models.PaymentVoided
+			orderGone = true
+		default:
+			payment.Status = models.PaymentAuthorized
+		}
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
+
+		// Errors other than declines leave the order to be paid again.
+		if errors.Is(authErr, ErrPaymentDeclined) && order.Status == models.OrderPending {
+			var err error
+			cancelled, err = s.OrderService.TransitionTx(tx, &order, models.OrderCancelled)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if authErr != nil {
+		s.OrderService.Committed(ctx, cancelled)
+		return payment, authErr
+	}
+	if orderGone {
+		if err := s.Provider.Void(ref); err != nil {
+			return models.Payment{}, err
+		}
+		return models.Payment{}, fmt.Errorf("%w: order was cancelled during the payment", ErrInvalidPaymentState)
+	}
+
+	if s.AutoCapture {
+		return s.Capture(ctx, payment.ID)
+	}
+	return payment, nil
+}
+
+func (s *paymentService) GetPayments(ctx context.Context, orderID uint) ([]models.Payment, error) {
+	payments := []models.Payment{}
+	err := s.DB.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&payments).Error
+	return payments, err
+}
+
+// Capture collects an authorized payment and marks the order paid.
+func (s *paymentService) Capture(ctx context.Context, id uint) (models.Payment, error) {
+	payment, err := s.payment(s.DB.WithContext(ctx), id, models.PaymentAuthorized)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if err := s.Provider.Capture(payment.ProviderRef, payment.Amount); err != nil {
+		return models.Payment{}, err
+	}
+	return s.apply(ctx, payment.ID, models.PaymentEvent{Type: models.PaymentEventCaptured, Amount: payment.Amount})
+}
+
+// Refund returns part or, when no amount is given, the rest of a captured
+// payment. A full refund moves the order to refunded. The amount is reserved
+// on the payment under its row lock before the provider is called, one
+// refund at a time, so concurrent refunds cannot return more than was
+// captured.
+func (s *paymentService) Refund(ctx context.Context, id uint, input models.RefundInput) (models.Payment, error) {
+	var payment models.Payment
+	var amount models.Money
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		payment, err = s.payment(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id, models.PaymentCaptured, models.PaymentPartiallyRefunded)
+		if err != nil {
+			return err
+		}
+		if payment.PendingRefund > 0 {
+			return fmt.Errorf("%w: a refund of %s is in progress", ErrInvalidPaymentState, payment.PendingRefund)
+		}
+
+		remaining := payment.CapturedAmount - payment.RefundedAmount
+		amount = remaining
+		if input.Amount != nil {
+			amount = *input.Amount
+		}
+		if amount <= 0 || amount > remaining {
+			return fmt.Errorf("%w: must be between 0.01 and %s", ErrInvalidRefund, remaining)
+		}
+		return tx.Model(&payment).Update("pending_refund", amount).Error
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	refundErr := s.Provider.Refund(payment.ProviderRef, amount)
+
+	var moved orderService.TransitionResult
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Payment{}).Where("id = ?", id).Update("pending_refund", 0).Error; err != nil {
+			return err
+		}
+		if refundErr != nil {
+			return nil
+		}
+		var err error
+		moved, err = s.applyTx(tx, id, models.PaymentEvent{Type: models.PaymentEventRefunded, Amount: payment.RefundedAmount + amount})
+		return err
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if refundErr != nil {
+		return models.Payment{}, refundErr
+	}
+	s.OrderService.Committed(ctx, moved)
+
+	err = s.DB.WithContext(ctx).First(&payment, id).Error
+	return payment, err
+}
+
+// Void releases an authorization that was never captured and cancels the
+// order.
+func (s *paymentService) Void(ctx context.Context, id uint) (models.Payment, error) {
+	payment, err := s.payment(s.DB.WithContext(ctx), id, models.PaymentAuthorized)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if err := s.Provider.Void(payment.ProviderRef); err != nil {
+		return models.Payment{}, err
+	}
+	return s.apply(ctx, payment.ID, models.PaymentEvent{Type: models.PaymentEventVoided})
+}
+
+func (s *paymentService) HandleWebhook(ctx context.Context, payload []byte, signature string) (bool, error) {
+	event, err := s.Provider.ParseWebhook(payload, signature)
+	if err != nil {
+		return false, err
+	}
+
+	processed := false
+	var moved orderService.TransitionResult
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record := models.PaymentWebhookEvent{Provider: s.Provider.Name(), EventID: event.ID, Type: event.Type}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		processed = true
+
+		var payment models.Payment
+		err := tx.Where("provider = ? AND provider_ref = ?", s.Provider.Name(), event.ProviderRef).First(&payment).Error
+		if err != nil {
+			return err
+		}
+		moved, err = s.applyTx(tx, payment.ID, event)
+		return err
+	})
+	if err != nil {
+		return processed, err
+	}
+	s.OrderService.Committed(ctx, moved)
+	return processed, nil
+}
+
+// payment loads a payment through db and checks that it is in one of the
+// statuses.
+func (s *paymentService) payment(db *gorm.DB, id uint, statuses ...string) (models.Payment, error) {
+	var payment models.Payment
+	if err := db.First(&payment, id).Error; err != nil {
+		return models.Payment{}, err
+	}
+	for _, status := range statuses {
+		if payment.Status == status {
+			return payment, nil
+		}
+	}
+	return models.Payment{}, fmt.Errorf("%w: payment is %s", ErrInvalidPaymentState, payment.Status)
+}
+
+func (s *paymentService) apply(ctx context.Context, id uint, event models.PaymentEvent) (models.Payment, error) {
+	var moved orderService.TransitionResult
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		moved, err = s.applyTx(tx, id, event)
+		return err
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+	s.OrderService.Committed(ctx, moved)
+
+	var payment models.Payment
+	err = s.DB.WithContext(ctx).First(&payment, id).Error
+	return payment, err
+}
+
+// applyTx moves a payment and its order to the state described by event.
+// Events that no longer apply to the payment, such as a capture arriving
+// after a refund, are ignored so the synchronous API calls and the provider
+// webhooks for the same operation can arrive in any order. It returns the
+// transition of the order, if any.
+func (s *paymentService) applyTx(tx *gorm.DB, id uint, event models.PaymentEvent) (orderService.TransitionResult, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error; err != nil {
+		return orderService.TransitionResult{}, err
+	}
+
+	orderStatus := ""
+	switch event.Type {
+	case models.PaymentEventCaptured:
+		if payment.Status != models.PaymentAuthorized {
+			return orderService.TransitionResult{}, nil
+		}
+		payment.Status = models.PaymentCaptured
+		payment.CapturedAmount = event.Amount
+		orderStatus = models.OrderPaid
+	case models.PaymentEventFailed:
+		if payment.Status != models.PaymentAuthorized {
+			return orderService.TransitionResult{}, nil
+		}
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = event.Reason
+		orderStatus = models.OrderCancelled
+	case models.PaymentEventVoided:
+		if payment.Status != models.PaymentAuthorized {
+			return orderService.TransitionResult{}, nil
+		}
+		payment.Status = models.PaymentVoided
+		orderStatus = models.OrderCancelled
+	case models.PaymentEventRefunded:
+		if payment.Status != models.PaymentCaptured && payment.Status != models.PaymentPartiallyRefunded {
+			return orderService.TransitionResult{}, nil
+		}
+		if event.Amount <= payment.RefundedAmount {
+			return orderService.TransitionResult{}, nil
+		}
+		payment.RefundedAmount = min(event.Amount, payment.CapturedAmount)
+		// Only one refund is processed at a time, this event completes it.
+		payment.PendingRefund = 0
+		payment.Status = models.PaymentPartiallyRefunded
+		if payment.RefundedAmount == payment.CapturedAmount {
+			payment.Status = models.PaymentRefunded
+			orderStatus = models.OrderRefunded
+		}
+	default:
+		return orderService.TransitionResult{}, nil
+	}
+
+	if err := tx.Save(&payment).Error; err != nil {
+		return orderService.TransitionResult{}, err
+	}
+	if orderStatus == "" {
+		return orderService.TransitionResult{}, nil
+	}
+	return s.transitionOrder(tx, payment.OrderID, orderStatus)
+}
+
+// transitionOrder moves the order when its state machine allows it and
+// returns the transition. A payment event never fails because the order was
+// already moved by an admin.
+func (s *paymentService) transitionOrder(tx *gorm.DB, orderID uint, status string) (orderService.TransitionResult, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return orderService.TransitionResult{}, err
+	}
+	if !orderService.CanTransition(order.Status, status) {
+		return orderService.TransitionResult{}, nil
+	}
+	return s.OrderService.TransitionTx(tx, &order, status)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	orderService "go-api/services/order"
+	promotionService "go-api/services/promotion"
+	warehouseService "go-api/services/warehouse"
+	webhookService "go-api/services/webhook"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// providerStub is the mock provider with hooks run while the provider
+// processes a request, when the service must not hold any lock.
+type providerStub struct {
+	*MockProvider
+	authorizing func()
+	refunding   func()
+	refundErr   error
+}
+
+func (p *providerStub) Authorize(amount models.Money, currency, paymentMethod, reference string) (string, error) {
+	if p.authorizing != nil {
+		p.authorizing()
+	}
+	return p.MockProvider.Authorize(amount, currency, paymentMethod, reference)
+}
+
+func (p *providerStub) Refund(ref string, amount models.Money) error {
+	if p.refunding != nil {
+		p.refunding()
+	}
+	if p.refundErr != nil {
+		return p.refundErr
+	}
+	return p.MockProvider.Refund(ref, amount)
+}
+
+func newTestService(t *testing.T) (*gorm.DB, *providerStub, PaymentService) {
+	t.Helper()
+	db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Warehouse{}, &models.WarehouseStock{},
+		&models.Order{}, &models.OrderItem{}, &models.OrderAllocation{}, &models.Payment{}, &models.Shipment{},
+		&models.ShipmentEvent{}, &models.Promotion{}, &models.PromotionRedemption{}, &models.WebhookSubscription{})
+	currencies, err := currencyService.NewCurrencyService("USD", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := webhookService.NewWebhookService(db, webhookService.Options{})
+	orders := orderService.NewOrderService(db, nil, promotionService.NewPromotionService(db, currencies), nil, nil,
+		warehouseService.NewWarehouseService(db, hooks), hooks)
+	provider := &providerStub{MockProvider: NewMockProvider("secret")}
+	return db, provider, NewPaymentService(db, provider, orders, false)
+}
+
+func pendingOrder(t *testing.T, db *gorm.DB) models.Order {
+	t.Helper()
+	order := models.Order{UserID: "user", Status: models.OrderPending, Currency: "USD", Total: 5000}
+	dbtest.Create(t, db, &order)
+	return order
+}
+
+// unlocked runs query on its own connection, the test database has a single
+// one, so it fails when the service still holds it in a transaction.
+func unlocked(t *testing.T, db *gorm.DB, query func(db *gorm.DB) error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := query(db.WithContext(ctx)); err != nil {
+		t.Errorf("provider called inside a transaction: %v", err)
+	}
+}
+
+func TestPay(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		cancel      bool
+		wantErr     error
+		wantPayment string
+		wantOrder   string
+	}{
+		{name: "authorized", wantPayment: models.PaymentAuthorized, wantOrder: models.OrderPending},
+		{name: "declined", method: MockDeclineMethod, wantErr: ErrPaymentDeclined, wantPayment: models.PaymentFailed, wantOrder: models.OrderCancelled},
+		{name: "order cancelled meanwhile", cancel: true, wantErr: ErrInvalidPaymentState, wantPayment: models.PaymentVoided, wantOrder: models.OrderCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, provider, s := newTestService(t)
+			order := pendingOrder(t, db)
+			provider.authorizing = func() {
+				unlocked(t, db, func(db *gorm.DB) error {
+					if !tt.cancel {
+						return db.Exec("SELECT 1").Error
+					}
+					return db.Model(&order).Update("status", models.OrderCancelled).Error
+				})
+			}
+
+			_, err := s.Pay(context.Background(), order.ID, "user", models.PaymentInput{PaymentMethod: tt.method})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Pay error = %v, want %v", err, tt.wantErr)
+			}
+
+			var payment models.Payment
+			if err := db.Where("order_id = ?", order.ID).First(&payment).Error; err != nil {
+				t.Fatal(err)
+			}
+			if payment.Status != tt.wantPayment || payment.ProviderRef == "" {
+				t.Errorf("payment is %s with ref %q, want %s", payment.Status, payment.ProviderRef, tt.wantPayment)
+			}
+			if tt.wantPayment == models.PaymentVoided {
+				if err := provider.Void(payment.ProviderRef); err == nil {
+					t.Error("authorization was not voided at the provider")
+				}
+			}
+			var stored models.Order
+			if err := db.First(&stored, order.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantOrder {
+				t.Errorf("order is %s, want %s", stored.Status, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestPayWithOpenPayment(t *testing.T) {
+	db, provider, s := newTestService(t)
+	order := pendingOrder(t, db)
+
+	var err error
+	provider.authorizing = func() {
+		provider.authorizing = nil
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = s.Pay(ctx, order.ID, "user", models.PaymentInput{})
+	}
+	if _, err := s.Pay(context.Background(), order.ID, "user", models.PaymentInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(err, ErrInvalidPaymentState) {
+		t.Errorf("Pay during an authorization error = %v, want %v", err, ErrInvalidPaymentState)
+	}
+
+	var count int64
+	if err := db.Model(&models.Payment{}).Where("order_id = ?", order.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d payments, want 1", count)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	refund := func(amount models.Money) models.RefundInput { return models.RefundInput{Amount: &amount} }
+	tests := []struct {
+		name         string
+		input        models.RefundInput
+		concurrent   *models.RefundInput
+		providerErr  error
+		wantErr      error
+		wantInner    error
+		wantRefunded models.Money
+		wantStatus   string
+	}{
+		{name: "partial", input: refund(2000), wantRefunded: 2000, wantStatus: models.PaymentPartiallyRefunded},
+		{name: "rest", input: models.RefundInput{}, wantRefunded: 5000, wantStatus: models.PaymentRefunded},
+		{name: "above the captured amount", input: refund(6000), wantErr: ErrInvalidRefund, wantStatus: models.PaymentCaptured},
+		{
+			name:         "concurrent refund",
+			input:        refund(3000),
+			concurrent:   &models.RefundInput{},
+			wantInner:    ErrInvalidPaymentState,
+			wantRefunded: 3000,
+			wantStatus:   models.PaymentPartiallyRefunded,
+		},
+		{name: "provider failure", input: refund(2000), providerErr: errMockIntent, wantErr: errMockIntent, wantStatus: models.PaymentCaptured},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, provider, s := newTestService(t)
+			ctx := context.Background()
+			order := pendingOrder(t, db)
+			payment, err := s.Pay(ctx, order.ID, "user", models.PaymentInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payment, err = s.Capture(ctx, payment.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			var innerErr error
+			provider.refundErr = tt.providerErr
+			provider.refunding = func() {
+				provider.refunding = nil
+				unlocked(t, db, func(db *gorm.DB) error { return db.Exec("SELECT 1").Error })
+				if tt.concurrent != nil {
+					ctx, cancel := context.WithTimeout(ctx, time.Second)
+					defer cancel()
+					_, innerErr = s.Refund(ctx, payment.ID, *tt.concurrent)
+				}
+			}
+
+			_, err = s.Refund(ctx, payment.ID, tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Refund error = %v, want %v", err, tt.wantErr)
+			}
+			if !errors.Is(innerErr, tt.wantInner) {
+				t.Errorf("concurrent Refund error = %v, want %v", innerErr, tt.wantInner)
+			}
+
+			var stored models.Payment
+			if err := db.First(&stored, payment.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.RefundedAmount != tt.wantRefunded || stored.Status != tt.wantStatus || stored.PendingRefund != 0 {
+				t.Errorf("payment is %s with %s refunded and %s pending, want %s with %s refunded",
+					stored.Status, stored.RefundedAmount, stored.PendingRefund, tt.wantStatus, tt.wantRefunded)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	productService "go-api/services/product"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PricingService interface {
+	GetPrices(ctx context.Context, productID uint) ([]models.ProductPrice, error)
+	SetPrice(ctx context.Context, productID uint, currency string, input models.ProductPriceInput) (models.ProductPrice, error)
+	DeletePrice(ctx context.Context, productID uint, currency string) error
+	Localize(ctx context.Context, products []models.Product, currency string) error
+}
+
+type pricingService struct {
+	DB              *gorm.DB
+	CurrencyService currencyService.CurrencyService
+	Invalidators    []productService.ProductInvalidator
+}
+
+// NewPricingService creates the pricing service. The invalidators are told
+// about the products whose price list changed.
+func NewPricingService(db *gorm.DB, currencyService currencyService.CurrencyService, invalidators ...productService.ProductInvalidator) PricingService {
+	return &pricingService{DB: db, CurrencyService: currencyService, Invalidators: invalidators}
+}
+
+func (s *pricingService) GetPrices(ctx context.Context, productID uint) ([]models.ProductPrice, error) {
+	if err := s.DB.WithContext(ctx).First(&models.Product{}, productID).Error; err != nil {
+		return nil, err
+	}
+
+	prices := []models.ProductPrice{}
+	if err := s.DB.WithContext(ctx).Where("product_id = ?", productID).Order("currency").Find(&prices).Error; err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (s *pricingService) SetPrice(ctx context.Context, productID uint, currency string, input models.ProductPriceInput) (models.ProductPrice, error) {
+	currency = strings.ToUpper(currency)
+	if !s.CurrencyService.Supports(currency) {
+		return models.ProductPrice{}, currencyService.ErrUnsupportedCurrency
+	}
+
+	if err := s.DB.WithContext(ctx).First(&models.Product{}, productID).Error; err != nil {
+		return models.ProductPrice{}, err
+	}
+
+	price := models.ProductPrice{
+		ProductID:     productID,
+		Currency:      currency,
+		Price:         input.Price,
+		DiscountPrice: input.DiscountPrice,
+	}
+
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "discount_price", "updated_at"}),
+	}).Create(&price).Error
+	if err != nil {
+		return models.ProductPrice{}, err
+	}
+	s.invalidate(productID)
+
+	if err := s.DB.WithContext(ctx).Where("product_id = ? AND currency = ?", productID, currency).First(&price).Error; err != nil {
+		return models.ProductPrice{}, err
+	}
+	return price, nil
+}
+
+func (s *pricingService) DeletePrice(ctx context.Context, productID uint, currency string) error {
+	result := s.DB.WithContext(ctx).Unscoped().
+		Where("product_id = ? AND currency = ?", productID, strings.ToUpper(currency)).
+		Delete(&models.ProductPrice{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	s.invalidate(productID)
+	return nil
+}
+
+func (s *pricingService) invalidate(productID uint) {
+	for _, invalidator := range s.Invalidators {
+		invalidator.InvalidateProducts(productID)
+	}
+}
+
+// Localize rewrites the prices of products in place into currency, using the
+// product's price list entry when there is one and the rate table otherwise.
+func (s *pricingService) Localize(ctx context.Context, products []models.Product, currency string) error {
+	if currency == "" {
+		return nil
+	}
+
+	currency = strings.ToUpper(currency)
+	if !s.CurrencyService.Supports(currency) {
+		return currencyService.ErrUnsupportedCurrency
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	var prices []models.ProductPrice
+	if err := s.DB.WithContext(ctx).Where("product_id IN ? AND currency = ?", ids, currency).Find(&prices).Error; err != nil {
+		return err
+	}
+
+	overrides := make(map[uint]models.ProductPrice, len(prices))
+	for _, price := range prices {
+		overrides[price.ProductID] = price
+	}
+
+	for i := range products {
+		product := &products[i]
+
+		if override, ok := overrides[product.ID]; ok {
+			product.Price = override.Price
+			product.DiscountPrice = override.DiscountPrice
+			product.Currency = currency
+			continue
+		}
+
+		price, err := s.CurrencyService.Convert(product.Price, product.Currency, currency)
+		if err != nil {
+			return err
+		}
+		product.Price = price
+
+		if product.DiscountPrice != nil {
+			discount, err := s.CurrencyService.Convert(*product.DiscountPrice, product.Currency, currency)
+			if err != nil {
+				return err
+			}
+			product.DiscountPrice = &discount
+		}
+
+		product.Currency = currency
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"go-api/core/cache"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	productService "go-api/services/product"
+	"strconv"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.Category{}, &models.Product{}, &models.ProductPrice{})
+}
+
+func TestPriceListWritesInvalidateCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		write       func(ctx context.Context, s PricingService, productID uint) error
+		wantErr     bool
+		wantDropped bool
+	}{
+		{
+			name: "set price",
+			write: func(ctx context.Context, s PricingService, productID uint) error {
+				_, err := s.SetPrice(ctx, productID, "eur", models.ProductPriceInput{Price: 900})
+				return err
+			},
+			wantDropped: true,
+		},
+		{
+			name: "delete price",
+			write: func(ctx context.Context, s PricingService, productID uint) error {
+				return s.DeletePrice(ctx, productID, "usd")
+			},
+			wantDropped: true,
+		},
+		{
+			name: "delete missing price",
+			write: func(ctx context.Context, s PricingService, productID uint) error {
+				return s.DeletePrice(ctx, productID, "eur")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported currency",
+			write: func(ctx context.Context, s PricingService, productID uint) error {
+				_, err := s.SetPrice(ctx, productID, "xyz", models.ProductPriceInput{Price: 900})
+				return err
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", Price: 1000, Currency: "USD"})
+			dbtest.Create(t, db, &models.ProductPrice{ProductID: product.ID, Currency: "USD", Price: 1000})
+
+			currencies, err := currencyService.NewCurrencyService("USD", "EUR=0.9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := cache.NewFakeCache()
+			key := cache.ProductsNamespace + "id:" + strconv.FormatUint(uint64(product.ID), 10)
+			store.Set(key, []byte(`{}`), 0)
+			s := NewPricingService(db, currencies, productService.CacheInvalidator{Cache: store})
+
+			err = tt.write(ctx, s, product.ID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("write error = %v, want error %v", err, tt.wantErr)
+			}
+			if _, cached := store.Values[key]; cached == tt.wantDropped {
+				t.Errorf("product still cached = %v, want %v", cached, !tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-api/core/cache"
+	"go-api/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// cachedProductService serves the product reads from a cache and drops the
+// affected entries on every write made through it. Writers that bypass it
+// drop them through a CacheInvalidator.
+type cachedProductService struct {
+	ProductService
+	Cache cache.Cache
+	TTL   time.Duration
+}
+
+func NewCachedProductService(inner ProductService, store cache.Cache, ttl time.Duration) ProductService {
+	return &cachedProductService{ProductService: inner, Cache: store, TTL: ttl}
+}
+
+const productListPrefix = cache.ProductsNamespace + "list:"
+
+func productKey(id string) (string, bool) {
+	parsed, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return "", false
+	}
+	return cache.ProductsNamespace + "id:" + strconv.FormatUint(parsed, 10), true
+}
+
+// listKey derives the key of a listing from all of its arguments.
+func listKey(name string, args ...interface{}) string {
+	encoded, _ := json.Marshal(args)
+	sum := sha256.Sum256(encoded)
+	return productListPrefix + name + ":" + hex.EncodeToString(sum[:16])
+}
+
+func (s *cachedProductService) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	key := productListPrefix + "all"
+	var products []models.Product
+	if cache.GetJSON(s.Cache, key, &products) {
+		return products, nil
+	}
+	products, err := s.ProductService.GetAllProducts(ctx)
+	if err == nil {
+		cache.SetJSON(s.Cache, key, products, s.TTL)
+	}
+	return products, err
+}
+
+func (s *cachedProductService) ListProducts(ctx context.Context, query models.ProductListQuery) (models.ProductPage, error) {
+	key := listKey("page", query)
+	var page models.ProductPage
+	if cache.GetJSON(s.Cache, key, &page) {
+		return page, nil
+	}
+	page, err := s.ProductService.ListProducts(ctx, query)
+	if err == nil {
+		cache.SetJSON(s.Cache, key, page, s.TTL)
+	}
+	return page, err
+}
+
+// GetProductByID caches the product whatever the visibility, which is
+// applied to the cached product.
+func (s *cachedProductService) GetProductByID(ctx context.Context, id string, visibility models.ProductVisibility) (models.Product, error) {
+	key, ok := productKey(id)
+	if !ok {
+		return s.ProductService.GetProductByID(ctx, id, visibility)
+	}
+	var product models.Product
+	if !cache.GetJSON(s.Cache, key, &product) {
+		var err error
+		product, err = s.ProductService.GetProductByID(ctx, id, models.ProductVisibility{})
+		if err != nil {
+			return models.Product{}, err
+		}
+		cache.SetJSON(s.Cache, key, product, s.TTL)
+	}
+	if visibility.Hides(product) {
+		return models.Product{}, gorm.ErrRecordNotFound
+	}
+	return product, nil
+}
+
+func (s *cachedProductService) GetProductsByPriceRange(ctx context.Context, minPrice, maxPrice models.Money, currency, sortOrder string, visibility models.ProductVisibility) ([]models.Product, error) {
+	key := listKey("price", minPrice, maxPrice, currency, sortOrder, visibility)
+	var products []models.Product
+	if cache.GetJSON(s.Cache, key, &products) {
+		return products, nil
+	}
+	products, err := s.ProductService.GetProductsByPriceRange(ctx, minPrice, maxPrice, currency, sortOrder, visibility)
+	if err == nil {
+		cache.SetJSON(s.Cache, key, products, s.TTL)
+	}
+	return products, err
+}
+
+func (s *cachedProductService) SearchProducts(ctx context.Context, query string, minPrice, maxPrice models.Money, currency string, attributes []models.AttributeFilter, visibility models.ProductVisibility) ([]models.Product, error) {
+	key := listKey("search", query, minPrice, maxPrice, currency, attributes, visibility)
+	var products []models.Product
+	if cache.GetJSON(s.Cache, key, &products) {
+		return products, nil
+	}
+	products, err := s.ProductService.SearchProducts(ctx, query, minPrice, maxPrice, currency, attributes, visibility)
+	if err == nil {
+		cache.SetJSON(s.Cache, key, products, s.TTL)
+	}
+	return products, err
+}
+
+func (s *cachedProductService) invalidate(ids ...string) {
+	invalidateProducts(s.Cache, ids...)
+}
+
+func (s *cachedProductService) CreateProduct(ctx context.Context, input models.ProductCreateInput) (models.Product, error) {
+	product, err := s.ProductService.CreateProduct(ctx, input)
+	if err == nil {
+		s.invalidate()
+	}
+	return product, err
+}
+
+func (s *cachedProductService) UpdateProduct(ctx context.Context, id string, input models.ProductUpdateInput) (models.Product, error) {
+	product, err := s.ProductService.UpdateProduct(ctx, id, input)
+	s.invalidate(id)
+	return product, err
+}
+
+func (s *cachedProductService) DeleteProduct(ctx context.Context, id string) error {
+	err := s.ProductService.DeleteProduct(ctx, id)
+	s.invalidate(id)
+	return err
+}
+
+func (s *cachedProductService) UpdateProductStock(ctx context.Context, id string, newStock int) (models.Product, error) {
+	product, err := s.ProductService.UpdateProductStock(ctx, id, newStock)
+	s.invalidate(id)
+	return product, err
+}
+
+func (s *cachedProductService) BulkUpdatePrices(ctx context.Context, priceUpdates []models.ProductPriceUpdateInput) error {
+	err := s.ProductService.BulkUpdatePrices(ctx, priceUpdates)
+	ids := make([]string, len(priceUpdates))
+	for i, update := range priceUpdates {
+		ids[i] = update.ID
+	}
+	s.invalidate(ids...)
+	return err
+}
+
+func (s *cachedProductService) RestoreProduct(ctx context.Context, id, categoryID uint) (models.Product, error) {
+	product, err := s.ProductService.RestoreProduct(ctx, id, categoryID)
+	s.invalidate(strconv.FormatUint(uint64(id), 10))
+	return product, err
+}
+
+func (s *cachedProductService) PurgeProduct(ctx context.Context, id uint) error {
+	err := s.ProductService.PurgeProduct(ctx, id)
+	s.invalidate(strconv.FormatUint(uint64(id), 10))
+	return err
+}
+
+func (s *cachedProductService) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	count, err := s.ProductService.PurgeDeletedProducts(ctx, deletedBefore)
+	if count > 0 {
+		s.invalidate()
+	}
+	return count, err
+}
+
+// ProductInvalidator is told about writes that change what the product
+// reads return without changing the product itself, like its images,
+// attributes, prices and reviews.
+type ProductInvalidator interface {
+	// InvalidateProducts drops the given products, all of them when no id
+	// is given.
+	InvalidateProducts(ids ...uint)
+}
+
+// CacheInvalidator is a ProductListener and ProductInvalidator dropping the
+// cached entries of the products that changed, for writers that bypass the
+// product service such as catalog imports, warehouses and orders.
+type CacheInvalidator struct {
+	Cache cache.Cache
+}
+
+func (i CacheInvalidator) ProductChanged(ctx context.Context, before, after models.Product) {
+	invalidateProducts(i.Cache, strconv.FormatUint(uint64(after.ID), 10))
+}
+
+func (i CacheInvalidator) InvalidateProducts(ids ...uint) {
+	if len(ids) == 0 {
+		i.Cache.DeletePrefix(cache.ProductsNamespace)
+		i.Cache.DeletePrefix(cache.CategoriesNamespace)
+		return
+	}
+	keys := make([]string, len(ids))
+	for n, id := range ids {
+		keys[n] = strconv.FormatUint(uint64(id), 10)
+	}
+	invalidateProducts(i.Cache, keys...)
+}
+
+// invalidateProducts drops the given products, every listing, and the
+// categories whose product counts may have changed.
+func invalidateProducts(store cache.Cache, ids ...string) {
+	var keys []string
+	for _, id := range ids {
+		if key, ok := productKey(id); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		store.Delete(keys...)
+	}
+	store.DeletePrefix(productListPrefix)
+	store.DeletePrefix(cache.CategoriesNamespace)
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/core/cache"
+	"go-api/models"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// productStub answers the reads with fixed products and counts how often it
+// was asked, writes succeed without doing anything.
+type productStub struct {
+	ProductService
+	reads int
+}
+
+func (p *productStub) GetProductByID(ctx context.Context, id string, visibility models.ProductVisibility) (models.Product, error) {
+	p.reads++
+	return models.Product{Name: "Lamp " + id}, nil
+}
+
+func (p *productStub) ListProducts(ctx context.Context, query models.ProductListQuery) (models.ProductPage, error) {
+	p.reads++
+	return models.ProductPage{Data: []models.Product{{Name: "Lamp"}}, Page: query.Page}, nil
+}
+
+func (p *productStub) UpdateProductStock(ctx context.Context, id string, newStock int) (models.Product, error) {
+	return models.Product{Stock: newStock}, nil
+}
+
+func (p *productStub) DeleteProduct(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestCachedProductServiceReads(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		read      func(s ProductService) error
+		wantReads int
+	}{
+		{
+			name: "same product",
+			read: func(s ProductService) error {
+				_, err := s.GetProductByID(ctx, "7", models.ProductVisibility{})
+				return err
+			},
+			wantReads: 1,
+		},
+		{
+			name: "invalid id is never cached",
+			read: func(s ProductService) error {
+				_, err := s.GetProductByID(ctx, "lamp", models.ProductVisibility{})
+				return err
+			},
+			wantReads: 3,
+		},
+		{
+			name: "hidden product",
+			read: func(s ProductService) error {
+				_, err := s.GetProductByID(ctx, "7", models.ProductVisibility{HideInactive: true})
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("inactive product: error = %v, want not found", err)
+				}
+				return nil
+			},
+			wantReads: 1,
+		},
+		{
+			name: "same page",
+			read: func(s ProductService) error {
+				_, err := s.ListProducts(ctx, models.ProductListQuery{Page: 2})
+				return err
+			},
+			wantReads: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &productStub{}
+			store := cache.NewFakeCache()
+			s := NewCachedProductService(inner, store, 0)
+			for i := 0; i < 3; i++ {
+				if err := tt.read(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if inner.reads != tt.wantReads {
+				t.Errorf("inner service read %d times, want %d", inner.reads, tt.wantReads)
+			}
+			if store.Hits != 3-tt.wantReads {
+				t.Errorf("cache hits = %d, want %d", store.Hits, 3-tt.wantReads)
+			}
+		})
+	}
+}
+
+func TestProductWritesInvalidateCache(t *testing.T) {
+	ctx := context.Background()
+	everything := []string{productListPrefix + "*", cache.CategoriesNamespace + "*"}
+	tests := []struct {
+		name  string
+		write func(s ProductService, i CacheInvalidator)
+		want  []string
+	}{
+		{
+			name: "stock update",
+			write: func(s ProductService, i CacheInvalidator) {
+				s.UpdateProductStock(ctx, "7", 3)
+			},
+			want: append([]string{cache.ProductsNamespace + "id:7"}, everything...),
+		},
+		{
+			name: "delete",
+			write: func(s ProductService, i CacheInvalidator) {
+				s.DeleteProduct(ctx, "7")
+			},
+			want: append([]string{cache.ProductsNamespace + "id:7"}, everything...),
+		},
+		{
+			name: "listener",
+			write: func(s ProductService, i CacheInvalidator) {
+				i.ProductChanged(ctx, models.Product{}, models.Product{Model: models.Model{ID: 7}})
+			},
+			want: append([]string{cache.ProductsNamespace + "id:7"}, everything...),
+		},
+		{
+			name: "invalidator with ids",
+			write: func(s ProductService, i CacheInvalidator) {
+				i.InvalidateProducts(7, 8)
+			},
+			want: append([]string{cache.ProductsNamespace + "id:7", cache.ProductsNamespace + "id:8"}, everything...),
+		},
+		{
+			name: "invalidator without ids",
+			write: func(s ProductService, i CacheInvalidator) {
+				i.InvalidateProducts()
+			},
+			want: []string{cache.ProductsNamespace + "*", cache.CategoriesNamespace + "*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := cache.NewFakeCache()
+			s := NewCachedProductService(&productStub{}, store, 0)
+			if _, err := s.GetProductByID(ctx, "7", models.ProductVisibility{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.ListProducts(ctx, models.ProductListQuery{}); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.write(s, CacheInvalidator{Cache: store})
+			if !reflect.DeepEqual(store.Deleted, tt.want) {
+				t.Errorf("deleted %q, want %q", store.Deleted, tt.want)
+			}
+			if len(store.Values) != 0 {
+				t.Errorf("entries %v are still cached", store.Values)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-api/models"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+// productSorts whitelists the sort keys accepted by ListProducts. Prefixing a
+// key with "-" reverses its default direction.
+var productSorts = map[string]sortKey{
+	"price":      {column: "price"},
+	"name":       {column: "name"},
+	"newest":     {column: "created_at", descending: true},
+	"popularity": {column: "sold_count", descending: true},
+	"rating":     {column: "rating_average", descending: true},
+}
+
+func SortKeys() []string {
+	return []string{"price", "name", "newest", "popularity", "rating"}
+}
+
+// applyProductFilter filters on the products, with price the product price
+// in the currency of filter.MinPrice and filter.MaxPrice.
+func applyProductFilter(db *gorm.DB, filter models.ProductFilter, price clause.Expr) *gorm.DB {
+	if filter.Search != "" {
+		db = db.Where("name ILIKE ? OR description ILIKE ?", "%"+filter.Search+"%", "%"+filter.Search+"%")
+	}
+	if filter.CategoryID != nil {
+		db = db.Where("category_id = ?", *filter.CategoryID)
+	}
+	if filter.InStock != nil {
+		if *filter.InStock {
+			db = db.Where("stock > 0")
+		} else {
+			db = db.Where("stock <= 0")
+		}
+	}
+	if filter.HideInactive {
+		db = db.Where("is_active = ?", true)
+	}
+	if filter.HideOutOfStock {
+		db = db.Where("stock > 0")
+	}
+	if filter.Active != nil {
+		db = db.Where("is_active = ?", *filter.Active)
+	}
+	if filter.OnDiscount != nil {
+		if *filter.OnDiscount {
+			db = db.Where("discount_price IS NOT NULL AND discount_price < price")
+		} else {
+			db = db.Where("discount_price IS NULL OR discount_price >= price")
+		}
+	}
+	// Money binds as text, which SQLite does not convert when compared
+	// with an expression instead of a numeric column.
+	if filter.MinPrice != nil {
+		db = db.Where("? >= CAST(? AS NUMERIC)", price, *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		db = db.Where("? <= CAST(? AS NUMERIC)", price, *filter.MaxPrice)
+	}
+	if filter.CreatedAfter != nil {
+		db = db.Where("created_at > ?", *filter.CreatedAfter)
+	}
+	for _, attribute := range filter.Attributes {
+		db = applyAttributeFilter(db, attribute)
+	}
+	return db
+}
+
+// applyAttributeFilter keeps the products having a value for the attribute
+// code that matches the filter. Values are compared against every typed
+// column they can be read as, the code alone does not tell the type since
+// each category defines its own attributes.
+func applyAttributeFilter(db *gorm.DB, filter models.AttributeFilter) *gorm.DB {
+	conditions := []string{"a.code = ?"}
+	args := []interface{}{filter.Code}
+
+	if len(filter.Values) > 0 {
+		matches := []string{"v.text_value IN ?"}
+		args = append(args, filter.Values)
+
+		var numbers []float64
+		var bools []bool
+		for _, value := range filter.Values {
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				numbers = append(numbers, number)
+			}
+			if b, err := strconv.ParseBool(value); err == nil {
+				bools = append(bools, b)
+			}
+		}
+		if len(numbers) > 0 {
+			matches = append(matches, "v.number_value IN ?")
+			args = append(args, numbers)
+		}
+		if len(bools) > 0 {
+			matches = append(matches, "v.bool_value IN ?")
+			args = append(args, bools)
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	if filter.Min != nil {
+		conditions = append(conditions, "v.number_value >= ?")
+		args = append(args, *filter.Min)
+	}
+	if filter.Max != nil {
+		conditions = append(conditions, "v.number_value <= ?")
+		args = append(args, *filter.Max)
+	}
+
+	return db.Where("EXISTS (SELECT 1 FROM product_attribute_values v "+
+		"JOIN category_attributes a ON a.id = v.attribute_id AND a.deleted_at IS NULL "+
+		"WHERE v.product_id = products.id AND "+strings.Join(conditions, " AND ")+")", args...)
+}
+
+// applyProductSort orders the products by the sort keys then by ID, with
+// price the product price in a currency all products share.
+func applyProductSort(db *gorm.DB, sort []string, price clause.Expr) (*gorm.DB, error) {
+	var columns []string
+	var vars []interface{}
+	for _, key := range sort {
+		descending := strings.HasPrefix(key, "-")
+
+		sk, ok := productSorts[strings.TrimPrefix(key, "-")]
+		if !ok {
+			return nil, ErrInvalidSort
+		}
+		if descending {
+			sk.descending = !sk.descending
+		}
+
+		column := sk.column
+		if column == "price" {
+			column = "?"
+			vars = append(vars, price)
+		}
+		if sk.descending {
+			columns = append(columns, column+" DESC")
+		} else {
+			columns = append(columns, column+" ASC")
+		}
+	}
+	columns = append(columns, "id ASC")
+	return db.Order(clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(columns, ", "), Vars: vars}}), nil
+}
+
+// priceIn is the SQL expression of the product price converted to currency,
+// the base currency when empty, so that products priced in different
+// currencies compare. The rates are written as exact fractions, products in
+// a currency without a rate keep their price.
+func (s *productService) priceIn(currency string) (clause.Expr, error) {
+	if currency == "" {
+		currency = s.CurrencyService.BaseCurrency()
+	}
+	to, err := s.CurrencyService.Rate(currency)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+
+	sql := "CASE currency"
+	var vars []interface{}
+	for _, code := range s.CurrencyService.Currencies() {
+		from, err := s.CurrencyService.Rate(code)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		ratio := new(big.Rat).Quo(to, from)
+		sql += fmt.Sprintf(" WHEN ? THEN price * %s.0 / %s", ratio.Num(), ratio.Denom())
+		vars = append(vars, code)
+	}
+	return clause.Expr{SQL: sql + " ELSE price END", Vars: vars}, nil
+}
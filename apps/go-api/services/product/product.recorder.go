@@ -0,0 +1,25 @@
+package services
+
+import (
+	"context"
+	"go-api/models"
+	"sync"
+)
+
+// ProductChange is a product as it was before and after a change.
+type ProductChange struct {
+	Before, After models.Product
+}
+
+// RecordingListener is a ProductListener that records the changes it is
+// told about, for tests of services that report them.
+type RecordingListener struct {
+	mu      sync.Mutex
+	Changes []ProductChange
+}
+
+func (l *RecordingListener) ProductChanged(ctx context.Context, before, after models.Product) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Changes = append(l.Changes, ProductChange{Before: before, After: after})
+}
@@ -1,141 +1,452 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go-api/core/storage"
 	"go-api/models"
+	currencyService "go-api/services/currency"
+	"log/slog"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrStockManagedByWarehouses = errors.New("product stock is managed per warehouse")
+	ErrInvalidProduct           = errors.New("invalid product")
+	ErrCategoryTrashed          = errors.New("product category is in the trash")
+)
+
 type ProductService interface {
-	GetAllProducts() ([]models.Product, error)
-	GetProductByID(id string) (models.Product, error)
-	UpdateProduct(id string, input models.ProductUpdateInput) (models.Product, error)
-	DeleteProduct(id string) error
-	GetProductsByPriceRange(minPrice, maxPrice float64, sortOrder string) ([]models.Product, error)
-	UpdateProductStock(id string, newStock int) (models.Product, error)
-	BulkUpdatePrices(priceUpdates []models.ProductPriceUpdateInput) error
-	SearchProducts(query string, minPrice, maxPrice float64) ([]models.Product, error)
+	GetAllProducts(ctx context.Context) ([]models.Product, error)
+	ListProducts(ctx context.Context, query models.ProductListQuery) (models.ProductPage, error)
+	CreateProduct(ctx context.Context, input models.ProductCreateInput) (models.Product, error)
+	GetProductByID(ctx context.Context, id string, visibility models.ProductVisibility) (models.Product, error)
+	UpdateProduct(ctx context.Context, id string, input models.ProductUpdateInput) (models.Product, error)
+	DeleteProduct(ctx context.Context, id string) error
+	GetProductsByPriceRange(ctx context.Context, minPrice, maxPrice models.Money, currency, sortOrder string, visibility models.ProductVisibility) ([]models.Product, error)
+	UpdateProductStock(ctx context.Context, id string, newStock int) (models.Product, error)
+	BulkUpdatePrices(ctx context.Context, priceUpdates []models.ProductPriceUpdateInput) error
+	SearchProducts(ctx context.Context, query string, minPrice, maxPrice models.Money, currency string, attributes []models.AttributeFilter, visibility models.ProductVisibility) ([]models.Product, error)
+	GetDeletedProducts(ctx context.Context) ([]models.Product, error)
+	RestoreProduct(ctx context.Context, id, categoryID uint) (models.Product, error)
+	PurgeProduct(ctx context.Context, id uint) error
+	PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// ProductListener is told about every saved change to a product, with the
+// product as it was before and after the change. Before is the zero Product
+// for a new one.
+type ProductListener interface {
+	ProductChanged(ctx context.Context, before, after models.Product)
 }
 
 type productService struct {
-	DB *gorm.DB
+	DB              *gorm.DB
+	CurrencyService currencyService.CurrencyService
+	// Storage holds the image files, they are removed with purged products.
+	Storage   storage.Storage
+	Listeners []ProductListener
 }
 
-func NewProductService(db *gorm.DB) ProductService {
-	return &productService{DB: db}
+func NewProductService(db *gorm.DB, currencyService currencyService.CurrencyService, store storage.Storage, listeners ...ProductListener) ProductService {
+	return &productService{DB: db, CurrencyService: currencyService, Storage: store, Listeners: listeners}
+}
+
+func (s *productService) changed(ctx context.Context, before, after models.Product) {
+	for _, listener := range s.Listeners {
+		listener.ProductChanged(ctx, before, after)
+	}
 }
 
-func (s *productService) GetAllProducts() ([]models.Product, error) {
+func (s *productService) GetAllProducts(ctx context.Context) ([]models.Product, error) {
 	var products []models.Product
-	if err := s.DB.Find(&products).Error; err != nil {
+	if err := s.DB.WithContext(ctx).Find(&products).Error; err != nil {
 		return nil, err
 	}
 	return products, nil
 }
 
-func (s *productService) CreateProduct(input models.ProductCreateInput) (models.Product, error) {
+// CreateProduct saves a new product. Its currency defaults to the base
+// currency and its tax class to the standard one. A SKU, when given, must
+// not be used by another product, trashed ones included.
+func (s *productService) CreateProduct(ctx context.Context, input models.ProductCreateInput) (models.Product, error) {
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if currency == "" {
+		currency = s.CurrencyService.BaseCurrency()
+	}
+	if !s.CurrencyService.Supports(currency) {
+		return models.Product{}, fmt.Errorf("%w: unsupported currency %s", ErrInvalidProduct, currency)
+	}
+	taxClass := input.TaxClass
+	if taxClass == "" {
+		taxClass = models.TaxClassStandard
+	}
+
+	var category models.Category
+	if err := s.DB.WithContext(ctx).First(&category, input.CategoryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Product{}, fmt.Errorf("%w: unknown category %d", ErrInvalidProduct, input.CategoryID)
+		}
+		return models.Product{}, err
+	}
+	sku := strings.TrimSpace(input.SKU)
+	if sku != "" {
+		var taken int64
+		if err := s.DB.WithContext(ctx).Unscoped().Model(&models.Product{}).Where("sku = ?", sku).Count(&taken).Error; err != nil {
+			return models.Product{}, err
+		}
+		if taken > 0 {
+			return models.Product{}, fmt.Errorf("%w: sku %s is already used", ErrInvalidProduct, sku)
+		}
+	}
+
 	product := models.Product{
-		Name:  input.Name,
-		Price: input.Price,
+		Name:             input.Name,
+		Description:      input.Description,
+		Price:            input.Price,
+		Quantity:         input.Quantity,
+		Image:            input.Image,
+		CategoryID:       category.ID,
+		Category:         category,
+		DiscountPrice:    input.DiscountPrice,
+		Currency:         currency,
+		TaxClass:         taxClass,
+		WeightGrams:      input.WeightGrams,
+		LengthMm:         input.LengthMm,
+		WidthMm:          input.WidthMm,
+		HeightMm:         input.HeightMm,
+		IsActive:         input.IsActive,
+		Stock:            input.Stock,
+		SKU:              sku,
+		ReorderThreshold: input.ReorderThreshold,
 	}
-	if err := s.DB.Create(&product).Error; err != nil {
+	if err := s.DB.WithContext(ctx).Create(&product).Error; err != nil {
 		return models.Product{}, err
 	}
+	s.changed(ctx, models.Product{}, product)
 	return product, nil
 }
 
-func (s *productService) UpdateProduct(id string, input models.ProductUpdateInput) (models.Product, error) {
+// UpdateProduct saves the changes to a product. The currency, when given,
+// must be supported like on CreateProduct.
+func (s *productService) UpdateProduct(ctx context.Context, id string, input models.ProductUpdateInput) (models.Product, error) {
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if currency != "" && !s.CurrencyService.Supports(currency) {
+		return models.Product{}, fmt.Errorf("%w: unsupported currency %s", ErrInvalidProduct, currency)
+	}
+
 	var product models.Product
-	if err := s.DB.First(&product, id).Error; err != nil {
+	if err := s.DB.WithContext(ctx).First(&product, id).Error; err != nil {
 		return models.Product{}, err
 	}
+	before := product
 	product.Name = input.Name
 	product.Price = input.Price
-	if err := s.DB.Save(&product).Error; err != nil {
+	if currency != "" {
+		product.Currency = currency
+	}
+	if input.TaxClass != "" {
+		product.TaxClass = input.TaxClass
+	}
+	if input.WeightGrams != 0 {
+		product.WeightGrams = input.WeightGrams
+	}
+	if input.LengthMm != 0 || input.WidthMm != 0 || input.HeightMm != 0 {
+		product.LengthMm = input.LengthMm
+		product.WidthMm = input.WidthMm
+		product.HeightMm = input.HeightMm
+	}
+	if input.DiscountPrice != nil {
+		product.DiscountPrice = input.DiscountPrice
+	}
+	if input.ReorderThreshold != nil {
+		product.ReorderThreshold = *input.ReorderThreshold
+	}
+	if err := s.DB.WithContext(ctx).Save(&product).Error; err != nil {
 		return models.Product{}, err
 	}
+	s.changed(ctx, before, product)
 	return product, nil
 }
 
-func (s *productService) DeleteProduct(id string) error {
-	if err := s.DB.Delete(&models.Product{}, id).Error; err != nil {
+// DeleteProduct moves a product to the trash. Deleting a product that does
+// not exist does nothing.
+func (s *productService) DeleteProduct(ctx context.Context, id string) error {
+	var product models.Product
+	result := s.DB.WithContext(ctx).Limit(1).Find(&product, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	before := product
+	if err := s.DB.WithContext(ctx).Delete(&product).Error; err != nil {
 		return err
 	}
+	product.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.changed(ctx, before, product)
 	return nil
 }
 
-func (s *productService) GetProductsByPriceRange(minPrice, maxPrice float64, sortOrder string) ([]models.Product, error) {
+// ListProducts returns a page of the filtered products. Prices are filtered
+// and sorted in query.Currency whatever the currency of each product.
+func (s *productService) ListProducts(ctx context.Context, query models.ProductListQuery) (models.ProductPage, error) {
+	price, err := s.priceIn(query.Currency)
+	if err != nil {
+		return models.ProductPage{}, err
+	}
+	dbQuery, err := applyProductSort(applyProductFilter(s.DB.WithContext(ctx).Model(&models.Product{}), query.ProductFilter, price), query.Sort, price)
+	if err != nil {
+		return models.ProductPage{}, err
+	}
+
+	page := models.ProductPage{
+		Data:  []models.Product{},
+		Page:  query.Page,
+		Limit: query.Limit,
+	}
+
+	if err := dbQuery.Count(&page.Total).Error; err != nil {
+		return models.ProductPage{}, err
+	}
+
+	offset := (query.Page - 1) * query.Limit
+	if err := dbQuery.Offset(offset).Limit(query.Limit).Find(&page.Data).Error; err != nil {
+		return models.ProductPage{}, err
+	}
+
+	return page, nil
+}
+
+// GetProductsByPriceRange returns the products priced between minPrice and
+// maxPrice once converted to currency.
+func (s *productService) GetProductsByPriceRange(ctx context.Context, minPrice, maxPrice models.Money, currency, sortOrder string, visibility models.ProductVisibility) ([]models.Product, error) {
 	var products []models.Product
+	price, err := s.priceIn(currency)
+	if err != nil {
+		return nil, err
+	}
 
-	sort := "price ASC"
+	sort := []string{"price"}
 	if sortOrder == "desc" {
-		sort = "price DESC"
+		sort = []string{"-price"}
 	}
 
-	result := s.DB.Where("price BETWEEN ? AND ?", minPrice, maxPrice).Order(sort).Find(&products)
-	if result.Error != nil {
-		return nil, result.Error
+	dbQuery := applyProductFilter(s.DB.WithContext(ctx), models.ProductFilter{ProductVisibility: visibility, MinPrice: &minPrice, MaxPrice: &maxPrice}, price)
+	dbQuery, err = applyProductSort(dbQuery, sort, price)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dbQuery.Find(&products).Error; err != nil {
+		return nil, err
 	}
 	return products, nil
 }
 
-func (s *productService) GetProductByID(id string) (models.Product, error) {
+// GetProductByID returns a product with its images and attributes. A
+// product the visibility hides is not found.
+func (s *productService) GetProductByID(ctx context.Context, id string, visibility models.ProductVisibility) (models.Product, error) {
 	var product models.Product
 
-	if err := s.DB.First(&product, id).Error; err != nil {
+	err := s.DB.WithContext(ctx).Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position").Order("id")
+	}).Preload("Attributes.Attribute").First(&product, id).Error
+	if err != nil {
 		return models.Product{}, err
 	}
+	if visibility.Hides(product) {
+		return models.Product{}, gorm.ErrRecordNotFound
+	}
 	return product, nil
 }
 
-func (s *productService) UpdateProductStock(id string, newStock int) (models.Product, error) {
+// UpdateProductStock sets the stock of a product that is not stocked in
+// warehouses, whose stock is the sum of its warehouse levels instead.
+func (s *productService) UpdateProductStock(ctx context.Context, id string, newStock int) (models.Product, error) {
 	var product models.Product
-	if err := s.DB.First(&product, id).Error; err != nil {
+	if err := s.DB.WithContext(ctx).First(&product, id).Error; err != nil {
 		return models.Product{}, err
 	}
 
+	var levels int64
+	if err := s.DB.WithContext(ctx).Model(&models.WarehouseStock{}).Where("product_id = ?", product.ID).Count(&levels).Error; err != nil {
+		return models.Product{}, err
+	}
+	if levels > 0 {
+		return models.Product{}, ErrStockManagedByWarehouses
+	}
+
+	before := product
 	product.Stock = newStock
 
-	if err := s.DB.Save(&product).Error; err != nil {
+	if err := s.DB.WithContext(ctx).Save(&product).Error; err != nil {
 		return models.Product{}, err
 	}
+	s.changed(ctx, before, product)
 
 	return product, nil
 }
 
-func (s *productService) BulkUpdatePrices(priceUpdates []models.ProductPriceUpdateInput) error {
+func (s *productService) BulkUpdatePrices(ctx context.Context, priceUpdates []models.ProductPriceUpdateInput) error {
 	for _, update := range priceUpdates {
 		var product models.Product
-		if err := s.DB.First(&product, update.ID).Error; err != nil {
+		if err := s.DB.WithContext(ctx).First(&product, update.ID).Error; err != nil {
 			return fmt.Errorf("product with ID %s not found", update.ID)
 		}
 
+		before := product
 		product.Price = update.Price
 
-		if err := s.DB.Save(&product).Error; err != nil {
+		if err := s.DB.WithContext(ctx).Save(&product).Error; err != nil {
 			return fmt.Errorf("failed to update price for product ID %s: %v", update.ID, err)
 		}
+		s.changed(ctx, before, product)
 	}
 	return nil
 }
 
-func (s *productService) SearchProducts(query string, minPrice, maxPrice float64) ([]models.Product, error) {
+// SearchProducts matches the products on name, description and attributes.
+// The price bounds are in currency and only apply when both are given.
+func (s *productService) SearchProducts(ctx context.Context, query string, minPrice, maxPrice models.Money, currency string, attributes []models.AttributeFilter, visibility models.ProductVisibility) ([]models.Product, error) {
 	var products []models.Product
-
-	dbQuery := s.DB.Model(&models.Product{})
-
-	if query != "" {
-		dbQuery = dbQuery.Where("name ILIKE ? OR description ILIKE ?", "%"+query+"%", "%"+query+"%")
+	price, err := s.priceIn(currency)
+	if err != nil {
+		return nil, err
 	}
 
+	filter := models.ProductFilter{ProductVisibility: visibility, Search: query, Attributes: attributes}
 	if minPrice > 0 && maxPrice > 0 {
-		dbQuery = dbQuery.Where("price BETWEEN ? AND ?", minPrice, maxPrice)
+		filter.MinPrice = &minPrice
+		filter.MaxPrice = &maxPrice
 	}
 
-	if err := dbQuery.Find(&products).Error; err != nil {
+	if err := applyProductFilter(s.DB.WithContext(ctx).Model(&models.Product{}), filter, price).Find(&products).Error; err != nil {
 		return nil, err
 	}
 
 	return products, nil
 }
+
+func (s *productService) GetDeletedProducts(ctx context.Context) ([]models.Product, error) {
+	var products []models.Product
+	if err := s.DB.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// RestoreProduct takes a product out of the trash. A product whose category
+// is trashed too is only restored when categoryID moves it to another one.
+func (s *productService) RestoreProduct(ctx context.Context, id, categoryID uint) (models.Product, error) {
+	var product models.Product
+	if err := s.DB.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&product, id).Error; err != nil {
+		return models.Product{}, err
+	}
+
+	before := product
+	if categoryID != 0 {
+		if err := s.DB.WithContext(ctx).Select("id").First(&models.Category{}, categoryID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.Product{}, fmt.Errorf("%w: unknown category %d", ErrInvalidProduct, categoryID)
+			}
+			return models.Product{}, err
+		}
+		product.CategoryID = categoryID
+	} else {
+		var categories int64
+		if err := s.DB.WithContext(ctx).Model(&models.Category{}).Where("id = ?", product.CategoryID).Count(&categories).Error; err != nil {
+			return models.Product{}, err
+		}
+		if categories == 0 {
+			return models.Product{}, ErrCategoryTrashed
+		}
+	}
+
+	err := s.DB.WithContext(ctx).Unscoped().Model(&product).Updates(map[string]interface{}{
+		"deleted_at":  nil,
+		"category_id": product.CategoryID,
+	}).Error
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	product.DeletedAt = gorm.DeletedAt{}
+	s.changed(ctx, before, product)
+	return product, nil
+}
+
+// PurgeProduct permanently removes a trashed product together with its
+// prices, reviews, subscriptions, stock levels, wishlist entries and images.
+func (s *productService) PurgeProduct(ctx context.Context, id uint) error {
+	var product models.Product
+	if err := s.DB.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&product, id).Error; err != nil {
+		return err
+	}
+	_, err := s.purge(ctx, []uint{product.ID})
+	return err
+}
+
+func (s *productService) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var ids []uint
+	err := s.DB.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return s.purge(ctx, ids)
+}
+
+// productDependents are the rows that only make sense with their product,
+// orders and stock movements keep referring to purged products.
+var productDependents = []interface{}{
+	&models.ProductPrice{},
+	&models.Review{},
+	&models.ProductSubscription{},
+	&models.WarehouseStock{},
+	&models.WishlistItem{},
+	&models.ProductAttributeValue{},
+	&models.ProductImage{},
+}
+
+// purge deletes the products and their dependents in one transaction, then
+// removes their image files.
+func (s *productService) purge(ctx context.Context, ids []uint) (int64, error) {
+	var images []models.ProductImage
+	var purged int64
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("product_id IN ?", ids).Find(&images).Error; err != nil {
+			return err
+		}
+		for _, dependent := range productDependents {
+			if err := tx.Unscoped().Where("product_id IN ?", ids).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Product{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// A file left behind is only wasted space.
+	for _, image := range images {
+		for _, key := range []string{image.StorageKey, image.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := s.Storage.Delete(key); err != nil {
+				slog.WarnContext(ctx, "Error deleting stored file", "key", key, "error", err)
+			}
+		}
+	}
+	return purged, nil
+}
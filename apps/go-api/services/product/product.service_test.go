@@ -0,0 +1,465 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// storageStub keeps no files and records the deleted keys.
+type storageStub struct {
+	deleted []string
+}
+
+func (s *storageStub) Put(key string, data []byte, contentType string) error { return nil }
+func (s *storageStub) URL(key string) string                                 { return "/" + key }
+
+func (s *storageStub) Delete(key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.Category{}, &models.Product{}, &models.ProductImage{}, &models.ProductPrice{},
+		&models.Review{}, &models.ProductSubscription{}, &models.Warehouse{}, &models.WarehouseStock{},
+		&models.Wishlist{}, &models.WishlistItem{}, &models.CategoryAttribute{}, &models.ProductAttributeValue{})
+}
+
+// newCurrencyService values a euro at 1.11 dollars.
+func newCurrencyService(t *testing.T) currencyService.CurrencyService {
+	t.Helper()
+	currencies, err := currencyService.NewCurrencyService("USD", "EUR=0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return currencies
+}
+
+// trashedProduct creates a product with a row in every dependent table and
+// moves it to the trash.
+func trashedProduct(t *testing.T, db *gorm.DB, name string, deletedAt time.Time) models.Product {
+	t.Helper()
+	product := dbtest.Product(t, db, models.Product{Name: name, SKU: name})
+	warehouse := models.Warehouse{Code: name, Name: name, IsActive: true}
+	wishlist := models.Wishlist{UserID: "user", Name: name}
+	attribute := models.CategoryAttribute{CategoryID: product.CategoryID, Code: "color", Name: "Color", Type: models.AttributeText}
+	dbtest.Create(t, db, &warehouse, &wishlist, &attribute)
+	color := "red"
+	dbtest.Create(t, db,
+		&models.ProductImage{ProductID: product.ID, StorageKey: name + ".jpg", ThumbnailKey: name + "-thumb.jpg"},
+		&models.ProductPrice{ProductID: product.ID, Currency: "EUR", Price: 900},
+		&models.Review{ProductID: product.ID, UserID: "user", Rating: 5},
+		&models.ProductSubscription{ProductID: product.ID, UserID: "user", Type: models.SubscriptionBackInStock},
+		&models.WarehouseStock{ProductID: product.ID, WarehouseID: warehouse.ID, Quantity: 3},
+		&models.WishlistItem{ProductID: product.ID, WishlistID: wishlist.ID},
+		&models.ProductAttributeValue{ProductID: product.ID, AttributeID: attribute.ID, TextValue: &color},
+	)
+	if err := db.Model(&product).Update("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
+
+// dependents counts the rows left for the product in each dependent table.
+func dependents(t *testing.T, db *gorm.DB, productID uint) map[string]int64 {
+	t.Helper()
+	tables := []string{"products", "product_images", "product_prices", "reviews", "product_subscriptions",
+		"warehouse_stocks", "wishlist_items", "product_attribute_values"}
+	counts := map[string]int64{}
+	for _, table := range tables {
+		column := "product_id"
+		if table == "products" {
+			column = "id"
+		}
+		var count int64
+		if err := db.Table(table).Where(column+" = ?", productID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		counts[table] = count
+	}
+	return counts
+}
+
+func TestPurgeProducts(t *testing.T) {
+	tests := []struct {
+		name       string
+		purge      func(s ProductService, old, recent models.Product) error
+		wantPurged []string
+	}{
+		{
+			name: "one product",
+			purge: func(s ProductService, old, recent models.Product) error {
+				return s.PurgeProduct(context.Background(), recent.ID)
+			},
+			wantPurged: []string{"recent"},
+		},
+		{
+			name: "expired products",
+			purge: func(s ProductService, old, recent models.Product) error {
+				purged, err := s.PurgeDeletedProducts(context.Background(), time.Now().Add(-24*time.Hour))
+				if err == nil && purged != 1 {
+					t.Errorf("purged %d products, want 1", purged)
+				}
+				return err
+			},
+			wantPurged: []string{"old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			store := &storageStub{}
+			s := NewProductService(db, newCurrencyService(t), store)
+			products := map[string]models.Product{
+				"old":    trashedProduct(t, db, "old", time.Now().Add(-48*time.Hour)),
+				"recent": trashedProduct(t, db, "recent", time.Now()),
+			}
+
+			if err := tt.purge(s, products["old"], products["recent"]); err != nil {
+				t.Fatal(err)
+			}
+
+			var wantDeleted []string
+			for name, product := range products {
+				want := int64(1)
+				for _, purged := range tt.wantPurged {
+					if purged == name {
+						want = 0
+						wantDeleted = append(wantDeleted, name+".jpg", name+"-thumb.jpg")
+					}
+				}
+				for table, count := range dependents(t, db, product.ID) {
+					if count != want {
+						t.Errorf("%s: %d rows left in %s, want %d", name, count, table, want)
+					}
+				}
+			}
+			sort.Strings(store.deleted)
+			sort.Strings(wantDeleted)
+			if !reflect.DeepEqual(store.deleted, wantDeleted) {
+				t.Errorf("deleted files %q, want %q", store.deleted, wantDeleted)
+			}
+		})
+	}
+}
+
+func TestRestoreProduct(t *testing.T) {
+	tests := []struct {
+		name             string
+		trashCategory    bool
+		target           string
+		wantErr          error
+		wantCategoryOf   string
+		wantStillTrashed bool
+	}{
+		{name: "category kept", wantCategoryOf: "lamp"},
+		{name: "category trashed", trashCategory: true, wantErr: ErrCategoryTrashed, wantStillTrashed: true},
+		{name: "moved out of a trashed category", trashCategory: true, target: "desk", wantCategoryOf: "desk"},
+		{name: "moved to a trashed category", target: "trashed", wantErr: ErrInvalidProduct, wantStillTrashed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+			listener := &RecordingListener{}
+			s := NewProductService(db, newCurrencyService(t), &storageStub{}, listener)
+
+			products := map[string]models.Product{
+				"lamp":    dbtest.Product(t, db, models.Product{Name: "lamp"}),
+				"desk":    dbtest.Product(t, db, models.Product{Name: "desk"}),
+				"trashed": dbtest.Product(t, db, models.Product{Name: "trashed"}),
+			}
+			if err := db.Delete(&models.Category{}, products["trashed"].CategoryID).Error; err != nil {
+				t.Fatal(err)
+			}
+			lamp := products["lamp"]
+			if err := s.DeleteProduct(ctx, strconv.FormatUint(uint64(lamp.ID), 10)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.trashCategory {
+				if err := db.Delete(&models.Category{}, lamp.CategoryID).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			listener.Changes = nil
+
+			var target uint
+			if tt.target != "" {
+				target = products[tt.target].CategoryID
+			}
+			restored, err := s.RestoreProduct(ctx, lamp.ID, target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RestoreProduct error = %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.Product
+			if err := db.Unscoped().First(&stored, lamp.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.DeletedAt.Valid != tt.wantStillTrashed {
+				t.Errorf("trashed = %v, want %v", stored.DeletedAt.Valid, tt.wantStillTrashed)
+			}
+			if tt.wantErr != nil {
+				if len(listener.Changes) > 0 {
+					t.Errorf("listeners saw %+v for a failed restore", listener.Changes)
+				}
+				return
+			}
+			if want := products[tt.wantCategoryOf].CategoryID; stored.CategoryID != want || restored.CategoryID != want {
+				t.Errorf("category = %d, returned %d, want %d", stored.CategoryID, restored.CategoryID, want)
+			}
+			if len(listener.Changes) != 1 || !listener.Changes[0].Before.DeletedAt.Valid || listener.Changes[0].After.DeletedAt.Valid {
+				t.Errorf("listeners saw %+v, want the restore", listener.Changes)
+			}
+		})
+	}
+}
+
+func TestProductVisibility(t *testing.T) {
+	reads := map[string]func(s ProductService, visibility models.ProductVisibility) ([]models.Product, error){
+		"list": func(s ProductService, visibility models.ProductVisibility) ([]models.Product, error) {
+			page, err := s.ListProducts(context.Background(), models.ProductListQuery{
+				ProductFilter: models.ProductFilter{ProductVisibility: visibility}, Page: 1, Limit: 10,
+			})
+			return page.Data, err
+		},
+		"price range": func(s ProductService, visibility models.ProductVisibility) ([]models.Product, error) {
+			return s.GetProductsByPriceRange(context.Background(), 0, 10000, "", "", visibility)
+		},
+		"search": func(s ProductService, visibility models.ProductVisibility) ([]models.Product, error) {
+			return s.SearchProducts(context.Background(), "", 0, 0, "", nil, visibility)
+		},
+		"by id": func(s ProductService, visibility models.ProductVisibility) ([]models.Product, error) {
+			var products []models.Product
+			for _, id := range []string{"1", "2", "3"} {
+				product, err := s.GetProductByID(context.Background(), id, visibility)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				products = append(products, product)
+			}
+			return products, nil
+		},
+	}
+	tests := []struct {
+		name       string
+		visibility models.ProductVisibility
+		want       []string
+	}{
+		{name: "admin", want: []string{"Active", "Inactive", "Out of stock"}},
+		{name: "public", visibility: models.ProductVisibility{HideInactive: true}, want: []string{"Active", "Out of stock"}},
+		{name: "in stock", visibility: models.ProductVisibility{HideInactive: true, HideOutOfStock: true}, want: []string{"Active"}},
+	}
+
+	db := newTestDB(t)
+	s := NewProductService(db, newCurrencyService(t), &storageStub{})
+	category := models.Category{Name: "Lamps"}
+	dbtest.Create(t, db, &category)
+	dbtest.Create(t, db,
+		&models.Product{Name: "Active", Price: 1000, Stock: 3, IsActive: true, CategoryID: category.ID},
+		&models.Product{Name: "Inactive", Price: 1000, Stock: 3, CategoryID: category.ID},
+		&models.Product{Name: "Out of stock", Price: 1000, IsActive: true, CategoryID: category.ID},
+	)
+
+	for _, tt := range tests {
+		for read, products := range reads {
+			t.Run(tt.name+" "+read, func(t *testing.T) {
+				got, err := products(s, tt.visibility)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, product := range got {
+					names = append(names, product.Name)
+				}
+				sort.Strings(names)
+				if !reflect.DeepEqual(names, tt.want) {
+					t.Errorf("products = %v, want %v", names, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestProductPricesInCurrencies(t *testing.T) {
+	money := func(amount models.Money) *models.Money { return &amount }
+	tests := []struct {
+		name string
+		read func(s ProductService) ([]models.Product, error)
+		want []string
+	}{
+		{
+			name: "list in the base currency",
+			read: func(s ProductService) ([]models.Product, error) {
+				page, err := s.ListProducts(context.Background(), models.ProductListQuery{
+					ProductFilter: models.ProductFilter{MinPrice: money(1000), MaxPrice: money(1000)}, Page: 1, Limit: 10,
+				})
+				return page.Data, err
+			},
+			want: []string{"Lamp", "Desk"},
+		},
+		{
+			name: "list in euros",
+			read: func(s ProductService) ([]models.Product, error) {
+				page, err := s.ListProducts(context.Background(), models.ProductListQuery{
+					ProductFilter: models.ProductFilter{MinPrice: money(1000), Currency: "EUR"}, Page: 1, Limit: 10,
+				})
+				return page.Data, err
+			},
+			want: []string{"Chair"},
+		},
+		{
+			name: "list sorted by price",
+			read: func(s ProductService) ([]models.Product, error) {
+				page, err := s.ListProducts(context.Background(), models.ProductListQuery{Sort: []string{"-price"}, Page: 1, Limit: 10})
+				return page.Data, err
+			},
+			want: []string{"Chair", "Lamp", "Desk", "Vase"},
+		},
+		{
+			name: "price range in euros",
+			read: func(s ProductService) ([]models.Product, error) {
+				return s.GetProductsByPriceRange(context.Background(), 500, 900, "EUR", "desc", models.ProductVisibility{})
+			},
+			want: []string{"Lamp", "Desk", "Vase"},
+		},
+		{
+			name: "search in the base currency",
+			read: func(s ProductService) ([]models.Product, error) {
+				return s.SearchProducts(context.Background(), "", 1500, 3000, "", nil, models.ProductVisibility{})
+			},
+			want: []string{"Chair"},
+		},
+	}
+
+	db := newTestDB(t)
+	s := NewProductService(db, newCurrencyService(t), &storageStub{})
+	category := models.Category{Name: "Furniture"}
+	dbtest.Create(t, db, &category)
+	dbtest.Create(t, db,
+		&models.Product{Name: "Lamp", Price: 1000, Currency: "USD", CategoryID: category.ID},
+		&models.Product{Name: "Desk", Price: 900, Currency: "EUR", CategoryID: category.ID},
+		&models.Product{Name: "Chair", Price: 1800, Currency: "EUR", CategoryID: category.ID},
+		&models.Product{Name: "Vase", Price: 600, Currency: "USD", CategoryID: category.ID},
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := tt.read(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, product := range products {
+				names = append(names, product.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("products = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateProductCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		wantErr  error
+		want     string
+	}{
+		{name: "kept", want: "USD"},
+		{name: "normalised", currency: " eur ", want: "EUR"},
+		{name: "unsupported", currency: "GBP", wantErr: ErrInvalidProduct, want: "USD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := NewProductService(db, newCurrencyService(t), &storageStub{})
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", Price: 1000, Currency: "USD"})
+
+			_, err := s.UpdateProduct(context.Background(), strconv.FormatUint(uint64(product.ID), 10),
+				models.ProductUpdateInput{Name: "Lamp", Price: 1000, Currency: tt.currency})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateProduct error = %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.Product
+			if err := db.First(&stored, product.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Currency != tt.want {
+				t.Errorf("currency = %q, want %q", stored.Currency, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchProductsIgnoresCase(t *testing.T) {
+	reads := map[string]func(s ProductService, search string) ([]models.Product, error){
+		"list": func(s ProductService, search string) ([]models.Product, error) {
+			page, err := s.ListProducts(context.Background(), models.ProductListQuery{
+				ProductFilter: models.ProductFilter{Search: search}, Page: 1, Limit: 10,
+			})
+			return page.Data, err
+		},
+		"search": func(s ProductService, search string) ([]models.Product, error) {
+			return s.SearchProducts(context.Background(), search, 0, 0, "", nil, models.ProductVisibility{})
+		},
+	}
+	tests := []struct {
+		name   string
+		search string
+		want   []string
+	}{
+		{name: "name", search: "LAMP", want: []string{"Desk lamp", "Floor Lamp"}},
+		{name: "description", search: "Oak", want: []string{"Chair"}},
+		{name: "no match", search: "sofa"},
+	}
+
+	// ILIKE only exists on Postgres.
+	db := dbtest.Postgres(t, &models.Category{}, &models.Product{}, &models.ProductPrice{},
+		&models.CategoryAttribute{}, &models.ProductAttributeValue{})
+	s := NewProductService(db, newCurrencyService(t), &storageStub{})
+	category := models.Category{Name: "Furniture"}
+	dbtest.Create(t, db, &category)
+	dbtest.Create(t, db,
+		&models.Product{Name: "Desk lamp", Price: 1000, CategoryID: category.ID},
+		&models.Product{Name: "Floor Lamp", Price: 1000, CategoryID: category.ID},
+		&models.Product{Name: "Chair", Description: "Solid oak", Price: 1000, CategoryID: category.ID},
+	)
+
+	for _, tt := range tests {
+		for read, products := range reads {
+			t.Run(tt.name+" "+read, func(t *testing.T) {
+				got, err := products(s, tt.search)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, product := range got {
+					names = append(names, product.Name)
+				}
+				sort.Strings(names)
+				if !reflect.DeepEqual(names, tt.want) {
+					t.Errorf("products = %v, want %v", names, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidPromotion  = errors.New("invalid promotion")
+	ErrDuplicateCode     = errors.New("coupon code already exists")
+	ErrUsageLimitReached = errors.New("promotion usage limit reached")
+)
+
+type PromotionService interface {
+	GetPromotions(ctx context.Context) ([]models.Promotion, error)
+	GetPromotionByID(ctx context.Context, id uint) (models.Promotion, error)
+	CreatePromotion(ctx context.Context, input models.PromotionInput) (models.Promotion, error)
+	UpdatePromotion(ctx context.Context, id uint, input models.PromotionInput) (models.Promotion, error)
+	DeletePromotion(ctx context.Context, id uint) error
+	Evaluate(ctx context.Context, cart *models.Cart, userID string, codes []string) error
+	RedeemTx(tx *gorm.DB, cart models.Cart, userID string, orderID uint) error
+	ReleaseTx(tx *gorm.DB, orderID uint) error
+}
+
+type promotionService struct {
+	DB              *gorm.DB
+	CurrencyService currencyService.CurrencyService
+}
+
+func NewPromotionService(db *gorm.DB, currencyService currencyService.CurrencyService) PromotionService {
+	return &promotionService{DB: db, CurrencyService: currencyService}
+}
+
+func (s *promotionService) GetPromotions(ctx context.Context) ([]models.Promotion, error) {
+	promotions := []models.Promotion{}
+	err := s.DB.WithContext(ctx).Preload("Products").Preload("Categories").Order("priority DESC, id").Find(&promotions).Error
+	return promotions, err
+}
+
+func (s *promotionService) GetPromotionByID(ctx context.Context, id uint) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := s.DB.WithContext(ctx).Preload("Products").Preload("Categories").First(&promotion, id).Error
+	return promotion, err
+}
+
+func (s *promotionService) CreatePromotion(ctx context.Context, input models.PromotionInput) (models.Promotion, error) {
+	var promotion models.Promotion
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.save(tx, &promotion, input)
+	})
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	return s.GetPromotionByID(ctx, promotion.ID)
+}
+
+func (s *promotionService) UpdatePromotion(ctx context.Context, id uint, input models.PromotionInput) (models.Promotion, error) {
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var promotion models.Promotion
+		if err := tx.First(&promotion, id).Error; err != nil {
+			return err
+		}
+		return s.save(tx, &promotion, input)
+	})
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	return s.GetPromotionByID(ctx, id)
+}
+
+func (s *promotionService) DeletePromotion(ctx context.Context, id uint) error {
+	result := s.DB.WithContext(ctx).Delete(&models.Promotion{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *promotionService) save(tx *gorm.DB, promotion *models.Promotion, input models.PromotionInput) error {
+	if err := validatePromotion(&input); err != nil {
+		return err
+	}
+
+	if input.Code != nil {
+		var count int64
+		if err := tx.Unscoped().Model(&models.Promotion{}).Where("code = ? AND id <> ?", *input.Code, promotion.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrDuplicateCode
+		}
+	}
+
+	var products []models.Product
+	if len(input.ProductIDs) > 0 {
+		if err := tx.Where("id IN ?", input.ProductIDs).Find(&products).Error; err != nil {
+			return err
+		}
+		if len(products) != len(input.ProductIDs) {
+			return fmt.Errorf("%w: unknown product in product_ids", ErrInvalidPromotion)
+		}
+	}
+
+	var categories []models.Category
+	if len(input.CategoryIDs) > 0 {
+		if err := tx.Where("id IN ?", input.CategoryIDs).Find(&categories).Error; err != nil {
+			return err
+		}
+		if len(categories) != len(input.CategoryIDs) {
+			return fmt.Errorf("%w: unknown category in category_ids", ErrInvalidPromotion)
+		}
+	}
+
+	promotion.Name = input.Name
+	promotion.Code = input.Code
+	promotion.Type = input.Type
+	promotion.PercentOff = input.PercentOff
+	promotion.AmountOff = input.AmountOff
+	promotion.BuyQuantity = input.BuyQuantity
+	promotion.GetQuantity = input.GetQuantity
+	promotion.MinSubtotal = input.MinSubtotal
+	promotion.UsageLimit = input.UsageLimit
+	promotion.UsageLimitPerUser = input.UsageLimitPerUser
+	promotion.StartsAt = input.StartsAt
+	promotion.EndsAt = input.EndsAt
+	promotion.Stackable = input.Stackable
+	promotion.Priority = input.Priority
+	promotion.IsActive = input.IsActive
+
+	if err := tx.Omit(clause.Associations).Save(promotion).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(promotion).Association("Products").Replace(products); err != nil {
+		return err
+	}
+	return tx.Model(promotion).Association("Categories").Replace(categories)
+}
+
+func validatePromotion(input *models.PromotionInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPromotion)
+	}
+
+	if input.Code != nil {
+		code := strings.ToUpper(strings.TrimSpace(*input.Code))
+		input.Code = &code
+		if code == "" {
+			input.Code = nil
+		}
+	}
+
+	switch input.Type {
+	case models.PromotionPercentage:
+		if input.PercentOff < 1 || input.PercentOff > 100 {
+			return fmt.Errorf("%w: percent_off must be between 1 and 100", ErrInvalidPromotion)
+		}
+	case models.PromotionFixed:
+		if input.AmountOff <= 0 {
+			return fmt.Errorf("%w: amount_off must be positive", ErrInvalidPromotion)
+		}
+	case models.PromotionBuyXGetY:
+		if input.BuyQuantity < 1 || input.GetQuantity < 1 {
+			return fmt.Errorf("%w: buy_quantity and get_quantity must be at least 1", ErrInvalidPromotion)
+		}
+	default:
+		return fmt.Errorf("%w: type must be percentage, fixed or buy_x_get_y", ErrInvalidPromotion)
+	}
+
+	if input.MinSubtotal < 0 {
+		return fmt.Errorf("%w: min_subtotal cannot be negative", ErrInvalidPromotion)
+	}
+	if (input.UsageLimit != nil && *input.UsageLimit < 1) || (input.UsageLimitPerUser != nil && *input.UsageLimitPerUser < 1) {
+		return fmt.Errorf("%w: usage limits must be at least 1", ErrInvalidPromotion)
+	}
+	if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+
+	return nil
+}
+
+// Evaluate applies the automatic promotions and the submitted coupons to cart.
+//
+// Stacking rule: all eligible stackable promotions are applied in priority
+// order, each one on what is left after the previous ones. A non-stackable
+// promotion is only used on its own, and it wins when its discount is larger
+// than the combined discount of the stackable ones.
+func (s *promotionService) Evaluate(ctx context.Context, cart *models.Cart, userID string, codes []string) error {
+	now := time.Now()
+
+	requested := map[string]bool{}
+	normalized := []string{}
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code != "" && !requested[code] {
+			requested[code] = true
+			normalized = append(normalized, code)
+		}
+	}
+
+	var candidates []models.Promotion
+	query := s.DB.WithContext(ctx).Preload("Products").Preload("Categories").
+		Where("is_active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at > ?", now)
+	if len(normalized) > 0 {
+		query = query.Where("code IS NULL OR code IN ?", normalized)
+	} else {
+		query = query.Where("code IS NULL")
+	}
+	if err := query.Order("priority DESC, id").Find(&candidates).Error; err != nil {
+		return err
+	}
+
+	found := map[string]bool{}
+	for _, promotion := range candidates {
+		if promotion.Code != nil {
+			found[*promotion.Code] = true
+		}
+	}
+	for _, code := range normalized {
+		if !found[code] {
+			cart.Rejected = append(cart.Rejected, models.RejectedCoupon{Code: code, Reason: "coupon is invalid or expired"})
+		}
+	}
+
+	var stackable, exclusive []models.Promotion
+	for _, promotion := range candidates {
+		if reason, err := s.ineligibility(ctx, promotion, cart, userID); err != nil {
+			return err
+		} else if reason != "" {
+			rejectCoupon(cart, promotion, reason)
+			continue
+		}
+
+		if promotion.Stackable {
+			stackable = append(stackable, promotion)
+		} else {
+			exclusive = append(exclusive, promotion)
+		}
+	}
+
+	bestLines, bestApplied, err := s.applyAll(cart, stackable)
+	if err != nil {
+		return err
+	}
+	bestDiscount := totalDiscount(bestApplied)
+
+	for _, promotion := range exclusive {
+		lines, applied, err := s.applyAll(cart, []models.Promotion{promotion})
+		if err != nil {
+			return err
+		}
+		if discount := totalDiscount(applied); discount > bestDiscount {
+			bestLines, bestApplied, bestDiscount = lines, applied, discount
+		}
+	}
+
+	appliedIDs := map[uint]bool{}
+	for _, applied := range bestApplied {
+		appliedIDs[applied.PromotionID] = true
+	}
+	for _, promotion := range append(stackable, exclusive...) {
+		if !appliedIDs[promotion.ID] {
+			rejectCoupon(cart, promotion, "does not apply to the cart or a better promotion was used")
+		}
+	}
+
+	cart.Lines = bestLines
+	cart.Promotions = bestApplied
+	cart.Recalculate()
+	return nil
+}
+
+// ineligibility returns why promotion cannot be used for this cart and user,
+// or an empty string when it can.
+func (s *promotionService) ineligibility(ctx context.Context, promotion models.Promotion, cart *models.Cart, userID string) (string, error) {
+	if promotion.UsageLimit != nil && promotion.UsedCount >= *promotion.UsageLimit {
+		return "usage limit reached", nil
+	}
+
+	if promotion.UsageLimitPerUser != nil {
+		if userID == "" {
+			return "sign in to use this coupon", nil
+		}
+		used, err := s.userRedemptions(s.DB.WithContext(ctx), promotion.ID, userID)
+		if err != nil {
+			return "", err
+		}
+		if used >= int64(*promotion.UsageLimitPerUser) {
+			return "usage limit per customer reached", nil
+		}
+	}
+
+	minSubtotal, err := s.CurrencyService.Convert(promotion.MinSubtotal, s.CurrencyService.BaseCurrency(), cart.Currency)
+	if err != nil {
+		return "", err
+	}
+	if cart.Subtotal < minSubtotal {
+		return "minimum subtotal not reached", nil
+	}
+
+	return "", nil
+}
+
+func (s *promotionService) userRedemptions(tx *gorm.DB, promotionID uint, userID string) (int64, error) {
+	var count int64
+	err := tx.Model(&models.PromotionRedemption{}).Where("promotion_id = ? AND user_id = ?", promotionID, userID).Count(&count).Error
+	return count, err
+}
+
+// applyAll applies promotions in order to a copy of the cart lines.
+func (s *promotionService) applyAll(cart *models.Cart, promotions []models.Promotion) ([]models.CartLine, []models.AppliedPromotion, error) {
+	lines := append([]models.CartLine{}, cart.Lines...)
+	applied := []models.AppliedPromotion{}
+
+	for _, promotion := range promotions {
+		discounts, err := s.lineDiscounts(promotion, lines, cart.Currency)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var total models.Money
+		for i, discount := range discounts {
+			lines[i].Discount += discount
+			total += discount
+		}
+		if total == 0 {
+			continue
+		}
+
+		code := ""
+		if promotion.Code != nil {
+			code = *promotion.Code
+		}
+		applied = append(applied, models.AppliedPromotion{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Code:        code,
+			Discount:    total,
+		})
+	}
+
+	return lines, applied, nil
+}
+
+// lineDiscounts computes the discount of promotion on each line, never more
+// than what is still payable on that line.
+func (s *promotionService) lineDiscounts(promotion models.Promotion, lines []models.CartLine, currency string) ([]models.Money, error) {
+	discounts := make([]models.Money, len(lines))
+
+	eligible := []int{}
+	var eligibleTotal models.Money
+	for i, line := range lines {
+		if inScope(promotion, line) && remaining(line) > 0 {
+			eligible = append(eligible, i)
+			eligibleTotal += remaining(line)
+		}
+	}
+	if len(eligible) == 0 {
+		return discounts, nil
+	}
+
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		for _, i := range eligible {
+			discounts[i] = (remaining(lines[i])*models.Money(promotion.PercentOff) + 50) / 100
+		}
+	case models.PromotionFixed:
+		amount, err := s.CurrencyService.Convert(promotion.AmountOff, s.CurrencyService.BaseCurrency(), currency)
+		if err != nil {
+			return nil, err
+		}
+		if amount >= eligibleTotal {
+			for _, i := range eligible {
+				discounts[i] = remaining(lines[i])
+			}
+			break
+		}
+
+		// Spread the amount proportionally, the last line takes the rounding
+		// rest. The products are computed on big.Int, amount times a line
+		// can exceed int64 while each share stays below amount.
+		var spread models.Money
+		for n, i := range eligible {
+			if n == len(eligible)-1 {
+				discounts[i] = amount - spread
+				break
+			}
+			share := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(remaining(lines[i]))))
+			share.Quo(share, big.NewInt(int64(eligibleTotal)))
+			discounts[i] = models.Money(share.Int64())
+			spread += discounts[i]
+		}
+	case models.PromotionBuyXGetY:
+		group := promotion.BuyQuantity + promotion.GetQuantity
+		for _, i := range eligible {
+			free := lines[i].Quantity / group * promotion.GetQuantity
+			discounts[i] = min(lines[i].UnitPrice*models.Money(free), remaining(lines[i]))
+		}
+	}
+
+	return discounts, nil
+}
+
+func inScope(promotion models.Promotion, line models.CartLine) bool {
+	if len(promotion.Products) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	for _, product := range promotion.Products {
+		if product.ID == line.ProductID {
+			return true
+		}
+	}
+	for _, category := range promotion.Categories {
+		if category.ID == line.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+func remaining(line models.CartLine) models.Money {
+	return line.UnitPrice*models.Money(line.Quantity) - line.Discount
+}
+
+func totalDiscount(applied []models.AppliedPromotion) models.Money {
+	var total models.Money
+	for _, promotion := range applied {
+		total += promotion.Discount
+	}
+	return total
+}
+
+func rejectCoupon(cart *models.Cart, promotion models.Promotion, reason string) {
+	if promotion.Code == nil {
+		return
+	}
+	for _, rejected := range cart.Rejected {
+		if rejected.Code == *promotion.Code {
+			return
+		}
+	}
+	cart.Rejected = append(cart.Rejected, models.RejectedCoupon{Code: *promotion.Code, Reason: reason})
+}
+
+// RedeemTx records the promotions applied to an evaluated cart as used by
+// the order being placed in tx, re-checking the usage limits under a row
+// lock so concurrent checkouts cannot exceed them. The redemptions are rolled
+// back together with an order that fails to save.
+func (s *promotionService) RedeemTx(tx *gorm.DB, cart models.Cart, userID string, orderID uint) error {
+	applied := append([]models.AppliedPromotion{}, cart.Promotions...)
+	sort.Slice(applied, func(i, j int) bool { return applied[i].PromotionID < applied[j].PromotionID })
+
+	for _, entry := range applied {
+		var promotion models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, entry.PromotionID).Error; err != nil {
+			return err
+		}
+
+		if promotion.UsageLimit != nil && promotion.UsedCount >= *promotion.UsageLimit {
+			return ErrUsageLimitReached
+		}
+		if promotion.UsageLimitPerUser != nil {
+			used, err := s.userRedemptions(tx, promotion.ID, userID)
+			if err != nil {
+				return err
+			}
+			if used >= int64(*promotion.UsageLimitPerUser) {
+				return ErrUsageLimitReached
+			}
+		}
+
+		if err := tx.Model(&promotion).UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+			return err
+		}
+
+		redemption := models.PromotionRedemption{
+			PromotionID: promotion.ID,
+			OrderID:     orderID,
+			UserID:      userID,
+			Discount:    entry.Discount,
+			Currency:    cart.Currency,
+		}
+		if err := tx.Create(&redemption).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseTx gives back the promotion uses of an order that was cancelled or
+// refunded, so they count against the usage limits no more.
+func (s *promotionService) ReleaseTx(tx *gorm.DB, orderID uint) error {
+	var redemptions []models.PromotionRedemption
+	if err := tx.Where("order_id = ?", orderID).Order("promotion_id").Find(&redemptions).Error; err != nil {
+		return err
+	}
+
+	for _, redemption := range redemptions {
+		err := tx.Model(&models.Promotion{}).Unscoped().
+			Where("id = ? AND used_count > 0", redemption.PromotionID).
+			UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(redemptions) == 0 {
+		return nil
+	}
+	return tx.Unscoped().Where("order_id = ?", orderID).Delete(&models.PromotionRedemption{}).Error
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func newCurrencyService(t *testing.T) currencyService.CurrencyService {
+	t.Helper()
+	currencies, err := currencyService.NewCurrencyService("USD", "EUR=0.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return currencies
+}
+
+func TestLineDiscounts(t *testing.T) {
+	lines := func(prices ...models.Money) []models.CartLine {
+		var lines []models.CartLine
+		for i, price := range prices {
+			lines = append(lines, models.CartLine{ProductID: uint(i + 1), Quantity: 1, UnitPrice: price})
+		}
+		return lines
+	}
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		lines     []models.CartLine
+		currency  string
+		want      []models.Money
+	}{
+		{
+			name:      "percentage rounds half up",
+			promotion: models.Promotion{Type: models.PromotionPercentage, PercentOff: 10},
+			lines:     lines(1000, 555),
+			want:      []models.Money{100, 56},
+		},
+		{
+			name:      "percentage of what is left",
+			promotion: models.Promotion{Type: models.PromotionPercentage, PercentOff: 50},
+			lines:     []models.CartLine{{Quantity: 2, UnitPrice: 1000, Discount: 1000}},
+			want:      []models.Money{500},
+		},
+		{
+			name:      "fixed spread proportionally",
+			promotion: models.Promotion{Type: models.PromotionFixed, AmountOff: 1000},
+			lines:     lines(3000, 1000),
+			want:      []models.Money{750, 250},
+		},
+		{
+			name:      "fixed rounding rest on the last line",
+			promotion: models.Promotion{Type: models.PromotionFixed, AmountOff: 1000},
+			lines:     lines(1000, 1000, 1000),
+			want:      []models.Money{333, 333, 334},
+		},
+		{
+			name:      "fixed above the total",
+			promotion: models.Promotion{Type: models.PromotionFixed, AmountOff: 5000},
+			lines:     lines(1000, 2000),
+			want:      []models.Money{1000, 2000},
+		},
+		{
+			name:      "fixed converted to the cart currency",
+			promotion: models.Promotion{Type: models.PromotionFixed, AmountOff: 1000},
+			lines:     lines(1800, 1800),
+			currency:  "EUR",
+			want:      []models.Money{450, 450},
+		},
+		{
+			name:      "fixed on amounts overflowing int64",
+			promotion: models.Promotion{Type: models.PromotionFixed, AmountOff: math.MaxInt64 / 3},
+			lines:     lines(math.MaxInt64/4, math.MaxInt64/4),
+			want:      []models.Money{math.MaxInt64 / 6, math.MaxInt64/3 - math.MaxInt64/6},
+		},
+		{
+			name:      "buy two get one",
+			promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+			lines:     []models.CartLine{{Quantity: 7, UnitPrice: 100}, {Quantity: 2, UnitPrice: 100}},
+			want:      []models.Money{200, 0},
+		},
+		{
+			name: "scoped to a product and a category",
+			promotion: models.Promotion{
+				Type:       models.PromotionPercentage,
+				PercentOff: 10,
+				Products:   []models.Product{{Model: models.Model{ID: 1}}},
+				Categories: []models.Category{{Model: models.Model{ID: 7}}},
+			},
+			lines: []models.CartLine{
+				{ProductID: 1, Quantity: 1, UnitPrice: 1000},
+				{ProductID: 2, Quantity: 1, UnitPrice: 1000},
+				{ProductID: 3, CategoryID: 7, Quantity: 1, UnitPrice: 1000},
+			},
+			want: []models.Money{100, 0, 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &promotionService{CurrencyService: newCurrencyService(t)}
+			currency := tt.currency
+			if currency == "" {
+				currency = "USD"
+			}
+
+			got, err := s.lineDiscounts(tt.promotion, tt.lines, currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lineDiscounts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateStacking(t *testing.T) {
+	tests := []struct {
+		name         string
+		coupons      []string
+		wantApplied  []string
+		wantDiscount models.Money
+		wantRejected []string
+	}{
+		{
+			name:         "stackable promotions in priority order",
+			wantApplied:  []string{"Ten percent", "Five off"},
+			wantDiscount: 1500,
+		},
+		{
+			name:         "larger exclusive coupon wins",
+			coupons:      []string{"half"},
+			wantApplied:  []string{"Half price"},
+			wantDiscount: 5000,
+		},
+		{
+			name:         "smaller exclusive coupon loses",
+			coupons:      []string{"SMALL"},
+			wantApplied:  []string{"Ten percent", "Five off"},
+			wantDiscount: 1500,
+			wantRejected: []string{"SMALL"},
+		},
+		{
+			name:         "stackable coupon joins the automatic ones",
+			coupons:      []string{"EXTRA"},
+			wantApplied:  []string{"Ten percent", "Extra", "Five off"},
+			wantDiscount: 2400,
+		},
+		{
+			name:         "unknown coupon",
+			coupons:      []string{"NOPE"},
+			wantApplied:  []string{"Ten percent", "Five off"},
+			wantDiscount: 1500,
+			wantRejected: []string{"NOPE"},
+		},
+	}
+
+	db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Promotion{}, &models.PromotionRedemption{})
+	code := func(code string) *string { return &code }
+	dbtest.Create(t, db,
+		&models.Promotion{Name: "Ten percent", Type: models.PromotionPercentage, PercentOff: 10, Stackable: true, Priority: 3, IsActive: true},
+		&models.Promotion{Name: "Five off", Type: models.PromotionFixed, AmountOff: 500, Stackable: true, Priority: 1, IsActive: true},
+		&models.Promotion{Name: "Extra", Code: code("EXTRA"), Type: models.PromotionPercentage, PercentOff: 10, Stackable: true, Priority: 2, IsActive: true},
+		&models.Promotion{Name: "Half price", Code: code("HALF"), Type: models.PromotionPercentage, PercentOff: 50, IsActive: true},
+		&models.Promotion{Name: "Small", Code: code("SMALL"), Type: models.PromotionFixed, AmountOff: 1000, IsActive: true},
+		&models.Promotion{Name: "Inactive", Type: models.PromotionPercentage, PercentOff: 90, Stackable: true},
+	)
+	s := NewPromotionService(db, newCurrencyService(t))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := models.Cart{Currency: "USD", Lines: []models.CartLine{{ProductID: 1, Quantity: 1, UnitPrice: 10000}}}
+			cart.Recalculate()
+
+			if err := s.Evaluate(context.Background(), &cart, "user", tt.coupons); err != nil {
+				t.Fatal(err)
+			}
+
+			var applied, rejected []string
+			for _, promotion := range cart.Promotions {
+				applied = append(applied, promotion.Name)
+			}
+			for _, coupon := range cart.Rejected {
+				rejected = append(rejected, coupon.Code)
+			}
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("applied %v, want %v", applied, tt.wantApplied)
+			}
+			if cart.Discount != tt.wantDiscount || cart.Total != cart.Subtotal-tt.wantDiscount {
+				t.Errorf("discount %v, total %v, want a discount of %v", cart.Discount, cart.Total, tt.wantDiscount)
+			}
+			if !reflect.DeepEqual(rejected, tt.wantRejected) {
+				t.Errorf("rejected %v, want %v", rejected, tt.wantRejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	productService "go-api/services/product"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidReview   = errors.New("invalid review")
+	ErrNotPurchased    = errors.New("only customers who bought the product can review it")
+	ErrAlreadyReviewed = errors.New("product already reviewed")
+)
+
+type ReviewService interface {
+	GetProductReviews(ctx context.Context, productID uint, page, limit int) (models.ReviewPage, error)
+	CreateReview(ctx context.Context, productID uint, userID string, input models.ReviewInput) (models.Review, error)
+	GetReviewsByStatus(ctx context.Context, status string) ([]models.Review, error)
+	SetStatus(ctx context.Context, id uint, status string) (models.Review, error)
+}
+
+type reviewService struct {
+	DB           *gorm.DB
+	Invalidators []productService.ProductInvalidator
+}
+
+// NewReviewService creates the review service. The invalidators are told
+// about the products whose rating changed.
+func NewReviewService(db *gorm.DB, invalidators ...productService.ProductInvalidator) ReviewService {
+	return &reviewService{DB: db, Invalidators: invalidators}
+}
+
+func (s *reviewService) GetProductReviews(ctx context.Context, productID uint, page, limit int) (models.ReviewPage, error) {
+	result := models.ReviewPage{Data: []models.Review{}, Page: page, Limit: limit}
+
+	query := s.DB.WithContext(ctx).Model(&models.Review{}).Where("product_id = ? AND status = ?", productID, models.ReviewApproved)
+	if err := query.Count(&result.Total).Error; err != nil {
+		return models.ReviewPage{}, err
+	}
+	err := query.Order("created_at DESC").Order("id DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&result.Data).Error
+	if err != nil {
+		return models.ReviewPage{}, err
+	}
+	return result, nil
+}
+
+// CreateReview stores a pending review. The user must have a paid, shipped or
+// delivered order containing the product.
+func (s *reviewService) CreateReview(ctx context.Context, productID uint, userID string, input models.ReviewInput) (models.Review, error) {
+	if input.Rating < 1 || input.Rating > 5 {
+		return models.Review{}, fmt.Errorf("%w: rating must be between 1 and 5", ErrInvalidReview)
+	}
+	if userID == "" {
+		return models.Review{}, fmt.Errorf("%w: missing user", ErrInvalidReview)
+	}
+
+	if err := s.DB.WithContext(ctx).First(&models.Product{}, productID).Error; err != nil {
+		return models.Review{}, err
+	}
+
+	var purchases int64
+	err := s.DB.WithContext(ctx).Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id AND orders.deleted_at IS NULL").
+		Where("order_items.product_id = ? AND orders.user_id = ?", productID, userID).
+		Where("orders.status IN ?", []string{models.OrderPaid, models.OrderShipped, models.OrderDelivered}).
+		Count(&purchases).Error
+	if err != nil {
+		return models.Review{}, err
+	}
+	if purchases == 0 {
+		return models.Review{}, ErrNotPurchased
+	}
+
+	var existing int64
+	if err := s.DB.WithContext(ctx).Model(&models.Review{}).Where("product_id = ? AND user_id = ?", productID, userID).Count(&existing).Error; err != nil {
+		return models.Review{}, err
+	}
+	if existing > 0 {
+		return models.Review{}, ErrAlreadyReviewed
+	}
+
+	review := models.Review{
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    input.Rating,
+		Title:     strings.TrimSpace(input.Title),
+		Body:      strings.TrimSpace(input.Body),
+		Status:    models.ReviewPending,
+	}
+	if err := s.DB.WithContext(ctx).Create(&review).Error; err != nil {
+		// A concurrent request can save the review after the check above.
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return models.Review{}, ErrAlreadyReviewed
+		}
+		return models.Review{}, err
+	}
+	return review, nil
+}
+
+func (s *reviewService) GetReviewsByStatus(ctx context.Context, status string) ([]models.Review, error) {
+	reviews := []models.Review{}
+	query := s.DB.WithContext(ctx).Order("created_at")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&reviews).Error
+	return reviews, err
+}
+
+// SetStatus approves or hides a review and refreshes the rating of its
+// product.
+func (s *reviewService) SetStatus(ctx context.Context, id uint, status string) (models.Review, error) {
+	if status != models.ReviewApproved && status != models.ReviewHidden {
+		return models.Review{}, fmt.Errorf("%w: status must be approved or hidden", ErrInvalidReview)
+	}
+
+	var review models.Review
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&review, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&review).Update("status", status).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductID)
+	})
+	if err != nil {
+		return models.Review{}, err
+	}
+	for _, invalidator := range s.Invalidators {
+		invalidator.InvalidateProducts(review.ProductID)
+	}
+	return review, nil
+}
+
+// refreshRating stores the average and count of the approved reviews on the
+// product so listings can show and sort by them without a join.
+func refreshRating(tx *gorm.DB, productID uint) error {
+	var stats struct {
+		Average float64
+		Count   int
+	}
+	err := tx.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, models.ReviewApproved).
+		Scan(&stats).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+		"rating_average": math.Round(stats.Average*100) / 100,
+		"rating_count":   stats.Count,
+	}).Error
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	"testing"
+)
+
+func TestCreateReviewOncePerUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *models.Review
+		trashed  bool
+		wantErr  error
+	}{
+		{name: "first review"},
+		{name: "already reviewed", existing: &models.Review{Rating: 4}, wantErr: ErrAlreadyReviewed},
+		// The check skips trashed reviews, the unique index does not.
+		{name: "trashed review", existing: &models.Review{Rating: 4}, trashed: true, wantErr: ErrAlreadyReviewed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Order{}, &models.OrderItem{}, &models.Review{})
+			s := NewReviewService(db)
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp"})
+			dbtest.Create(t, db, &models.Order{
+				UserID: "user",
+				Status: models.OrderDelivered,
+				Items:  []models.OrderItem{{ProductID: product.ID, Quantity: 1}},
+			})
+			if tt.existing != nil {
+				tt.existing.ProductID, tt.existing.UserID = product.ID, "user"
+				dbtest.Create(t, db, tt.existing)
+				if tt.trashed {
+					if err := db.Delete(tt.existing).Error; err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			_, err := s.CreateReview(context.Background(), product.ID, "user", models.ReviewInput{Rating: 5})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateReview error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidShipping     = errors.New("invalid shipping configuration")
+	ErrNoShippingZone      = errors.New("no shipping zone covers the destination")
+	ErrMethodNotApplicable = errors.New("shipping method is not available for this cart")
+)
+
+type ShippingService interface {
+	GetZones(ctx context.Context) ([]models.ShippingZone, error)
+	CreateZone(ctx context.Context, input models.ShippingZoneInput) (models.ShippingZone, error)
+	UpdateZone(ctx context.Context, id uint, input models.ShippingZoneInput) (models.ShippingZone, error)
+	DeleteZone(ctx context.Context, id uint) error
+	CreateMethod(ctx context.Context, zoneID uint, input models.ShippingMethodInput) (models.ShippingMethod, error)
+	UpdateMethod(ctx context.Context, id uint, input models.ShippingMethodInput) (models.ShippingMethod, error)
+	DeleteMethod(ctx context.Context, id uint) error
+	Quote(ctx context.Context, cart models.Cart, address models.Address) ([]models.ShippingQuote, error)
+	Rate(ctx context.Context, methodID uint, cart models.Cart, address models.Address) (models.ShippingQuote, error)
+}
+
+type shippingService struct {
+	DB              *gorm.DB
+	CurrencyService currencyService.CurrencyService
+}
+
+func NewShippingService(db *gorm.DB, currencyService currencyService.CurrencyService) ShippingService {
+	return &shippingService{DB: db, CurrencyService: currencyService}
+}
+
+func (s *shippingService) GetZones(ctx context.Context) ([]models.ShippingZone, error) {
+	zones := []models.ShippingZone{}
+	err := s.DB.WithContext(ctx).Preload("Methods").Order("id").Find(&zones).Error
+	return zones, err
+}
+
+func (s *shippingService) CreateZone(ctx context.Context, input models.ShippingZoneInput) (models.ShippingZone, error) {
+	var zone models.ShippingZone
+	if err := applyZoneInput(&zone, input); err != nil {
+		return models.ShippingZone{}, err
+	}
+	if err := s.DB.WithContext(ctx).Create(&zone).Error; err != nil {
+		return models.ShippingZone{}, err
+	}
+	return zone, nil
+}
+
+func (s *shippingService) UpdateZone(ctx context.Context, id uint, input models.ShippingZoneInput) (models.ShippingZone, error) {
+	var zone models.ShippingZone
+	if err := s.DB.WithContext(ctx).First(&zone, id).Error; err != nil {
+		return models.ShippingZone{}, err
+	}
+	if err := applyZoneInput(&zone, input); err != nil {
+		return models.ShippingZone{}, err
+	}
+	if err := s.DB.WithContext(ctx).Save(&zone).Error; err != nil {
+		return models.ShippingZone{}, err
+	}
+	return zone, nil
+}
+
+func (s *shippingService) DeleteZone(ctx context.Context, id uint) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.ShippingZone{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("zone_id = ?", id).Delete(&models.ShippingMethod{}).Error
+	})
+}
+
+func (s *shippingService) CreateMethod(ctx context.Context, zoneID uint, input models.ShippingMethodInput) (models.ShippingMethod, error) {
+	if err := s.DB.WithContext(ctx).First(&models.ShippingZone{}, zoneID).Error; err != nil {
+		return models.ShippingMethod{}, err
+	}
+
+	method := models.ShippingMethod{ZoneID: zoneID}
+	if err := applyMethodInput(&method, input); err != nil {
+		return models.ShippingMethod{}, err
+	}
+	if err := s.DB.WithContext(ctx).Create(&method).Error; err != nil {
+		return models.ShippingMethod{}, err
+	}
+	return method, nil
+}
+
+func (s *shippingService) UpdateMethod(ctx context.Context, id uint, input models.ShippingMethodInput) (models.ShippingMethod, error) {
+	var method models.ShippingMethod
+	if err := s.DB.WithContext(ctx).First(&method, id).Error; err != nil {
+		return models.ShippingMethod{}, err
+	}
+	if err := applyMethodInput(&method, input); err != nil {
+		return models.ShippingMethod{}, err
+	}
+	if err := s.DB.WithContext(ctx).Save(&method).Error; err != nil {
+		return models.ShippingMethod{}, err
+	}
+	return method, nil
+}
+
+func (s *shippingService) DeleteMethod(ctx context.Context, id uint) error {
+	result := s.DB.WithContext(ctx).Delete(&models.ShippingMethod{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Quote returns the rate of every active method of the zone covering the
+// destination that can carry the cart, cheapest first.
+func (s *shippingService) Quote(ctx context.Context, cart models.Cart, address models.Address) ([]models.ShippingQuote, error) {
+	zone, err := s.zoneFor(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := []models.ShippingQuote{}
+	for _, method := range zone.Methods {
+		quote, err := s.rate(method, cart)
+		if errors.Is(err, ErrMethodNotApplicable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, quote)
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Rate < quotes[j].Rate })
+	return quotes, nil
+}
+
+func (s *shippingService) Rate(ctx context.Context, methodID uint, cart models.Cart, address models.Address) (models.ShippingQuote, error) {
+	zone, err := s.zoneFor(ctx, address)
+	if err != nil {
+		return models.ShippingQuote{}, err
+	}
+
+	for _, method := range zone.Methods {
+		if method.ID == methodID {
+			return s.rate(method, cart)
+		}
+	}
+	return models.ShippingQuote{}, ErrMethodNotApplicable
+}
+
+// zoneFor picks the zone listing the destination country, falling back to a
+// "*" zone.
+func (s *shippingService) zoneFor(ctx context.Context, address models.Address) (models.ShippingZone, error) {
+	country := strings.ToUpper(strings.TrimSpace(address.Country))
+	if len(country) != 2 {
+		return models.ShippingZone{}, fmt.Errorf("%w: country must be a two letter code", ErrInvalidShipping)
+	}
+
+	var zones []models.ShippingZone
+	if err := s.DB.WithContext(ctx).Preload("Methods", "is_active = ?", true).Order("id").Find(&zones).Error; err != nil {
+		return models.ShippingZone{}, err
+	}
+
+	var fallback *models.ShippingZone
+	for i, zone := range zones {
+		for _, code := range strings.Split(zone.Countries, ",") {
+			code = strings.TrimSpace(code)
+			if code == country {
+				return zone, nil
+			}
+			if code == "*" && fallback == nil {
+				fallback = &zones[i]
+			}
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return models.ShippingZone{}, ErrNoShippingZone
+}
+
+func (s *shippingService) rate(method models.ShippingMethod, cart models.Cart) (models.ShippingQuote, error) {
+	weight := cart.WeightGrams()
+	if method.MaxWeightGrams > 0 && weight > method.MaxWeightGrams {
+		return models.ShippingQuote{}, ErrMethodNotApplicable
+	}
+
+	rate := method.BaseRate
+	switch method.Type {
+	case models.ShippingWeight:
+		kilograms := (weight + 999) / 1000
+		rate += method.PerKgRate * models.Money(kilograms)
+	case models.ShippingFreeOver:
+		threshold, err := s.CurrencyService.Convert(method.FreeThreshold, s.CurrencyService.BaseCurrency(), cart.Currency)
+		if err != nil {
+			return models.ShippingQuote{}, err
+		}
+		if cart.Total >= threshold {
+			rate = 0
+		}
+	}
+
+	rate, err := s.CurrencyService.Convert(rate, s.CurrencyService.BaseCurrency(), cart.Currency)
+	if err != nil {
+		return models.ShippingQuote{}, err
+	}
+
+	return models.ShippingQuote{
+		MethodID:    method.ID,
+		Name:        method.Name,
+		Carrier:     method.Carrier,
+		WeightGrams: weight,
+		Rate:        rate,
+		Currency:    cart.Currency,
+	}, nil
+}
+
+func applyZoneInput(zone *models.ShippingZone, input models.ShippingZoneInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidShipping)
+	}
+
+	codes := []string{}
+	for _, code := range strings.Split(input.Countries, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if code != "*" && len(code) != 2 {
+			return fmt.Errorf("%w: countries must be two letter codes or *", ErrInvalidShipping)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return fmt.Errorf("%w: at least one country is required", ErrInvalidShipping)
+	}
+
+	zone.Name = name
+	zone.Countries = strings.Join(codes, ",")
+	return nil
+}
+
+func applyMethodInput(method *models.ShippingMethod, input models.ShippingMethodInput) error {
+	if strings.TrimSpace(input.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidShipping)
+	}
+	switch input.Type {
+	case models.ShippingFlat, models.ShippingWeight, models.ShippingFreeOver:
+	default:
+		return fmt.Errorf("%w: type must be flat, weight or free_over", ErrInvalidShipping)
+	}
+	if input.BaseRate < 0 || input.PerKgRate < 0 || input.FreeThreshold < 0 || input.MaxWeightGrams < 0 {
+		return fmt.Errorf("%w: rates, threshold and max weight cannot be negative", ErrInvalidShipping)
+	}
+
+	method.Name = strings.TrimSpace(input.Name)
+	method.Carrier = strings.TrimSpace(input.Carrier)
+	method.Type = input.Type
+	method.BaseRate = input.BaseRate
+	method.PerKgRate = input.PerKgRate
+	method.FreeThreshold = input.FreeThreshold
+	method.MaxWeightGrams = input.MaxWeightGrams
+	method.IsActive = input.IsActive
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+type SubscriptionService interface {
+	GetSubscriptions(ctx context.Context, userID string) ([]models.ProductSubscription, error)
+	Subscribe(ctx context.Context, productID uint, userID string, input models.ProductSubscriptionInput) (models.ProductSubscription, error)
+	Unsubscribe(ctx context.Context, id uint, userID string) error
+}
+
+type subscriptionService struct {
+	DB              *gorm.DB
+	CurrencyService currencyService.CurrencyService
+}
+
+func NewSubscriptionService(db *gorm.DB, currencies currencyService.CurrencyService) SubscriptionService {
+	return &subscriptionService{DB: db, CurrencyService: currencies}
+}
+
+func (s *subscriptionService) GetSubscriptions(ctx context.Context, userID string) ([]models.ProductSubscription, error) {
+	subscriptions := []models.ProductSubscription{}
+	err := s.DB.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// Subscribe creates a subscription, or re-arms and updates the existing one
+// of the same type for the product.
+func (s *subscriptionService) Subscribe(ctx context.Context, productID uint, userID string, input models.ProductSubscriptionInput) (models.ProductSubscription, error) {
+	input.TargetCurrency = strings.ToUpper(strings.TrimSpace(input.TargetCurrency))
+	switch input.Type {
+	case models.SubscriptionBackInStock:
+		input.TargetPrice = nil
+		input.TargetCurrency = ""
+	case models.SubscriptionPriceDrop:
+		if input.TargetPrice == nil || *input.TargetPrice <= 0 {
+			return models.ProductSubscription{}, fmt.Errorf("%w: price_drop needs a positive target_price", ErrInvalidSubscription)
+		}
+	default:
+		return models.ProductSubscription{}, fmt.Errorf("%w: type must be back_in_stock or price_drop", ErrInvalidSubscription)
+	}
+	if userID == "" {
+		return models.ProductSubscription{}, fmt.Errorf("%w: missing user", ErrInvalidSubscription)
+	}
+
+	var product models.Product
+	if err := s.DB.WithContext(ctx).First(&product, productID).Error; err != nil {
+		return models.ProductSubscription{}, err
+	}
+	if input.Type == models.SubscriptionPriceDrop {
+		if input.TargetCurrency == "" {
+			input.TargetCurrency = product.Currency
+		}
+		if !s.CurrencyService.Supports(input.TargetCurrency) {
+			return models.ProductSubscription{}, fmt.Errorf("%w: unsupported target_currency %s", ErrInvalidSubscription, input.TargetCurrency)
+		}
+	}
+
+	var subscription models.ProductSubscription
+	err := s.DB.WithContext(ctx).Where("user_id = ? AND product_id = ? AND type = ?", userID, productID, input.Type).First(&subscription).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ProductSubscription{}, err
+	}
+
+	subscription.UserID = userID
+	subscription.ProductID = productID
+	subscription.Type = input.Type
+	subscription.TargetPrice = input.TargetPrice
+	subscription.TargetCurrency = input.TargetCurrency
+	subscription.NotifiedAt = nil
+	if err := s.DB.WithContext(ctx).Save(&subscription).Error; err != nil {
+		return models.ProductSubscription{}, err
+	}
+	return subscription, nil
+}
+
+func (s *subscriptionService) Unsubscribe(ctx context.Context, id uint, userID string) error {
+	result := s.DB.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.ProductSubscription{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	currencyService "go-api/services/currency"
+	"testing"
+)
+
+func TestSubscribeTargetCurrency(t *testing.T) {
+	price := models.Money(800)
+	tests := []struct {
+		name         string
+		input        models.ProductSubscriptionInput
+		wantErr      error
+		wantCurrency string
+	}{
+		{name: "product currency by default", input: models.ProductSubscriptionInput{Type: models.SubscriptionPriceDrop, TargetPrice: &price}, wantCurrency: "EUR"},
+		{name: "given currency", input: models.ProductSubscriptionInput{Type: models.SubscriptionPriceDrop, TargetPrice: &price, TargetCurrency: " usd "}, wantCurrency: "USD"},
+		{name: "unsupported currency", input: models.ProductSubscriptionInput{Type: models.SubscriptionPriceDrop, TargetPrice: &price, TargetCurrency: "GBP"}, wantErr: ErrInvalidSubscription},
+		{name: "back in stock", input: models.ProductSubscriptionInput{Type: models.SubscriptionBackInStock, TargetCurrency: "USD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t, &models.Category{}, &models.Product{}, &models.ProductSubscription{})
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", Price: 1000, Currency: "EUR"})
+			currencies, err := currencyService.NewCurrencyService("USD", "EUR=0.9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewSubscriptionService(db, currencies)
+
+			subscription, err := s.Subscribe(context.Background(), product.ID, "ann", tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Subscribe error = %v, want %v", err, tt.wantErr)
+			}
+			if subscription.TargetCurrency != tt.wantCurrency {
+				t.Errorf("target currency = %q, want %q", subscription.TargetCurrency, tt.wantCurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TaxProvider computes the tax of a priced cart shipped to an address. The
+// rule based provider is the default, other implementations can wrap an
+// external tax API.
+type TaxProvider interface {
+	Name() string
+	Calculate(ctx context.Context, cart models.Cart, address models.Address) (models.TaxBreakdown, error)
+}
+
+// NewTaxProvider selects the provider configured with TAX_PROVIDER.
+func NewTaxProvider(name string, db *gorm.DB, stubRateBps int) (TaxProvider, error) {
+	switch name {
+	case "", "rules":
+		return &ruleTaxProvider{DB: db}, nil
+	case "stub":
+		return &stubTaxProvider{RateBps: stubRateBps}, nil
+	}
+	return nil, fmt.Errorf("unknown tax provider %q", name)
+}
+
+type ruleTaxProvider struct {
+	DB *gorm.DB
+}
+
+func (p *ruleTaxProvider) Name() string {
+	return "rules"
+}
+
+func (p *ruleTaxProvider) Calculate(ctx context.Context, cart models.Cart, address models.Address) (models.TaxBreakdown, error) {
+	country := strings.ToUpper(address.Country)
+
+	var rules []models.TaxRule
+	if err := p.DB.WithContext(ctx).Where("country = ?", country).Find(&rules).Error; err != nil {
+		return models.TaxBreakdown{}, err
+	}
+
+	breakdown := newBreakdown(p.Name(), cart.Currency)
+	for _, line := range cart.Lines {
+		rule := matchRule(rules, address.Region, line.TaxClass)
+		breakdown.add(taxLine(line, rule))
+	}
+	return breakdown.TaxBreakdown, nil
+}
+
+// matchRule prefers a rule for the exact region over a country wide one. A
+// line without a matching rule is untaxed.
+func matchRule(rules []models.TaxRule, region, taxClass string) models.TaxRule {
+	if taxClass == "" {
+		taxClass = models.TaxClassStandard
+	}
+
+	var countryWide *models.TaxRule
+	for i, rule := range rules {
+		if rule.TaxClass != taxClass {
+			continue
+		}
+		if rule.Region != "" && strings.EqualFold(rule.Region, region) {
+			return rule
+		}
+		if rule.Region == "" && countryWide == nil {
+			countryWide = &rules[i]
+		}
+	}
+	if countryWide != nil {
+		return *countryWide
+	}
+	return models.TaxRule{TaxClass: taxClass}
+}
+
+// stubTaxProvider applies one flat exclusive rate to everything. It stands in
+// for an external provider in local and test setups.
+type stubTaxProvider struct {
+	RateBps int
+}
+
+func (p *stubTaxProvider) Name() string {
+	return "stub"
+}
+
+func (p *stubTaxProvider) Calculate(ctx context.Context, cart models.Cart, address models.Address) (models.TaxBreakdown, error) {
+	breakdown := newBreakdown(p.Name(), cart.Currency)
+	for _, line := range cart.Lines {
+		breakdown.add(taxLine(line, models.TaxRule{
+			Name:     "Stub tax",
+			TaxClass: line.TaxClass,
+			RateBps:  p.RateBps,
+		}))
+	}
+	return breakdown.TaxBreakdown, nil
+}
+
+type breakdownBuilder struct {
+	models.TaxBreakdown
+}
+
+func newBreakdown(provider, currency string) *breakdownBuilder {
+	return &breakdownBuilder{models.TaxBreakdown{
+		Provider: provider,
+		Currency: currency,
+		Lines:    []models.TaxLine{},
+	}}
+}
+
+func (b *breakdownBuilder) add(line models.TaxLine) {
+	b.Lines = append(b.Lines, line)
+	b.Net += line.Net
+	b.Tax += line.Tax
+	b.Gross += line.Gross
+}
+
+// taxLine taxes what is payable on the cart line after discounts.
+func taxLine(line models.CartLine, rule models.TaxRule) models.TaxLine {
+	amount := line.Total
+	result := models.TaxLine{
+		ProductID: line.ProductID,
+		TaxClass:  rule.TaxClass,
+		Name:      rule.Name,
+		RateBps:   rule.RateBps,
+		Inclusive: rule.Inclusive,
+	}
+
+	rate := models.Money(rule.RateBps)
+	if rule.Inclusive {
+		result.Tax = roundDiv(amount*rate, 10000+rate)
+		result.Net = amount - result.Tax
+		result.Gross = amount
+	} else {
+		result.Tax = roundDiv(amount*rate, 10000)
+		result.Net = amount
+		result.Gross = amount + result.Tax
+	}
+	return result
+}
+
+func roundDiv(a, b models.Money) models.Money {
+	return (a + b/2) / b
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidTaxRule = errors.New("invalid tax rule")
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+type TaxService interface {
+	GetRules(ctx context.Context) ([]models.TaxRule, error)
+	CreateRule(ctx context.Context, input models.TaxRuleInput) (models.TaxRule, error)
+	UpdateRule(ctx context.Context, id uint, input models.TaxRuleInput) (models.TaxRule, error)
+	DeleteRule(ctx context.Context, id uint) error
+	Calculate(ctx context.Context, cart models.Cart, address models.Address) (models.TaxBreakdown, error)
+}
+
+type taxService struct {
+	DB       *gorm.DB
+	Provider TaxProvider
+}
+
+func NewTaxService(db *gorm.DB, provider TaxProvider) TaxService {
+	return &taxService{DB: db, Provider: provider}
+}
+
+func (s *taxService) GetRules(ctx context.Context) ([]models.TaxRule, error) {
+	rules := []models.TaxRule{}
+	err := s.DB.WithContext(ctx).Order("country, region, tax_class").Find(&rules).Error
+	return rules, err
+}
+
+func (s *taxService) CreateRule(ctx context.Context, input models.TaxRuleInput) (models.TaxRule, error) {
+	var rule models.TaxRule
+	if err := applyTaxRuleInput(&rule, input); err != nil {
+		return models.TaxRule{}, err
+	}
+	if err := s.DB.WithContext(ctx).Create(&rule).Error; err != nil {
+		return models.TaxRule{}, err
+	}
+	return rule, nil
+}
+
+func (s *taxService) UpdateRule(ctx context.Context, id uint, input models.TaxRuleInput) (models.TaxRule, error) {
+	var rule models.TaxRule
+	if err := s.DB.WithContext(ctx).First(&rule, id).Error; err != nil {
+		return models.TaxRule{}, err
+	}
+	if err := applyTaxRuleInput(&rule, input); err != nil {
+		return models.TaxRule{}, err
+	}
+	if err := s.DB.WithContext(ctx).Save(&rule).Error; err != nil {
+		return models.TaxRule{}, err
+	}
+	return rule, nil
+}
+
+func (s *taxService) DeleteRule(ctx context.Context, id uint) error {
+	result := s.DB.WithContext(ctx).Delete(&models.TaxRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *taxService) Calculate(ctx context.Context, cart models.Cart, address models.Address) (models.TaxBreakdown, error) {
+	if len(strings.TrimSpace(address.Country)) != 2 {
+		return models.TaxBreakdown{}, fmt.Errorf("%w: country must be a two letter code", ErrInvalidAddress)
+	}
+	return s.Provider.Calculate(ctx, cart, address)
+}
+
+func applyTaxRuleInput(rule *models.TaxRule, input models.TaxRuleInput) error {
+	country := strings.ToUpper(strings.TrimSpace(input.Country))
+	if len(country) != 2 {
+		return fmt.Errorf("%w: country must be a two letter code", ErrInvalidTaxRule)
+	}
+	if input.RateBps < 0 || input.RateBps > 10000 {
+		return fmt.Errorf("%w: rate_bps must be between 0 and 10000", ErrInvalidTaxRule)
+	}
+
+	taxClass := strings.TrimSpace(input.TaxClass)
+	if taxClass == "" {
+		taxClass = models.TaxClassStandard
+	}
+
+	rule.Country = country
+	rule.Region = strings.TrimSpace(input.Region)
+	rule.TaxClass = taxClass
+	rule.Name = strings.TrimSpace(input.Name)
+	rule.RateBps = input.RateBps
+	rule.Inclusive = input.Inclusive
+	return nil
+}
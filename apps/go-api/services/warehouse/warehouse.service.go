@@ -0,0 +1,523 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-api/models"
+	cartService "go-api/services/cart"
+	productService "go-api/services/product"
+	webhookService "go-api/services/webhook"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidWarehouse  = errors.New("invalid warehouse")
+	ErrWarehouseNotEmpty = errors.New("warehouse still holds stock")
+	ErrInvalidTransfer   = errors.New("invalid stock transfer")
+)
+
+type WarehouseService interface {
+	GetWarehouses(ctx context.Context) ([]models.Warehouse, error)
+	CreateWarehouse(ctx context.Context, input models.WarehouseInput) (models.Warehouse, error)
+	UpdateWarehouse(ctx context.Context, id uint, input models.WarehouseInput) (models.Warehouse, error)
+	DeleteWarehouse(ctx context.Context, id uint) error
+	GetWarehouseStock(ctx context.Context, warehouseID uint) ([]models.WarehouseStock, error)
+	GetProductStock(ctx context.Context, productID uint) (models.ProductStock, error)
+	SetStock(ctx context.Context, warehouseID, productID uint, quantity int) (models.WarehouseStock, error)
+	Transfer(ctx context.Context, input models.StockTransferInput) (models.StockTransfer, error)
+	GetTransfers(ctx context.Context, productID uint) ([]models.StockTransfer, error)
+	// AllocateTx and ReleaseTx return the products they changed as they
+	// were before, for StockChanged once tx commits.
+	AllocateTx(tx *gorm.DB, order *models.Order) ([]models.Product, error)
+	ReleaseTx(tx *gorm.DB, order *models.Order) ([]models.Product, error)
+	StockChanged(ctx context.Context, before ...models.Product)
+}
+
+type warehouseService struct {
+	DB             *gorm.DB
+	WebhookService webhookService.WebhookService
+	Listeners      []productService.ProductListener
+}
+
+// NewWarehouseService creates the warehouse service. Stock changes are queued
+// for webhooks within their transaction, the listeners are told about them
+// once it commits.
+func NewWarehouseService(db *gorm.DB, webhookService webhookService.WebhookService, listeners ...productService.ProductListener) WarehouseService {
+	return &warehouseService{DB: db, WebhookService: webhookService, Listeners: listeners}
+}
+
+func (s *warehouseService) GetWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	warehouses := []models.Warehouse{}
+	err := s.DB.WithContext(ctx).Order("priority").Order("id").Find(&warehouses).Error
+	return warehouses, err
+}
+
+func (s *warehouseService) CreateWarehouse(ctx context.Context, input models.WarehouseInput) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	if err := applyWarehouseInput(&warehouse, input); err != nil {
+		return models.Warehouse{}, err
+	}
+	if err := s.DB.WithContext(ctx).Create(&warehouse).Error; err != nil {
+		return models.Warehouse{}, err
+	}
+	return warehouse, nil
+}
+
+// UpdateWarehouse saves the warehouse and, when it was enabled or disabled,
+// recomputes the available stock of the products it holds.
+func (s *warehouseService) UpdateWarehouse(ctx context.Context, id uint, input models.WarehouseInput) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	var before []models.Product
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&warehouse, id).Error; err != nil {
+			return err
+		}
+		wasActive := warehouse.IsActive
+		if err := applyWarehouseInput(&warehouse, input); err != nil {
+			return err
+		}
+		if err := tx.Save(&warehouse).Error; err != nil {
+			return err
+		}
+		if wasActive == warehouse.IsActive {
+			return nil
+		}
+
+		var productIDs []uint
+		if err := tx.Model(&models.WarehouseStock{}).Where("warehouse_id = ?", id).Pluck("product_id", &productIDs).Error; err != nil {
+			return err
+		}
+		var err error
+		if before, err = products(tx, productIDs); err != nil {
+			return err
+		}
+		if err := syncProductStock(tx, productIDs...); err != nil {
+			return err
+		}
+		return s.WebhookService.StockChangedTx(tx, productIDs...)
+	})
+	if err != nil {
+		return models.Warehouse{}, err
+	}
+	s.StockChanged(ctx, before...)
+	return warehouse, nil
+}
+
+func (s *warehouseService) DeleteWarehouse(ctx context.Context, id uint) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stocked int64
+		if err := tx.Model(&models.WarehouseStock{}).Where("warehouse_id = ? AND quantity > 0", id).Count(&stocked).Error; err != nil {
+			return err
+		}
+		if stocked > 0 {
+			return ErrWarehouseNotEmpty
+		}
+
+		result := tx.Delete(&models.Warehouse{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Unscoped().Where("warehouse_id = ?", id).Delete(&models.WarehouseStock{}).Error
+	})
+}
+
+func (s *warehouseService) GetWarehouseStock(ctx context.Context, warehouseID uint) ([]models.WarehouseStock, error) {
+	if err := s.DB.WithContext(ctx).First(&models.Warehouse{}, warehouseID).Error; err != nil {
+		return nil, err
+	}
+	levels := []models.WarehouseStock{}
+	err := s.DB.WithContext(ctx).Where("warehouse_id = ?", warehouseID).Order("product_id").Find(&levels).Error
+	return levels, err
+}
+
+func (s *warehouseService) GetProductStock(ctx context.Context, productID uint) (models.ProductStock, error) {
+	var product models.Product
+	if err := s.DB.WithContext(ctx).First(&product, productID).Error; err != nil {
+		return models.ProductStock{}, err
+	}
+
+	stock := models.ProductStock{ProductID: productID, Total: product.Stock, Warehouses: []models.WarehouseStock{}}
+	err := s.DB.WithContext(ctx).Preload("Warehouse").Where("product_id = ?", productID).Order("warehouse_id").Find(&stock.Warehouses).Error
+	return stock, err
+}
+
+// SetStock sets the counted stock of a product in a warehouse.
+func (s *warehouseService) SetStock(ctx context.Context, warehouseID, productID uint, quantity int) (models.WarehouseStock, error) {
+	if quantity < 0 {
+		return models.WarehouseStock{}, fmt.Errorf("%w: quantity cannot be negative", ErrInvalidWarehouse)
+	}
+
+	var level models.WarehouseStock
+	var before models.Product
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Warehouse{}, warehouseID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&before, productID).Error; err != nil {
+			return err
+		}
+
+		level = models.WarehouseStock{WarehouseID: warehouseID, ProductID: productID, Quantity: quantity}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+		}).Create(&level).Error
+		if err != nil {
+			return err
+		}
+		if err := syncProductStock(tx, productID); err != nil {
+			return err
+		}
+		return s.WebhookService.StockChangedTx(tx, productID)
+	})
+	if err != nil {
+		return models.WarehouseStock{}, err
+	}
+	s.StockChanged(ctx, before)
+	return level, nil
+}
+
+// Transfer moves stock of a product between two warehouses.
+func (s *warehouseService) Transfer(ctx context.Context, input models.StockTransferInput) (models.StockTransfer, error) {
+	if input.Quantity <= 0 {
+		return models.StockTransfer{}, fmt.Errorf("%w: quantity must be positive", ErrInvalidTransfer)
+	}
+	if input.FromWarehouseID == input.ToWarehouseID {
+		return models.StockTransfer{}, fmt.Errorf("%w: source and destination are the same", ErrInvalidTransfer)
+	}
+
+	transfer := models.StockTransfer{
+		ProductID:       input.ProductID,
+		FromWarehouseID: input.FromWarehouseID,
+		ToWarehouseID:   input.ToWarehouseID,
+		Quantity:        input.Quantity,
+		Note:            strings.TrimSpace(input.Note),
+	}
+	var before []models.Product
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.Warehouse{}, input.ToWarehouseID).Error; err != nil {
+			return err
+		}
+		var err error
+		if before, err = products(tx, []uint{input.ProductID}); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.WarehouseStock{}).
+			Where("warehouse_id = ? AND product_id = ? AND quantity >= ?", input.FromWarehouseID, input.ProductID, input.Quantity).
+			Update("quantity", gorm.Expr("quantity - ?", input.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: not enough stock in the source warehouse", ErrInvalidTransfer)
+		}
+
+		if err := addStock(tx, input.ToWarehouseID, input.ProductID, input.Quantity); err != nil {
+			return err
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		if err := syncProductStock(tx, input.ProductID); err != nil {
+			return err
+		}
+		return s.WebhookService.StockChangedTx(tx, input.ProductID)
+	})
+	if err != nil {
+		return models.StockTransfer{}, err
+	}
+	s.StockChanged(ctx, before...)
+	return transfer, nil
+}
+
+func (s *warehouseService) GetTransfers(ctx context.Context, productID uint) ([]models.StockTransfer, error) {
+	transfers := []models.StockTransfer{}
+	query := s.DB.WithContext(ctx).Order("created_at DESC")
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	err := query.Find(&transfers).Error
+	return transfers, err
+}
+
+// AllocateTx takes the stock of a saved order. Lines of products stocked in
+// warehouses are allocated by the following rules, recorded as
+// OrderAllocations:
+//   - only active warehouses with stock are used
+//   - warehouses in the destination country come first
+//   - a warehouse that can ship the whole line beats splitting it
+//   - then the lowest priority, then the oldest warehouse
+//
+// A line is split over several warehouses when no single one can ship it.
+// Products without warehouse stock use Product.Stock directly.
+func (s *warehouseService) AllocateTx(tx *gorm.DB, order *models.Order) ([]models.Product, error) {
+	before, err := products(tx, orderProductIDs(order.Items))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range order.Items {
+		var levels []models.WarehouseStock
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Warehouse").
+			Where("product_id = ?", item.ProductID).
+			Find(&levels).Error
+		if err != nil {
+			return nil, err
+		}
+
+		if len(levels) == 0 {
+			result := tx.Model(&models.Product{}).
+				Where("id = ? AND stock >= ?", item.ProductID, item.Quantity).
+				Update("stock", gorm.Expr("stock - ?", item.Quantity))
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil, &cartService.CartItemError{ProductID: item.ProductID, Err: cartService.ErrInsufficientStock}
+			}
+			continue
+		}
+
+		allocations := allocate(levels, order.ShippingAddress.Country, item.Quantity)
+		if allocations == nil {
+			return nil, &cartService.CartItemError{ProductID: item.ProductID, Err: cartService.ErrInsufficientStock}
+		}
+		for _, allocation := range allocations {
+			err := tx.Model(&models.WarehouseStock{}).
+				Where("warehouse_id = ? AND product_id = ?", allocation.WarehouseID, item.ProductID).
+				Update("quantity", gorm.Expr("quantity - ?", allocation.Quantity)).Error
+			if err != nil {
+				return nil, err
+			}
+
+			allocation.OrderID = order.ID
+			allocation.ProductID = item.ProductID
+			if err := tx.Create(&allocation).Error; err != nil {
+				return nil, err
+			}
+		}
+		if err := syncProductStock(tx, item.ProductID); err != nil {
+			return nil, err
+		}
+	}
+	return before, s.WebhookService.StockChangedTx(tx, orderProductIDs(order.Items)...)
+}
+
+// ReleaseTx gives the stock of an order back, to the warehouses it was
+// allocated from, or to the first active warehouse for those deleted since.
+func (s *warehouseService) ReleaseTx(tx *gorm.DB, order *models.Order) ([]models.Product, error) {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	var allocations []models.OrderAllocation
+	if err := tx.Where("order_id = ?", order.ID).Find(&allocations).Error; err != nil {
+		return nil, err
+	}
+	before, err := products(tx, orderProductIDs(items))
+	if err != nil {
+		return nil, err
+	}
+
+	allocated := map[uint]bool{}
+	for _, allocation := range allocations {
+		warehouseID, err := releaseWarehouse(tx, allocation.WarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		// Without any warehouse left the stock goes back to Product.Stock.
+		if warehouseID == 0 {
+			continue
+		}
+		if err := addStock(tx, warehouseID, allocation.ProductID, allocation.Quantity); err != nil {
+			return nil, err
+		}
+		allocated[allocation.ProductID] = true
+	}
+	if len(allocations) > 0 {
+		if err := tx.Unscoped().Where("order_id = ?", order.ID).Delete(&models.OrderAllocation{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range items {
+		if allocated[item.ProductID] {
+			continue
+		}
+		err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+			Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	productIDs := make([]uint, 0, len(allocated))
+	for productID := range allocated {
+		productIDs = append(productIDs, productID)
+	}
+	if err := syncProductStock(tx, productIDs...); err != nil {
+		return nil, err
+	}
+	return before, s.WebhookService.StockChangedTx(tx, orderProductIDs(items)...)
+}
+
+// StockChanged tells the product listeners about the products whose stock a
+// committed transaction moved, before holds them as they were. Failures are
+// logged, the change is already committed.
+func (s *warehouseService) StockChanged(ctx context.Context, before ...models.Product) {
+	if len(before) == 0 || len(s.Listeners) == 0 {
+		return
+	}
+	productIDs := make([]uint, len(before))
+	for i, product := range before {
+		productIDs[i] = product.ID
+	}
+	after, err := products(s.DB.WithContext(ctx), productIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading products with changed stock", "error", err)
+		return
+	}
+
+	current := make(map[uint]models.Product, len(after))
+	for _, product := range after {
+		current[product.ID] = product
+	}
+	for _, old := range before {
+		product, ok := current[old.ID]
+		if !ok || product.Stock == old.Stock {
+			continue
+		}
+		for _, listener := range s.Listeners {
+			listener.ProductChanged(ctx, old, product)
+		}
+	}
+}
+
+// products loads the products with the given ids.
+func products(db *gorm.DB, productIDs []uint) ([]models.Product, error) {
+	products := []models.Product{}
+	if len(productIDs) == 0 {
+		return products, nil
+	}
+	err := db.Where("id IN ?", productIDs).Order("id").Find(&products).Error
+	return products, err
+}
+
+func orderProductIDs(items []models.OrderItem) []uint {
+	productIDs := make([]uint, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	return productIDs
+}
+
+// allocate picks the warehouses shipping quantity, or returns nil when the
+// active warehouses do not hold enough stock.
+func allocate(levels []models.WarehouseStock, country string, quantity int) []models.OrderAllocation {
+	candidates := []models.WarehouseStock{}
+	available := 0
+	for _, level := range levels {
+		if level.Warehouse.IsActive && level.Quantity > 0 {
+			candidates = append(candidates, level)
+			available += level.Quantity
+		}
+	}
+	if available < quantity {
+		return nil
+	}
+
+	country = strings.ToUpper(country)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if local := a.Warehouse.Country == country; local != (b.Warehouse.Country == country) {
+			return local
+		}
+		if whole := a.Quantity >= quantity; whole != (b.Quantity >= quantity) {
+			return whole
+		}
+		if a.Warehouse.Priority != b.Warehouse.Priority {
+			return a.Warehouse.Priority < b.Warehouse.Priority
+		}
+		return a.WarehouseID < b.WarehouseID
+	})
+
+	allocations := []models.OrderAllocation{}
+	for _, level := range candidates {
+		if quantity == 0 {
+			break
+		}
+		take := min(level.Quantity, quantity)
+		allocations = append(allocations, models.OrderAllocation{WarehouseID: level.WarehouseID, Quantity: take})
+		quantity -= take
+	}
+	return allocations
+}
+
+// releaseWarehouse returns the warehouse released stock goes back to: the
+// one it was allocated from unless it was deleted, then the active warehouse
+// with the lowest priority, an inactive one when none is active. It returns 0
+// when no warehouse is left.
+func releaseWarehouse(tx *gorm.DB, warehouseID uint) (uint, error) {
+	var warehouse models.Warehouse
+	result := tx.Where("id = ?", warehouseID).Limit(1).Find(&warehouse)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return warehouse.ID, result.Error
+	}
+	err := tx.Order("is_active DESC").Order("priority").Order("id").Limit(1).Find(&warehouse).Error
+	return warehouse.ID, err
+}
+
+func addStock(tx *gorm.DB, warehouseID, productID uint, quantity int) error {
+	level := models.WarehouseStock{WarehouseID: warehouseID, ProductID: productID, Quantity: quantity}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"quantity": gorm.Expr("warehouse_stocks.quantity + ?", quantity)}),
+	}).Create(&level).Error
+}
+
+// syncProductStock sets Product.Stock to the stock held in active
+// warehouses.
+func syncProductStock(tx *gorm.DB, productIDs ...uint) error {
+	for _, productID := range productIDs {
+		available := tx.Model(&models.WarehouseStock{}).
+			Select("COALESCE(SUM(warehouse_stocks.quantity), 0)").
+			Joins("JOIN warehouses ON warehouses.id = warehouse_stocks.warehouse_id AND warehouses.deleted_at IS NULL").
+			Where("warehouse_stocks.product_id = ? AND warehouses.is_active = ?", productID, true)
+
+		err := tx.Model(&models.Product{}).Where("id = ?", productID).Update("stock", available).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyWarehouseInput(warehouse *models.Warehouse, input models.WarehouseInput) error {
+	code := strings.ToUpper(strings.TrimSpace(input.Code))
+	name := strings.TrimSpace(input.Name)
+	country := strings.ToUpper(strings.TrimSpace(input.Country))
+	if code == "" || name == "" {
+		return fmt.Errorf("%w: code and name are required", ErrInvalidWarehouse)
+	}
+	if len(country) != 2 {
+		return fmt.Errorf("%w: country must be a two letter code", ErrInvalidWarehouse)
+	}
+
+	warehouse.Code = code
+	warehouse.Name = name
+	warehouse.Country = country
+	warehouse.Priority = input.Priority
+	warehouse.IsActive = input.IsActive
+	return nil
+}
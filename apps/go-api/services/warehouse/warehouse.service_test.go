@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-api/database/dbtest"
+	"go-api/models"
+	productService "go-api/services/product"
+	webhookService "go-api/services/webhook"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type stockChange struct {
+	before, after int
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.Category{}, &models.Product{}, &models.Warehouse{}, &models.WarehouseStock{},
+		&models.StockTransfer{}, &models.Order{}, &models.OrderItem{}, &models.OrderAllocation{}, &models.WebhookSubscription{})
+}
+
+func TestStockChangesNotifyListeners(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error
+		wantErr bool
+		want    []stockChange
+	}{
+		{
+			name: "set stock",
+			change: func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error {
+				_, err := s.SetStock(ctx, main.ID, product.ID, 7)
+				return err
+			},
+			want: []stockChange{{before: 10, after: 7}},
+		},
+		{
+			name: "set the same stock",
+			change: func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error {
+				_, err := s.SetStock(ctx, main.ID, product.ID, 10)
+				return err
+			},
+		},
+		{
+			name: "transfer to an inactive warehouse",
+			change: func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error {
+				_, err := s.Transfer(ctx, models.StockTransferInput{ProductID: product.ID, FromWarehouseID: main.ID, ToWarehouseID: spare.ID, Quantity: 4})
+				return err
+			},
+			want: []stockChange{{before: 10, after: 6}},
+		},
+		{
+			name: "transfer more than there is",
+			change: func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error {
+				_, err := s.Transfer(ctx, models.StockTransferInput{ProductID: product.ID, FromWarehouseID: main.ID, ToWarehouseID: spare.ID, Quantity: 11})
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name: "deactivate warehouse",
+			change: func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error {
+				_, err := s.UpdateWarehouse(ctx, main.ID, models.WarehouseInput{Code: main.Code, Name: main.Name, Country: main.Country})
+				return err
+			},
+			want: []stockChange{{before: 10, after: 0}},
+		},
+		{
+			name: "allocate and release an order",
+			change: func(ctx context.Context, s WarehouseService, main, spare models.Warehouse, product models.Product) error {
+				db := s.(*warehouseService).DB
+				order := models.Order{UserID: "user", Items: []models.OrderItem{{ProductID: product.ID, Quantity: 3}}}
+				for _, change := range []func(tx *gorm.DB, order *models.Order) ([]models.Product, error){s.AllocateTx, s.ReleaseTx} {
+					var before []models.Product
+					err := db.Transaction(func(tx *gorm.DB) error {
+						if order.ID == 0 {
+							if err := tx.Create(&order).Error; err != nil {
+								return err
+							}
+						}
+						var err error
+						before, err = change(tx, &order)
+						return err
+					})
+					if err != nil {
+						return err
+					}
+					s.StockChanged(ctx, before...)
+				}
+				return nil
+			},
+			want: []stockChange{{before: 10, after: 7}, {before: 7, after: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+			listener := &productService.RecordingListener{}
+			s := NewWarehouseService(db, webhookService.NewWebhookService(db, webhookService.Options{}), listener)
+
+			main, err := s.CreateWarehouse(ctx, models.WarehouseInput{Code: "MAIN", Name: "Main", Country: "DE", IsActive: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			spare, err := s.CreateWarehouse(ctx, models.WarehouseInput{Code: "SPARE", Name: "Spare", Country: "DE"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", SKU: "LAMP-1"})
+			if _, err := s.SetStock(ctx, main.ID, product.ID, 10); err != nil {
+				t.Fatal(err)
+			}
+			listener.Changes = nil
+
+			err = tt.change(ctx, s, main, spare, product)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("change error = %v, want error %v", err, tt.wantErr)
+			}
+			var changes []stockChange
+			for _, change := range listener.Changes {
+				changes = append(changes, stockChange{before: change.Before.Stock, after: change.After.Stock})
+			}
+			if len(changes) != len(tt.want) {
+				t.Fatalf("listeners saw %+v, want %+v", changes, tt.want)
+			}
+			for i, want := range tt.want {
+				if changes[i] != want {
+					t.Errorf("change %d = %+v, want %+v", i, changes[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseIntoDeletedWarehouse(t *testing.T) {
+	tests := []struct {
+		name          string
+		otherActive   bool
+		deleteOther   bool
+		wantWarehouse string
+		wantStock     int
+	}{
+		{name: "active warehouse", otherActive: true, wantWarehouse: "OTHER", wantStock: 3},
+		{name: "only an inactive warehouse", wantWarehouse: "OTHER", wantStock: 0},
+		{name: "no warehouse left", deleteOther: true, wantStock: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+			s := NewWarehouseService(db, webhookService.NewWebhookService(db, webhookService.Options{}))
+			main, err := s.CreateWarehouse(ctx, models.WarehouseInput{Code: "MAIN", Name: "Main", Country: "DE", IsActive: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := s.CreateWarehouse(ctx, models.WarehouseInput{Code: "OTHER", Name: "Other", Country: "DE", IsActive: tt.otherActive})
+			if err != nil {
+				t.Fatal(err)
+			}
+			product := dbtest.Product(t, db, models.Product{Name: "Lamp", SKU: "LAMP-1"})
+			if _, err := s.SetStock(ctx, main.ID, product.ID, 10); err != nil {
+				t.Fatal(err)
+			}
+
+			order := models.Order{UserID: "user", Items: []models.OrderItem{{ProductID: product.ID, Quantity: 3}}}
+			err = db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&order).Error; err != nil {
+					return err
+				}
+				_, err := s.AllocateTx(tx, &order)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The rest of the stock leaves the warehouse before it is deleted.
+			if _, err := s.SetStock(ctx, main.ID, product.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			deleted := []models.Warehouse{main}
+			if tt.deleteOther {
+				deleted = append(deleted, other)
+			}
+			for _, warehouse := range deleted {
+				if err := s.DeleteWarehouse(ctx, warehouse.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = db.Transaction(func(tx *gorm.DB) error {
+				_, err := s.ReleaseTx(tx, &order)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stock, err := s.GetProductStock(ctx, product.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var released []string
+			for _, level := range stock.Warehouses {
+				if level.Quantity > 0 {
+					released = append(released, fmt.Sprintf("%s:%d", level.Warehouse.Code, level.Quantity))
+				}
+			}
+			var want []string
+			if tt.wantWarehouse != "" {
+				want = []string{tt.wantWarehouse + ":3"}
+			}
+			if !reflect.DeepEqual(released, want) {
+				t.Errorf("warehouse stock = %v, want %v", released, want)
+			}
+			if stock.Total != tt.wantStock {
+				t.Errorf("product stock = %d, want %d", stock.Total, tt.wantStock)
+			}
+		})
+	}
+}
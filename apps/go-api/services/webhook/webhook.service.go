@@ -0,0 +1,594 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-api/core/metrics"
+	"go-api/models"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidWebhook  = errors.New("invalid webhook subscription")
+	ErrDeliveryPending = errors.New("webhook delivery is still pending")
+	ErrPrivateAddress  = errors.New("webhook address is on a private network")
+)
+
+// Events are the event types a subscription can receive.
+var Events = []string{
+	models.WebhookProductCreated,
+	models.WebhookProductUpdated,
+	models.WebhookProductDeleted,
+	models.WebhookStockChanged,
+	models.WebhookOrderCreated,
+	models.WebhookOrderStatusChanged,
+}
+
+// Headers sent with every delivery. The signature is the hex encoded
+// HMAC-SHA256, keyed with the subscription secret, of the timestamp, a dot
+// and the body. Receivers should reject old timestamps and dedupe on the
+// event ID, which replays keep.
+const (
+	HeaderEventID   = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// secretPrefix starts every generated secret.
+const secretPrefix = "whsec_"
+
+// maxResponseBody is how much of a response is kept in the delivery log.
+const maxResponseBody = 1024
+
+type WebhookService interface {
+	GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id uint) (models.WebhookSubscription, error)
+	CreateSubscription(ctx context.Context, input models.WebhookSubscriptionInput) (models.NewWebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id uint, input models.WebhookSubscriptionInput) (models.NewWebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uint) error
+	GetDeliveries(ctx context.Context, subscriptionID uint, status string) ([]models.WebhookDelivery, error)
+	Replay(ctx context.Context, deliveryID uint) (models.WebhookDelivery, error)
+	Enqueue(ctx context.Context, event string, data interface{}) error
+	EnqueueTx(tx *gorm.DB, event string, data interface{}) error
+	StockChangedTx(tx *gorm.DB, productIDs ...uint) error
+	DeliverDue(ctx context.Context) error
+	ProductChanged(ctx context.Context, before, after models.Product)
+}
+
+type Options struct {
+	// Timeout bounds each delivery request.
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked failed.
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt. It doubles with
+	// every further attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// BatchSize limits the deliveries sent per run of DeliverDue.
+	BatchSize int
+	// AllowPrivateNetworks lets deliveries reach loopback, link-local,
+	// private and unspecified addresses, for local tests. They are refused
+	// otherwise so a subscription cannot probe the internal network.
+	AllowPrivateNetworks bool
+}
+
+type webhookService struct {
+	DB      *gorm.DB
+	Client  *http.Client
+	Options Options
+}
+
+func NewWebhookService(db *gorm.DB, options Options) WebhookService {
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 10
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = 30 * time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 6 * time.Hour
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 50
+	}
+
+	dialer := &net.Dialer{Timeout: options.Timeout}
+	if !options.AllowPrivateNetworks {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be the only address the dialer gets to check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   options.Timeout,
+		// A redirect would turn the POST into a GET, it counts as a
+		// failed attempt instead.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &webhookService{DB: db, Client: client, Options: options}
+}
+
+func (s *webhookService) GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subscriptions := []models.WebhookSubscription{}
+	err := s.DB.WithContext(ctx).Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (s *webhookService) GetSubscription(ctx context.Context, id uint) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := s.DB.WithContext(ctx).First(&subscription, id).Error
+	return subscription, err
+}
+
+// CreateSubscription saves a subscription and returns its secret, generated
+// unless the partner supplied one.
+func (s *webhookService) CreateSubscription(ctx context.Context, input models.WebhookSubscriptionInput) (models.NewWebhookSubscription, error) {
+	subscription := models.WebhookSubscription{IsActive: true}
+	secret, err := applySubscriptionInput(&subscription, input)
+	if err != nil {
+		return models.NewWebhookSubscription{}, err
+	}
+	if secret == "" {
+		if secret, err = generateSecret(); err != nil {
+			return models.NewWebhookSubscription{}, err
+		}
+	}
+	subscription.Secret = secret
+
+	if err := s.DB.WithContext(ctx).Create(&subscription).Error; err != nil {
+		return models.NewWebhookSubscription{}, err
+	}
+	return models.NewWebhookSubscription{WebhookSubscription: subscription, Secret: secret}, nil
+}
+
+// UpdateSubscription replaces a subscription. The secret is only returned
+// when the input rotates it.
+func (s *webhookService) UpdateSubscription(ctx context.Context, id uint, input models.WebhookSubscriptionInput) (models.NewWebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := s.DB.WithContext(ctx).First(&subscription, id).Error; err != nil {
+		return models.NewWebhookSubscription{}, err
+	}
+	secret, err := applySubscriptionInput(&subscription, input)
+	if err != nil {
+		return models.NewWebhookSubscription{}, err
+	}
+	if secret != "" {
+		subscription.Secret = secret
+	}
+
+	if err := s.DB.WithContext(ctx).Save(&subscription).Error; err != nil {
+		return models.NewWebhookSubscription{}, err
+	}
+	return models.NewWebhookSubscription{WebhookSubscription: subscription, Secret: secret}, nil
+}
+
+// DeleteSubscription stops a subscription. Its pending deliveries fail on
+// their next attempt, the delivery log is kept.
+func (s *webhookService) DeleteSubscription(ctx context.Context, id uint) error {
+	result := s.DB.WithContext(ctx).Delete(&models.WebhookSubscription{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetDeliveries returns the latest 100 deliveries of a subscription, newest
+// first, optionally only those with status.
+func (s *webhookService) GetDeliveries(ctx context.Context, subscriptionID uint, status string) ([]models.WebhookDelivery, error) {
+	if err := s.DB.WithContext(ctx).First(&models.WebhookSubscription{}, subscriptionID).Error; err != nil {
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	query := s.DB.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Order("id DESC").Limit(100)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Replay queues a finished delivery again, with the same event ID and body.
+// The original stays in the log untouched.
+func (s *webhookService) Replay(ctx context.Context, deliveryID uint) (models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := s.DB.WithContext(ctx).First(&original, deliveryID).Error; err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if original.Status == models.WebhookDeliveryPending {
+		return models.WebhookDelivery{}, ErrDeliveryPending
+	}
+
+	var subscription models.WebhookSubscription
+	if err := s.DB.WithContext(ctx).First(&subscription, original.SubscriptionID).Error; err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if !subscription.IsActive {
+		return models.WebhookDelivery{}, fmt.Errorf("%w: subscription is disabled", ErrInvalidWebhook)
+	}
+
+	now := time.Now()
+	replay := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  &now,
+		ReplayOf:       &original.ID,
+	}
+	if err := s.DB.WithContext(ctx).Create(&replay).Error; err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return replay, nil
+}
+
+// Enqueue queues event for every active subscription receiving it.
+func (s *webhookService) Enqueue(ctx context.Context, event string, data interface{}) error {
+	return s.EnqueueTx(s.DB.WithContext(ctx), event, data)
+}
+
+// EnqueueTx queues event within tx, so it is only sent if tx commits.
+func (s *webhookService) EnqueueTx(tx *gorm.DB, event string, data interface{}) error {
+	subscriptions, err := subscribers(tx, event)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+	return queue(tx, subscriptions, event, data)
+}
+
+// StockChangedTx queues a stock.changed event with the stock of each
+// product, as updated within tx.
+func (s *webhookService) StockChangedTx(tx *gorm.DB, productIDs ...uint) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	subscriptions, err := subscribers(tx, models.WebhookStockChanged)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	var products []models.Product
+	if err := tx.Select("id", "sku", "stock").Where("id IN ?", productIDs).Order("id").Find(&products).Error; err != nil {
+		return err
+	}
+	for _, product := range products {
+		level := models.StockLevel{ProductID: product.ID, SKU: product.SKU, Stock: product.Stock}
+		if err := queue(tx, subscriptions, models.WebhookStockChanged, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProductChanged reports the products created, edited and trashed through
+// the product and catalog services. A product restored from the trash is
+// reported as created again. Stock moved by orders and warehouses is
+// reported by the services moving it. Failures are logged, they never fail
+// the change.
+func (s *webhookService) ProductChanged(ctx context.Context, before, after models.Product) {
+	event := models.WebhookProductUpdated
+	switch {
+	case after.DeletedAt.Valid:
+		event = models.WebhookProductDeleted
+	case before.ID == 0 || before.DeletedAt.Valid:
+		event = models.WebhookProductCreated
+	}
+	if err := s.Enqueue(ctx, event, after); err != nil {
+		slog.ErrorContext(ctx, "Error queueing webhook", "event", event, "error", err)
+	}
+	if event == models.WebhookProductUpdated && before.Stock != after.Stock {
+		level := models.StockLevel{ProductID: after.ID, SKU: after.SKU, Stock: after.Stock}
+		if err := s.Enqueue(ctx, models.WebhookStockChanged, level); err != nil {
+			slog.ErrorContext(ctx, "Error queueing webhook", "event", models.WebhookStockChanged, "error", err)
+		}
+	}
+}
+
+// DeliverDue sends the deliveries whose next attempt is due, oldest first.
+// Each one is claimed before it is sent by counting the attempt and
+// pushing its next attempt past the request timeout, so replicas running
+// the same job do not send it twice.
+func (s *webhookService) DeliverDue(ctx context.Context) error {
+	var due []models.WebhookDelivery
+	err := s.DB.WithContext(ctx).Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+		Order("next_attempt_at").Order("id").
+		Limit(s.Options.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range due {
+		claimed := s.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND attempts = ?", delivery.ID, models.WebhookDeliveryPending, delivery.Attempts).
+			Updates(map[string]interface{}{
+				"attempts":        delivery.Attempts + 1,
+				"next_attempt_at": time.Now().Add(2 * s.Options.Timeout),
+			})
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			continue
+		}
+		delivery.Attempts++
+
+		if err := s.deliver(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver sends one attempt of delivery and records its outcome. Attempts
+// for a deleted or disabled subscription fail without being retried.
+func (s *webhookService) deliver(ctx context.Context, delivery models.WebhookDelivery) error {
+	now := time.Now()
+	updates := map[string]interface{}{"last_attempt_at": now}
+	retry := delivery.Attempts < s.Options.MaxAttempts
+
+	var subscription models.WebhookSubscription
+	err := s.DB.WithContext(ctx).Unscoped().First(&subscription, delivery.SubscriptionID).Error
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	case err != nil || subscription.DeletedAt.Valid:
+		err, retry = errors.New("subscription was deleted"), false
+	case !subscription.IsActive:
+		err, retry = errors.New("subscription is disabled"), false
+	default:
+		var status int
+		var body string
+		status, body, err = s.post(ctx, subscription, delivery)
+		updates["response_status"] = status
+		updates["response_body"] = body
+	}
+
+	var result string
+	switch {
+	case err == nil:
+		result = models.WebhookDeliverySucceeded
+		updates["status"] = result
+		updates["delivered_at"] = now
+		updates["next_attempt_at"] = nil
+		updates["error"] = ""
+	case retry:
+		result = "retrying"
+		updates["next_attempt_at"] = now.Add(s.backoff(delivery.Attempts))
+		updates["error"] = err.Error()
+	default:
+		result = models.WebhookDeliveryFailed
+		updates["status"] = result
+		updates["next_attempt_at"] = nil
+		updates["error"] = err.Error()
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Webhook delivery failed", "delivery_id", delivery.ID, "event", delivery.Event,
+			"attempt", delivery.Attempts, "result", result, "error", err)
+	}
+	metrics.WebhookDeliveries.WithLabelValues(delivery.Event, result).Inc()
+
+	return s.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error
+}
+
+// post sends the delivery body to the subscription URL. Any status other
+// than 2xx is an error.
+func (s *webhookService) post(ctx context.Context, subscription models.WebhookSubscription, delivery models.WebhookDelivery) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Options.Timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "go-api-webhooks/1.0")
+	request.Header.Set(HeaderEventID, delivery.EventID)
+	request.Header.Set(HeaderEvent, delivery.Event)
+	request.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, SignPayload(subscription.Secret, timestamp, body))
+
+	response, err := s.Client.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+
+	excerpt, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseBody))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, string(excerpt), fmt.Errorf("endpoint answered %d", response.StatusCode)
+	}
+	return response.StatusCode, string(excerpt), nil
+}
+
+// refusePrivateAddress is a dialer control refusing private addresses. It
+// runs on the resolved address of every connection, so host names resolving
+// to a private address are refused too.
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// backoff is the wait after the given failed attempt.
+func (s *webhookService) backoff(attempt int) time.Duration {
+	wait := s.Options.Backoff
+	for i := 1; i < attempt && wait < s.Options.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.Options.MaxBackoff)
+}
+
+// SignPayload returns the value of the X-Webhook-Signature header of a
+// delivery sent at timestamp, in Unix seconds.
+func SignPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribers returns the active subscriptions receiving event.
+func subscribers(tx *gorm.DB, event string) ([]models.WebhookSubscription, error) {
+	var active []models.WebhookSubscription
+	if err := tx.Where("is_active = ?", true).Order("id").Find(&active).Error; err != nil {
+		return nil, err
+	}
+
+	subscriptions := active[:0]
+	for _, subscription := range active {
+		for _, subscribed := range strings.Split(subscription.Events, ",") {
+			if subscribed == event || subscribed == models.WebhookAllEvents {
+				subscriptions = append(subscriptions, subscription)
+				break
+			}
+		}
+	}
+	return subscriptions, nil
+}
+
+// queue writes one delivery of a new event per subscription.
+func queue(tx *gorm.DB, subscriptions []models.WebhookSubscription, event string, data interface{}) error {
+	now := time.Now()
+	eventID := uuid.NewString()
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:        eventID,
+		Type:      event,
+		CreatedAt: now.UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(subscriptions))
+	for i, subscription := range subscriptions {
+		deliveries[i] = models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        eventID,
+			Event:          event,
+			Payload:        string(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  &now,
+		}
+	}
+	return tx.Create(&deliveries).Error
+}
+
+// applySubscriptionInput validates input into subscription and returns the
+// secret it sets, if any.
+func applySubscriptionInput(subscription *models.WebhookSubscription, input models.WebhookSubscriptionInput) (string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidWebhook)
+	}
+	target, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "", fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	events, err := parseEvents(input.Events)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(input.Secret)
+	if secret != "" && len(secret) < 16 {
+		return "", fmt.Errorf("%w: secret must be at least 16 characters", ErrInvalidWebhook)
+	}
+
+	subscription.Name = name
+	subscription.URL = target.String()
+	subscription.Events = events
+	if input.IsActive != nil {
+		subscription.IsActive = *input.IsActive
+	}
+	return secret, nil
+}
+
+// parseEvents validates a comma separated list of event types and returns
+// it sorted without duplicates. "*" stands for every event.
+func parseEvents(list string) (string, error) {
+	known := make(map[string]bool, len(Events))
+	for _, event := range Events {
+		known[event] = true
+	}
+
+	seen := map[string]bool{}
+	var events []string
+	for _, event := range strings.Split(list, ",") {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if event == "" || seen[event] {
+			continue
+		}
+		if event == models.WebhookAllEvents {
+			return models.WebhookAllEvents, nil
+		}
+		if !known[event] {
+			return "", fmt.Errorf("%w: unknown event %q, expected one of %s or *", ErrInvalidWebhook, event, strings.Join(Events, ", "))
+		}
+		seen[event] = true
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return "", fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	sort.Strings(events)
+	return strings.Join(events, ","), nil
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
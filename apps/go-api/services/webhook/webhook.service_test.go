@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-api/database/dbtest"
+	"go-api/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const testSecret = "whsec_test_secret_0123456789"
+
+// endpoint is a partner stand-in answering every delivery with status and
+// recording what it received.
+type endpoint struct {
+	*httptest.Server
+	status int
+
+	mu       sync.Mutex
+	requests []received
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func newEndpoint(t *testing.T, status int) *endpoint {
+	t.Helper()
+	e := &endpoint{status: status}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		e.mu.Lock()
+		e.requests = append(e.requests, received{header: r.Header.Clone(), body: body})
+		e.mu.Unlock()
+		if e.status == http.StatusFound {
+			w.Header().Set("Location", "/elsewhere")
+		}
+		w.WriteHeader(e.status)
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *endpoint) received() []received {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]received(nil), e.requests...)
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.WebhookSubscription{}, &models.WebhookDelivery{})
+}
+
+// subscribe creates a subscription of url to every event.
+func subscribe(t *testing.T, s WebhookService, url string) models.NewWebhookSubscription {
+	t.Helper()
+	subscription, err := s.CreateSubscription(context.Background(), models.WebhookSubscriptionInput{
+		Name: "Partner", URL: url, Events: models.WebhookAllEvents, Secret: testSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subscription
+}
+
+func deliveries(t *testing.T, db *gorm.DB) []models.WebhookDelivery {
+	t.Helper()
+	var deliveries []models.WebhookDelivery
+	if err := db.Order("id").Find(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+	return deliveries
+}
+
+func TestDeliverDue(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantStatus   string
+		wantResponse int
+	}{
+		{name: "ok", status: http.StatusOK, wantStatus: models.WebhookDeliverySucceeded, wantResponse: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent, wantStatus: models.WebhookDeliverySucceeded, wantResponse: http.StatusNoContent},
+		{name: "server error", status: http.StatusInternalServerError, wantStatus: models.WebhookDeliveryPending, wantResponse: http.StatusInternalServerError},
+		{name: "redirect", status: http.StatusFound, wantStatus: models.WebhookDeliveryPending, wantResponse: http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			partner := newEndpoint(t, tt.status)
+			s := NewWebhookService(db, Options{AllowPrivateNetworks: true})
+			subscribe(t, s, partner.URL)
+
+			if err := s.Enqueue(ctx, models.WebhookOrderStatusChanged, models.OrderStatusChange{OrderID: 7, From: "pending", To: "paid"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.DeliverDue(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			requests := partner.received()
+			if len(requests) != 1 {
+				t.Fatalf("partner received %d requests, want 1", len(requests))
+			}
+			delivery := deliveries(t, db)[0]
+			request := requests[0]
+			if request.header.Get(HeaderEventID) != delivery.EventID || request.header.Get(HeaderEvent) != models.WebhookOrderStatusChanged {
+				t.Errorf("event headers = %v", request.header)
+			}
+			if request.header.Get(HeaderDelivery) != strconv.FormatUint(uint64(delivery.ID), 10) {
+				t.Errorf("delivery header = %q, want %d", request.header.Get(HeaderDelivery), delivery.ID)
+			}
+			timestamp, err := strconv.ParseInt(request.header.Get(HeaderTimestamp), 10, 64)
+			if err != nil {
+				t.Fatalf("timestamp header: %v", err)
+			}
+			if got, want := request.header.Get(HeaderSignature), SignPayload(testSecret, timestamp, request.body); got != want {
+				t.Errorf("signature = %q, want %q", got, want)
+			}
+			if string(request.body) != delivery.Payload {
+				t.Errorf("body = %s, want %s", request.body, delivery.Payload)
+			}
+
+			if delivery.Status != tt.wantStatus || delivery.ResponseStatus != tt.wantResponse || delivery.Attempts != 1 {
+				t.Errorf("delivery = %+v", delivery)
+			}
+		})
+	}
+}
+
+func TestDeliveryRetrySchedule(t *testing.T) {
+	// Each attempt fails, the wait doubles up to MaxBackoff and the last
+	// attempt marks the delivery failed.
+	attempts := []struct {
+		wantStatus string
+		wantWait   time.Duration
+	}{
+		{wantStatus: models.WebhookDeliveryPending, wantWait: time.Minute},
+		{wantStatus: models.WebhookDeliveryPending, wantWait: 2 * time.Minute},
+		{wantStatus: models.WebhookDeliveryPending, wantWait: 3 * time.Minute},
+		{wantStatus: models.WebhookDeliveryPending, wantWait: 3 * time.Minute},
+		{wantStatus: models.WebhookDeliveryFailed},
+	}
+
+	ctx := context.Background()
+	db := newTestDB(t)
+	partner := newEndpoint(t, http.StatusServiceUnavailable)
+	s := NewWebhookService(db, Options{
+		MaxAttempts:          len(attempts),
+		Backoff:              time.Minute,
+		MaxBackoff:           3 * time.Minute,
+		AllowPrivateNetworks: true,
+	})
+	subscribe(t, s, partner.URL)
+	if err := s.Enqueue(ctx, models.WebhookStockChanged, models.StockLevel{ProductID: 1, Stock: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, attempt := range attempts {
+		started := time.Now()
+		if err := s.DeliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		delivery := deliveries(t, db)[0]
+		if delivery.Attempts != i+1 || delivery.Status != attempt.wantStatus {
+			t.Fatalf("attempt %d: delivery = %+v, want status %s", i+1, delivery, attempt.wantStatus)
+		}
+
+		if attempt.wantWait == 0 {
+			if delivery.NextAttemptAt != nil || !strings.Contains(delivery.Error, "503") {
+				t.Errorf("attempt %d: failed delivery = %+v", i+1, delivery)
+			}
+			continue
+		}
+		if delivery.NextAttemptAt == nil {
+			t.Fatalf("attempt %d: no next attempt", i+1)
+		}
+		if wait := delivery.NextAttemptAt.Sub(started); wait < attempt.wantWait || wait > attempt.wantWait+time.Second {
+			t.Errorf("attempt %d: next attempt in %s, want %s", i+1, wait, attempt.wantWait)
+		}
+
+		// The next attempt is not due yet.
+		if err := s.DeliverDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(partner.received()); got != i+1 {
+			t.Fatalf("attempt %d: partner received %d requests before the next attempt was due", i+1, got)
+		}
+		if err := db.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(partner.received()); got != len(attempts) {
+		t.Errorf("partner received %d requests, want %d", got, len(attempts))
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(db *gorm.DB, s WebhookService) error
+		wantErr error
+	}{
+		{
+			name:  "delivered",
+			setup: func(db *gorm.DB, s WebhookService) error { return s.DeliverDue(context.Background()) },
+		},
+		{
+			name:    "still pending",
+			setup:   func(db *gorm.DB, s WebhookService) error { return nil },
+			wantErr: ErrDeliveryPending,
+		},
+		{
+			name: "disabled subscription",
+			setup: func(db *gorm.DB, s WebhookService) error {
+				if err := s.DeliverDue(context.Background()); err != nil {
+					return err
+				}
+				return db.Model(&models.WebhookSubscription{}).Where("1 = 1").Update("is_active", false).Error
+			},
+			wantErr: ErrInvalidWebhook,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			partner := newEndpoint(t, http.StatusOK)
+			s := NewWebhookService(db, Options{AllowPrivateNetworks: true})
+			subscribe(t, s, partner.URL)
+			if err := s.Enqueue(ctx, models.WebhookOrderCreated, models.Order{UserID: "user"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.setup(db, s); err != nil {
+				t.Fatal(err)
+			}
+			original := deliveries(t, db)[0]
+
+			replay, err := s.Replay(ctx, original.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Replay error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if replay.EventID != original.EventID || replay.Payload != original.Payload || replay.ReplayOf == nil || *replay.ReplayOf != original.ID {
+				t.Errorf("replay = %+v of %+v", replay, original)
+			}
+
+			if err := s.DeliverDue(ctx); err != nil {
+				t.Fatal(err)
+			}
+			requests := partner.received()
+			if len(requests) != 2 {
+				t.Fatalf("partner received %d requests, want 2", len(requests))
+			}
+			for _, request := range requests {
+				if request.header.Get(HeaderEventID) != original.EventID {
+					t.Errorf("event id = %q, want %q", request.header.Get(HeaderEventID), original.EventID)
+				}
+			}
+			if requests[0].header.Get(HeaderDelivery) == requests[1].header.Get(HeaderDelivery) {
+				t.Error("the replay was sent as the same delivery")
+			}
+		})
+	}
+}
+
+func TestPrivateNetworks(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      bool
+		wantStatus string
+		wantSent   int
+	}{
+		{name: "refused", wantStatus: models.WebhookDeliveryPending},
+		{name: "allowed", allow: true, wantStatus: models.WebhookDeliverySucceeded, wantSent: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			partner := newEndpoint(t, http.StatusOK)
+			s := NewWebhookService(db, Options{AllowPrivateNetworks: tt.allow})
+			subscribe(t, s, partner.URL)
+			if err := s.Enqueue(ctx, models.WebhookStockChanged, models.StockLevel{ProductID: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.DeliverDue(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			delivery := deliveries(t, db)[0]
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", delivery.Status, tt.wantStatus)
+			}
+			if !tt.allow && !strings.Contains(delivery.Error, ErrPrivateAddress.Error()) {
+				t.Errorf("error = %q, want a private address error", delivery.Error)
+			}
+			if got := len(partner.received()); got != tt.wantSent {
+				t.Errorf("partner received %d requests, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{address: "127.0.0.1:443", refused: true},
+		{address: "10.1.2.3:443", refused: true},
+		{address: "172.16.0.1:443", refused: true},
+		{address: "192.168.1.10:443", refused: true},
+		{address: "169.254.169.254:80", refused: true},
+		{address: "0.0.0.0:443", refused: true},
+		{address: "[::1]:443", refused: true},
+		{address: "[::]:443", refused: true},
+		{address: "[fe80::1]:443", refused: true},
+		{address: "[fd00::1]:443", refused: true},
+		{address: "[::ffff:127.0.0.1]:443", refused: true},
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1::]:443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := refusePrivateAddress("tcp", tt.address, nil)
+			if errors.Is(err, ErrPrivateAddress) != tt.refused {
+				t.Errorf("refusePrivateAddress(%s) = %v, refused %v", tt.address, err, tt.refused)
+			}
+			if !tt.refused && err != nil {
+				t.Errorf("refusePrivateAddress(%s) = %v", tt.address, err)
+			}
+		})
+	}
+}
+
+func TestProductChangedEvents(t *testing.T) {
+	product := models.Product{Model: models.Model{ID: 7}, Name: "Lamp", SKU: "LAMP-1", Stock: 5}
+	withStock := product
+	withStock.Stock = 2
+	trashed := product
+	trashed.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name          string
+		before, after models.Product
+		want          []string
+	}{
+		{name: "created", after: product, want: []string{models.WebhookProductCreated}},
+		{name: "updated", before: product, after: product, want: []string{models.WebhookProductUpdated}},
+		{name: "stock changed", before: product, after: withStock, want: []string{models.WebhookProductUpdated, models.WebhookStockChanged}},
+		{name: "deleted", before: product, after: trashed, want: []string{models.WebhookProductDeleted}},
+		{name: "restored", before: trashed, after: product, want: []string{models.WebhookProductCreated}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := NewWebhookService(db, Options{})
+			subscribe(t, s, "https://partner.example.com/hooks")
+
+			s.ProductChanged(context.Background(), tt.before, tt.after)
+
+			var got []string
+			for _, delivery := range deliveries(t, db) {
+				got = append(got, delivery.Event)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("queued %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-api/models"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidWishlist = errors.New("invalid wishlist")
+
+// WishlistService manages the lists of a user. Lists of other users are
+// reported as not found.
+type WishlistService interface {
+	GetWishlists(ctx context.Context, userID string) ([]models.Wishlist, error)
+	GetWishlist(ctx context.Context, id uint, userID string) (models.Wishlist, error)
+	GetSharedWishlist(ctx context.Context, token string) (models.Wishlist, error)
+	CreateWishlist(ctx context.Context, userID string, input models.WishlistInput) (models.Wishlist, error)
+	RenameWishlist(ctx context.Context, id uint, userID string, input models.WishlistInput) (models.Wishlist, error)
+	DeleteWishlist(ctx context.Context, id uint, userID string) error
+	AddItem(ctx context.Context, id uint, userID string, input models.WishlistItemInput) (models.Wishlist, error)
+	RemoveItem(ctx context.Context, id uint, userID string, productID uint) (models.Wishlist, error)
+	Share(ctx context.Context, id uint, userID string) (models.Wishlist, error)
+	Unshare(ctx context.Context, id uint, userID string) (models.Wishlist, error)
+}
+
+type wishlistService struct {
+	DB *gorm.DB
+}
+
+func NewWishlistService(db *gorm.DB) WishlistService {
+	return &wishlistService{DB: db}
+}
+
+func (s *wishlistService) GetWishlists(ctx context.Context, userID string) ([]models.Wishlist, error) {
+	wishlists := []models.Wishlist{}
+	err := s.DB.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&wishlists).Error
+	return wishlists, err
+}
+
+func (s *wishlistService) GetWishlist(ctx context.Context, id uint, userID string) (models.Wishlist, error) {
+	return s.load(s.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID))
+}
+
+func (s *wishlistService) GetSharedWishlist(ctx context.Context, token string) (models.Wishlist, error) {
+	if token == "" {
+		return models.Wishlist{}, gorm.ErrRecordNotFound
+	}
+	return s.load(s.DB.WithContext(ctx).Where("share_token = ?", token))
+}
+
+func (s *wishlistService) CreateWishlist(ctx context.Context, userID string, input models.WishlistInput) (models.Wishlist, error) {
+	name, err := wishlistName(input)
+	if err != nil {
+		return models.Wishlist{}, err
+	}
+
+	wishlist := models.Wishlist{UserID: userID, Name: name}
+	if err := s.DB.WithContext(ctx).Create(&wishlist).Error; err != nil {
+		return models.Wishlist{}, err
+	}
+	return wishlist, nil
+}
+
+func (s *wishlistService) RenameWishlist(ctx context.Context, id uint, userID string, input models.WishlistInput) (models.Wishlist, error) {
+	name, err := wishlistName(input)
+	if err != nil {
+		return models.Wishlist{}, err
+	}
+	if err := s.update(ctx, id, userID, "name", name); err != nil {
+		return models.Wishlist{}, err
+	}
+	return s.GetWishlist(ctx, id, userID)
+}
+
+func (s *wishlistService) DeleteWishlist(ctx context.Context, id uint, userID string) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&models.Wishlist{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Unscoped().Where("wishlist_id = ?", id).Delete(&models.WishlistItem{}).Error
+	})
+}
+
+// AddItem saves a product to the list. Adding a product twice is a no-op.
+func (s *wishlistService) AddItem(ctx context.Context, id uint, userID string, input models.WishlistItemInput) (models.Wishlist, error) {
+	if _, err := s.GetWishlist(ctx, id, userID); err != nil {
+		return models.Wishlist{}, err
+	}
+	if err := s.DB.WithContext(ctx).First(&models.Product{}, input.ProductID).Error; err != nil {
+		return models.Wishlist{}, err
+	}
+
+	item := models.WishlistItem{WishlistID: id, ProductID: input.ProductID}
+	if err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&item).Error; err != nil {
+		return models.Wishlist{}, err
+	}
+	return s.GetWishlist(ctx, id, userID)
+}
+
+func (s *wishlistService) RemoveItem(ctx context.Context, id uint, userID string, productID uint) (models.Wishlist, error) {
+	if _, err := s.GetWishlist(ctx, id, userID); err != nil {
+		return models.Wishlist{}, err
+	}
+
+	result := s.DB.WithContext(ctx).Unscoped().Where("wishlist_id = ? AND product_id = ?", id, productID).Delete(&models.WishlistItem{})
+	if result.Error != nil {
+		return models.Wishlist{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Wishlist{}, gorm.ErrRecordNotFound
+	}
+	return s.GetWishlist(ctx, id, userID)
+}
+
+// Share gives the list a public token, keeping the existing one if the list
+// is already shared.
+func (s *wishlistService) Share(ctx context.Context, id uint, userID string) (models.Wishlist, error) {
+	wishlist, err := s.GetWishlist(ctx, id, userID)
+	if err != nil {
+		return models.Wishlist{}, err
+	}
+	if wishlist.ShareToken != nil {
+		return wishlist, nil
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return models.Wishlist{}, err
+	}
+	if err := s.update(ctx, id, userID, "share_token", token); err != nil {
+		return models.Wishlist{}, err
+	}
+	return s.GetWishlist(ctx, id, userID)
+}
+
+func (s *wishlistService) Unshare(ctx context.Context, id uint, userID string) (models.Wishlist, error) {
+	if err := s.update(ctx, id, userID, "share_token", nil); err != nil {
+		return models.Wishlist{}, err
+	}
+	return s.GetWishlist(ctx, id, userID)
+}
+
+func (s *wishlistService) update(ctx context.Context, id uint, userID, column string, value interface{}) error {
+	result := s.DB.WithContext(ctx).Model(&models.Wishlist{}).Where("id = ? AND user_id = ?", id, userID).Update(column, value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *wishlistService) load(query *gorm.DB) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := query.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	}).Preload("Items.Product").First(&wishlist).Error
+	return wishlist, err
+}
+
+func wishlistName(input models.WishlistInput) (string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidWishlist)
+	}
+	return name, nil
+}
+
+func newShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}